@@ -1,7 +1,11 @@
 package pongo2
 
 import (
+	"encoding/json"
+	"net/url"
+	"reflect"
 	"regexp"
+	"sort"
 
 	"github.com/juju/errors"
 )
@@ -14,6 +18,10 @@ var reIdentifiers = regexp.MustCompile("^[a-zA-Z0-9_]+$")
 // Currently, context["pongo2"] contains the following keys:
 //  1. version: returns the version string
 //
+// Additionally the global function range(start, stop[, step]) is available (unless
+// your context provides a "range"-key itself). It returns a list of integers following
+// Python's half-open semantics ({% for i in range(0, 3) %} iterates over 0, 1 and 2).
+//
 // Template examples for accessing items from your context:
 //     {{ myconstant }}
 //     {{ myfunc("test", 42) }}
@@ -33,6 +41,60 @@ func (c Context) checkForValidIdentifiers() *Error {
 	return nil
 }
 
+// Validate checks whether all required keys are available in the context
+// and whether their values are of the expected kind. Pointers are resolved
+// before the kind is compared. Use reflect.Invalid as kind if a key is required
+// but may be of any type.
+func (c Context) Validate(required map[string]reflect.Kind) error {
+	// Check in a stable order to always report the same key first
+	keys := make([]string, 0, len(required))
+	for k := range required {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v, has := c[k]
+		if !has {
+			return &Error{
+				Sender:    "validate",
+				OrigError: errors.Errorf("required context-key '%s' is missing", k),
+			}
+		}
+
+		kind := required[k]
+		if kind == reflect.Invalid {
+			continue
+		}
+		if actual := AsValue(v).getResolvedValue().Kind(); actual != kind {
+			return &Error{
+				Sender:    "validate",
+				OrigError: errors.Errorf("context-key '%s' must be of kind %s (is %s)", k, kind.String(), actual.String()),
+			}
+		}
+	}
+	return nil
+}
+
+// ContextFromValues creates a context from url.Values (e. g. the query or form
+// values of a request). Keys with a single value become strings, repeated keys
+// become []string. Keys aren't changed, so executing a template with keys which
+// aren't valid identifiers (like "user-name") fails.
+func ContextFromValues(v url.Values) Context {
+	ctx := make(Context, len(v))
+	for k, values := range v {
+		switch len(values) {
+		case 0:
+			ctx[k] = ""
+		case 1:
+			ctx[k] = values[0]
+		default:
+			ctx[k] = values
+		}
+	}
+	return ctx
+}
+
 // Update updates this context with the key/value-pairs from another context.
 func (c Context) Update(other Context) Context {
 	for k, v := range other {
@@ -58,40 +120,124 @@ func (c Context) Update(other Context) Context {
 // NewChildExecutionContext(parent) function.
 type ExecutionContext struct {
 	template *Template
+	parent   *ExecutionContext // nil for the top-level scope
+	recovery *errorRecovery    // nil if the error-recovery mode is disabled
+	readOnly bool              // true for the top-level scope if TemplateSet.ReadOnlyContext is set
+	isolated bool              // true for macro scopes and call-bodies: set-tags don't update enclosing scopes
 
-	Autoescape bool
-	Public     Context
-	Private    Context
-	Shared     Context
+	// Results of pure filters (see MarkFilterPure) and the positions of the
+	// cycle-tags. Both are only set for the top-level scope (see root) and
+	// allocated on their first use.
+	filterCache map[filterCacheKey]*Value
+	cycles      map[*tagCycleNode]int
+
+	Autoescape     bool
+	AutoescapeMode AutoescapeMode // escaper used while Autoescape is true
+	Public         Context
+	Private        Context
+	Shared         Context
+}
+
+// AutoescapeMode selects how the output of variable tags is escaped
+// when autoescaping is enabled.
+type AutoescapeMode int
+
+const (
+	// AutoescapeHTML escapes the output using the escape filter (default).
+	AutoescapeHTML AutoescapeMode = iota
+	// AutoescapeJSON escapes the output for use within a JSON string.
+	AutoescapeJSON
+)
+
+// escape applies the escaper of the current autoescape mode to the given
+// value. It must only be called if ctx.Autoescape is true.
+func (ctx *ExecutionContext) escape(value *Value) (*Value, *Error) {
+	switch ctx.AutoescapeMode {
+	case AutoescapeJSON:
+		b, err := json.Marshal(value.String())
+		if err != nil {
+			return nil, ctx.OrigError(err, nil)
+		}
+		// Strip the surrounding quotes; the template provides them
+		return AsValue(string(b[1 : len(b)-1])), nil
+	default:
+		escape, _ := getFilter("escape")
+		return escape(value, nil)
+	}
 }
 
 var pongo2MetaContext = Context{
 	"version": Version,
 }
 
+// maxRangeItems limits the number of items range() generates.
+const maxRangeItems = 100000
+
+// pongo2Range implements the global range(start, stop[, step]) function.
+func pongo2Range(args ...*Value) ([]int, error) {
+	if len(args) < 2 || len(args) > 3 {
+		return nil, errors.Errorf("range() takes 2 or 3 arguments (start, stop[, step]), got %d", len(args))
+	}
+	for idx, arg := range args {
+		if !arg.IsInteger() {
+			return nil, errors.Errorf("range() argument %d must be an integer (not %s)", idx+1, arg.String())
+		}
+	}
+
+	start, stop, step := args[0].Integer(), args[1].Integer(), 1
+	if len(args) == 3 {
+		step = args[2].Integer()
+	}
+	if step == 0 {
+		return nil, errors.New("range() step must not be zero")
+	}
+
+	result := []int{}
+	for i := start; (step > 0 && i < stop) || (step < 0 && i > stop); i += step {
+		if len(result) == maxRangeItems {
+			return nil, errors.Errorf("range() must not generate more than %d items", maxRangeItems)
+		}
+		result = append(result, i)
+	}
+	return result, nil
+}
+
 func newExecutionContext(tpl *Template, ctx Context) *ExecutionContext {
 	privateCtx := make(Context)
 
 	// Make the pongo2-related funcs/vars available to the context
 	privateCtx["pongo2"] = pongo2MetaContext
 
-	return &ExecutionContext{
+	// The built-in range function must not hide user-provided data
+	if _, has := ctx["range"]; !has {
+		privateCtx["range"] = pongo2Range
+	}
+
+	execCtx := &ExecutionContext{
 		template: tpl,
 
 		Public:     ctx,
 		Private:    privateCtx,
 		Autoescape: true,
 	}
+	execCtx.readOnly = tpl.set.ReadOnlyContext
+	if tpl.set.ErrorPlaceholder != nil {
+		execCtx.recovery = &errorRecovery{placeholder: tpl.set.ErrorPlaceholder}
+	}
+	return execCtx
 }
 
 func NewChildExecutionContext(parent *ExecutionContext) *ExecutionContext {
 	newctx := &ExecutionContext{
 		template: parent.template,
+		parent:   parent,
+		recovery: parent.recovery,
 
 		Public:     parent.Public,
 		Private:    make(Context),
 		Autoescape: parent.Autoescape,
 	}
+	newctx.AutoescapeMode = parent.AutoescapeMode
 	newctx.Shared = parent.Shared
 
 	// Copy all existing private items
@@ -100,6 +246,24 @@ func NewChildExecutionContext(parent *ExecutionContext) *ExecutionContext {
 	return newctx
 }
 
+// root returns the top-level scope of the execution.
+func (ctx *ExecutionContext) root() *ExecutionContext {
+	for ctx.parent != nil {
+		ctx = ctx.parent
+	}
+	return ctx
+}
+
+// checkAssignable returns an error if variables can't be assigned in this
+// scope (see TemplateSet.ReadOnlyContext). Tags assigning variables (like set
+// or cycle ... as) must check it before the assignment.
+func (ctx *ExecutionContext) checkAssignable(name string, token *Token) *Error {
+	if ctx.readOnly {
+		return ctx.OrigError(errors.Errorf("Cannot set '%s': the top-level context is read-only.", name), token)
+	}
+	return nil
+}
+
 func (ctx *ExecutionContext) Error(msg string, token *Token) *Error {
 	return ctx.OrigError(errors.New(msg), token)
 }
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"strings"
 )
 
 // The Error type is being used to address an error during lexing, parsing or
@@ -27,7 +28,7 @@ func (e *Error) updateFromTokenIfNeeded(template *Template, t *Token) *Error {
 		e.Template = template
 	}
 
-	if e.Token == nil {
+	if e.Token == nil && t != nil {
 		e.Token = t
 		if e.Line <= 0 {
 			e.Line = t.Line
@@ -35,6 +36,14 @@ func (e *Error) updateFromTokenIfNeeded(template *Template, t *Token) *Error {
 		}
 	}
 
+	if e.Filename == "" {
+		if e.Token != nil {
+			e.Filename = e.Token.Filename
+		} else if e.Template != nil {
+			e.Filename = e.Template.name
+		}
+	}
+
 	return e
 }
 
@@ -89,3 +98,16 @@ func (e *Error) RawLine() (line string, available bool, outErr error) {
 	}
 	return "", false, nil
 }
+
+// RecoveredErrors is returned by the Execute*-functions along with the rendered
+// output if errors have been recovered in error-recovery mode (see
+// TemplateSet.ErrorPlaceholder).
+type RecoveredErrors []*Error
+
+func (e RecoveredErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("%d error(s) recovered: %s", len(e), strings.Join(msgs, "; "))
+}
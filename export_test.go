@@ -0,0 +1,17 @@
+package pongo2
+
+// MaxRegexpCacheSize is the number of patterns cached per template set.
+const MaxRegexpCacheSize = maxRegexpCacheSize
+
+// RegexpCacheSize returns the number of the set's cached patterns.
+func (set *TemplateSet) RegexpCacheSize() int {
+	set.regexpCacheMutex.Lock()
+	defer set.regexpCacheMutex.Unlock()
+	return len(set.regexpCache)
+}
+
+// SetBufferPooling enables or disables the buffer pool (to compare the
+// allocations in benchmarks).
+func SetBufferPooling(enabled bool) {
+	bufferPoolDisabled = !enabled
+}
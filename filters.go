@@ -2,6 +2,9 @@ package pongo2
 
 import (
 	"fmt"
+	"reflect"
+	"sort"
+	"sync"
 
 	"github.com/juju/errors"
 )
@@ -11,16 +14,73 @@ type FilterFunction func(in *Value, param *Value) (out *Value, err *Error)
 
 var filters map[string]FilterFunction
 
+// contextFilterFunction is the signature of built-in filters which need access to
+// the current execution context (for example to the template set's configuration).
+// Every context filter must be registered as a regular FilterFunction as well which
+// is being used whenever no execution context is available (e. g. in ApplyFilter).
+type contextFilterFunction func(ctx *ExecutionContext, in *Value, param *Value) (*Value, *Error)
+
+var contextFilters map[string]contextFilterFunction
+
+// FilterArgumentValidator checks a filter's argument. Validators are called at
+// compile time if the argument is a literal (e. g. {{ x|truncatechars:"abc" }}).
+// Variable arguments aren't validated; the filter has to handle them itself.
+// Validators are not called if no argument (or nil) is given.
+type FilterArgumentValidator func(param *Value) error
+
+var filterValidators map[string]FilterArgumentValidator
+
+// pureFilters contains the filters whose results are memoized within an
+// execution (see MarkFilterPure).
+var pureFilters map[string]bool
+
+// filtersMutex guards filters, contextFilters, filterValidators and pureFilters
+var filtersMutex sync.RWMutex
+
+// errorFallbackFilters are applied even if a field or an index of the variable
+// they are applied to could not be looked up (e. g. {{ user.name.first|default:"n/a" }}
+// with name being a string): instead of aborting the execution, the filter's
+// parameter is being used. Other errors (like failing function calls) are not
+// affected.
+var errorFallbackFilters = map[string]bool{
+	"default":          true,
+	"default_if_error": true,
+}
+
 func init() {
 	filters = make(map[string]FilterFunction)
+	contextFilters = make(map[string]contextFilterFunction)
+	filterValidators = make(map[string]FilterArgumentValidator)
+	pureFilters = make(map[string]bool)
 }
 
 // FilterExists returns true if the given filter is already registered
 func FilterExists(name string) bool {
-	_, existing := filters[name]
+	_, existing := getFilter(name)
 	return existing
 }
 
+// getFilter returns the registered filter function.
+func getFilter(name string) (FilterFunction, bool) {
+	filtersMutex.RLock()
+	defer filtersMutex.RUnlock()
+	fn, existing := filters[name]
+	return fn, existing
+}
+
+// RegisteredFilters returns the sorted names of all registered filters.
+func RegisteredFilters() []string {
+	filtersMutex.RLock()
+	names := make([]string, 0, len(filters))
+	for name := range filters {
+		names = append(names, name)
+	}
+	filtersMutex.RUnlock()
+
+	sort.Strings(names)
+	return names
+}
+
 // RegisterFilter registers a new filter. If there's already a filter with the same
 // name, RegisterFilter will panic. You usually want to call this
 // function in the filter's init() function:
@@ -29,7 +89,9 @@ func FilterExists(name string) bool {
 // See http://www.florian-schlachter.de/post/pongo2/ for more about
 // writing filters and tags.
 func RegisterFilter(name string, fn FilterFunction) error {
-	if FilterExists(name) {
+	filtersMutex.Lock()
+	defer filtersMutex.Unlock()
+	if _, existing := filters[name]; existing {
 		return errors.Errorf("filter with name '%s' is already registered", name)
 	}
 	filters[name] = fn
@@ -39,10 +101,87 @@ func RegisterFilter(name string, fn FilterFunction) error {
 // ReplaceFilter replaces an already registered filter with a new implementation. Use this
 // function with caution since it allows you to change existing filter behaviour.
 func ReplaceFilter(name string, fn FilterFunction) error {
-	if !FilterExists(name) {
+	filtersMutex.Lock()
+	defer filtersMutex.Unlock()
+	if _, existing := filters[name]; !existing {
 		return errors.Errorf("filter with name '%s' does not exist (therefore cannot be overridden)", name)
 	}
 	filters[name] = fn
+	delete(contextFilters, name) // the replacement takes precedence
+	delete(filterValidators, name)
+	delete(pureFilters, name)
+	return nil
+}
+
+// RegisterFilterValidator registers an argument validator for an already
+// registered filter (see FilterArgumentValidator). Replacing the filter using
+// ReplaceFilter removes its validator.
+func RegisterFilterValidator(name string, validator FilterArgumentValidator) error {
+	filtersMutex.Lock()
+	defer filtersMutex.Unlock()
+	if _, existing := filters[name]; !existing {
+		return errors.Errorf("filter with name '%s' does not exist", name)
+	}
+	filterValidators[name] = validator
+	return nil
+}
+
+// MarkFilterPure marks an already registered filter as pure: its result only
+// depends on its input and argument. Within an execution, the filter is then
+// called only once per input and argument (currently only nil, bools, numbers
+// and strings are memoized). Replacing the filter using ReplaceFilter removes
+// the mark. Templates compiled before marking the filter aren't affected.
+func MarkFilterPure(name string) error {
+	filtersMutex.Lock()
+	defer filtersMutex.Unlock()
+	if _, existing := filters[name]; !existing {
+		return errors.Errorf("filter with name '%s' does not exist", name)
+	}
+	pureFilters[name] = true
+	return nil
+}
+
+// filterCacheKey identifies the result of a pure filter within an execution.
+type filterCacheKey struct {
+	name   string
+	in     interface{}
+	inSafe bool
+	param  interface{}
+}
+
+// filterCacheValue returns the underlying value if it's usable as (a part of)
+// a filter cache key.
+func filterCacheValue(v *Value) (interface{}, bool) {
+	rv := v.getResolvedValue()
+	if !rv.IsValid() {
+		return nil, true
+	}
+	switch rv.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if rv.CanInterface() {
+			return rv.Interface(), true
+		}
+	}
+	return nil, false
+}
+
+// validateFilterArgument calls the filter's argument validator (if any).
+func validateFilterArgument(name string, param *Value) *Error {
+	filtersMutex.RLock()
+	validator, has := filterValidators[name]
+	filtersMutex.RUnlock()
+	if !has || param == nil || param.IsNil() {
+		return nil
+	}
+	if err := validator(param); err != nil {
+		return &Error{
+			Sender:    fmt.Sprintf("filter:%s", name),
+			OrigError: err,
+		}
+	}
 	return nil
 }
 
@@ -58,7 +197,7 @@ func MustApplyFilter(name string, value *Value, param *Value) *Value {
 // ApplyFilter applies a filter to a given value using the given parameters.
 // Returns a *pongo2.Value or an error.
 func ApplyFilter(name string, value *Value, param *Value) (*Value, *Error) {
-	fn, existing := filters[name]
+	fn, existing := getFilter(name)
 	if !existing {
 		return nil, &Error{
 			Sender:    "applyfilter",
@@ -74,19 +213,59 @@ func ApplyFilter(name string, value *Value, param *Value) (*Value, *Error) {
 	return fn(value, param)
 }
 
+// applyFilterWithContext behaves like ApplyFilter, but prefers the filter's
+// context-aware implementation, if available.
+func applyFilterWithContext(ctx *ExecutionContext, name string, value *Value, param *Value) (*Value, *Error) {
+	filtersMutex.RLock()
+	fn, existing := contextFilters[name]
+	filtersMutex.RUnlock()
+	if existing {
+		if param == nil {
+			param = AsValue(nil)
+		}
+		return fn(ctx, value, param)
+	}
+	return ApplyFilter(name, value, param)
+}
+
 type filterCall struct {
 	token *Token
 
 	name      string
 	parameter IEvaluator
 
-	filterFunc FilterFunction
+	filterFunc        FilterFunction
+	contextFilterFunc contextFilterFunction
+
+	// pure filters are memoized within an execution (see MarkFilterPure)
+	pure bool
+
+	// alias is the filter chain of a filter alias (see the filteralias-tag)
+	alias []*filterCall
+}
+
+// applies checks whether the filter call applies the given filter (directly
+// or through a filter alias).
+func (fc *filterCall) applies(name string) bool {
+	if fc.alias == nil {
+		return fc.name == name
+	}
+	for _, call := range fc.alias {
+		if call.applies(name) {
+			return true
+		}
+	}
+	return false
 }
 
 func (fc *filterCall) Execute(v *Value, ctx *ExecutionContext) (*Value, *Error) {
 	var param *Value
 	var err *Error
 
+	if fc.alias != nil {
+		return applyFilterChain(ctx, fc.alias, v)
+	}
+
 	if fc.parameter != nil {
 		param, err = fc.parameter.Evaluate(ctx)
 		if err != nil {
@@ -96,10 +275,35 @@ func (fc *filterCall) Execute(v *Value, ctx *ExecutionContext) (*Value, *Error)
 		param = AsValue(nil)
 	}
 
-	filteredValue, err := fc.filterFunc(v, param)
+	var key filterCacheKey
+	var root *ExecutionContext
+	cacheable := fc.pure
+	if cacheable {
+		key = filterCacheKey{name: fc.name, inSafe: v.safe}
+		if key.in, cacheable = filterCacheValue(v); cacheable {
+			key.param, cacheable = filterCacheValue(param)
+		}
+		root = ctx.root()
+		if cached, has := root.filterCache[key]; cacheable && has {
+			return cached, nil
+		}
+	}
+
+	var filteredValue *Value
+	if fc.contextFilterFunc != nil {
+		filteredValue, err = fc.contextFilterFunc(ctx, v, param)
+	} else {
+		filteredValue, err = fc.filterFunc(v, param)
+	}
 	if err != nil {
 		return nil, err.updateFromTokenIfNeeded(ctx.template, fc.token)
 	}
+	if cacheable {
+		if root.filterCache == nil {
+			root.filterCache = make(map[filterCacheKey]*Value)
+		}
+		root.filterCache[key] = filteredValue
+	}
 	return filteredValue, nil
 }
 
@@ -117,13 +321,29 @@ func (p *Parser) parseFilter() (*filterCall, *Error) {
 		name:  identToken.Val,
 	}
 
+	// Filter aliases of the template take precedence
+	if p.template != nil {
+		if alias, has := p.template.filterAliases[identToken.Val]; has {
+			if p.Peek(TokenSymbol, ":") != nil {
+				return nil, p.Error(fmt.Sprintf("Filter alias '%s' does not take a parameter.", identToken.Val), nil)
+			}
+			filter.alias = alias
+			return filter, nil
+		}
+	}
+
 	// Get the appropriate filter function and bind it
+	filtersMutex.RLock()
 	filterFn, exists := filters[identToken.Val]
+	contextFilterFn := contextFilters[identToken.Val]
+	filter.pure = pureFilters[identToken.Val]
+	filtersMutex.RUnlock()
 	if !exists {
 		return nil, p.Error(fmt.Sprintf("Filter '%s' does not exist.", identToken.Val), identToken)
 	}
 
 	filter.filterFunc = filterFn
+	filter.contextFilterFunc = contextFilterFn
 
 	// Check for filter-argument (2 tokens needed: ':' ARG)
 	if p.Match(TokenSymbol, ":") != nil {
@@ -132,12 +352,95 @@ func (p *Parser) parseFilter() (*filterCall, *Error) {
 		}
 
 		// Get filter argument expression
+		argToken := p.Current()
 		v, err := p.parseVariableOrLiteral()
 		if err != nil {
 			return nil, err
 		}
 		filter.parameter = v
+
+		// Literal arguments can be validated right now
+		switch v.(type) {
+		case *stringResolver, *intResolver, *floatResolver, *boolResolver:
+			param, err := v.Evaluate(nil)
+			if err != nil {
+				return nil, err
+			}
+			if err := validateFilterArgument(filter.name, param); err != nil {
+				return nil, p.Error(fmt.Sprintf("Invalid argument for filter '%s': %s", filter.name, err.OrigError.Error()), argToken)
+			}
+		}
 	}
 
 	return filter, nil
 }
+
+// parseFilterChain parses one or more filters separated by "|".
+// FilterChain = Filter { "|" Filter }
+func (p *Parser) parseFilterChain() ([]*filterCall, *Error) {
+	var chain []*filterCall
+	for {
+		filter, err := p.parseFilter()
+		if err != nil {
+			return nil, err
+		}
+
+		// Check sandbox filter restriction
+		if p.template != nil {
+			if _, isBanned := p.template.set.bannedFilters[filter.name]; isBanned {
+				return nil, p.Error(fmt.Sprintf("Usage of filter '%s' is not allowed (sandbox restriction active).", filter.name), nil)
+			}
+		}
+
+		chain = append(chain, filter)
+
+		if p.Match(TokenSymbol, "|") == nil {
+			return chain, nil
+		}
+	}
+}
+
+// FilterChain is a parsed chain of filters (including their arguments)
+// returned by Parser.ParseFilterChain.
+type FilterChain struct {
+	calls []*filterCall
+}
+
+// Apply applies all filters of the chain to the given value (one after another).
+func (fc *FilterChain) Apply(ctx *ExecutionContext, v *Value) (*Value, *Error) {
+	return applyFilterChain(ctx, fc.calls, v)
+}
+
+// FilterApplied checks whether the chain applies the given filter.
+func (fc *FilterChain) FilterApplied(name string) bool {
+	for _, call := range fc.calls {
+		if call.applies(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseFilterChain parses a chain of filters like `lower|truncatechars:limit`
+// starting at the current token (useful for tags applying filters to their
+// output). Filter aliases and sandbox restrictions of the template are taken
+// into account. Filter arguments are evaluated when the chain is applied.
+func (p *Parser) ParseFilterChain() (*FilterChain, *Error) {
+	calls, err := p.parseFilterChain()
+	if err != nil {
+		return nil, err
+	}
+	return &FilterChain{calls: calls}, nil
+}
+
+// applyFilterChain applies all filters of the chain one after another.
+func applyFilterChain(ctx *ExecutionContext, chain []*filterCall, v *Value) (*Value, *Error) {
+	var err *Error
+	for _, call := range chain {
+		v, err = call.Execute(v, ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
@@ -12,14 +12,12 @@ package pongo2
    ----------------------------
 
    get_static_prefix (reason: web-framework specific)
-   pprint (reason: python-specific)
    static (reason: web-framework specific)
 
    Reconsideration (not implemented yet):
    --------------------------------------
 
-   force_escape (reason: not yet needed since this is the behaviour of pongo2's escape filter)
-   safeseq (reason: same reason as `force_escape`)
+   safeseq (reason: not yet needed)
    unordered_list (python-specific; not sure whether needed or not)
    dictsort (python-specific; maybe one could add a filter to sort a list of structs by a specific field name)
    dictsortreversed (see dictsort)
@@ -27,10 +25,15 @@ package pongo2
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"html"
+	"math"
 	"math/rand"
 	"net/url"
+	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -43,43 +46,65 @@ func init() {
 	rand.Seed(time.Now().Unix())
 
 	RegisterFilter("escape", filterEscape)
+	RegisterFilter("force_escape", filterForceEscape)
 	RegisterFilter("safe", filterSafe)
 	RegisterFilter("escapejs", filterEscapejs)
+	RegisterFilter("escape_template", filterEscapeTemplate)
 
 	RegisterFilter("add", filterAdd)
 	RegisterFilter("addslashes", filterAddslashes)
+	RegisterFilter("attr", filterAttr)
+	RegisterFilter("br2nl", filterBr2nl)
 	RegisterFilter("capfirst", filterCapfirst)
 	RegisterFilter("center", filterCenter)
 	RegisterFilter("cut", filterCut)
 	RegisterFilter("date", filterDate)
 	RegisterFilter("default", filterDefault)
+	RegisterFilter("default_if_error", filterDefaultIfError)
 	RegisterFilter("default_if_none", filterDefaultIfNone)
 	RegisterFilter("divisibleby", filterDivisibleby)
+	RegisterFilter("endswith", filterEndswith)
+	RegisterFilter("enumerate", filterEnumerate)
 	RegisterFilter("first", filterFirst)
 	RegisterFilter("floatformat", filterFloatformat)
 	RegisterFilter("get_digit", filterGetdigit)
+	RegisterFilter("icontains", filterIcontains)
 	RegisterFilter("iriencode", filterIriencode)
+	RegisterFilter("items", filterItems)
 	RegisterFilter("join", filterJoin)
+	RegisterFilter("json", filterJSON)
+	RegisterFilter("keys", filterKeys)
 	RegisterFilter("last", filterLast)
 	RegisterFilter("length", filterLength)
 	RegisterFilter("length_is", filterLengthis)
 	RegisterFilter("linebreaks", filterLinebreaks)
 	RegisterFilter("linebreaksbr", filterLinebreaksbr)
+	RegisterFilter("linecount", filterLinecount)
 	RegisterFilter("linenumbers", filterLinenumbers)
 	RegisterFilter("ljust", filterLjust)
 	RegisterFilter("lower", filterLower)
 	RegisterFilter("make_list", filterMakelist)
+	RegisterFilter("merge", filterMerge)
+	RegisterFilter("normalize_space", filterNormalizeSpace)
+	RegisterFilter("number_format", filterNumberFormat)
 	RegisterFilter("phone2numeric", filterPhone2numeric)
 	RegisterFilter("pluralize", filterPluralize)
+	RegisterFilter("pprint", filterPprint)
 	RegisterFilter("random", filterRandom)
+	RegisterFilter("regex_match", filterRegexMatch)
+	RegisterFilter("regex_replace", filterRegexReplace)
 	RegisterFilter("removetags", filterRemovetags)
+	RegisterFilter("replace", filterReplace)
 	RegisterFilter("rjust", filterRjust)
 	RegisterFilter("slice", filterSlice)
 	RegisterFilter("split", filterSplit)
+	RegisterFilter("sortkeys", filterSortkeys)
+	RegisterFilter("startswith", filterStartswith)
 	RegisterFilter("stringformat", filterStringformat)
 	RegisterFilter("striptags", filterStriptags)
 	RegisterFilter("time", filterDate) // time uses filterDate (same golang-format)
 	RegisterFilter("title", filterTitle)
+	RegisterFilter("tojson", filterToJSON)
 	RegisterFilter("truncatechars", filterTruncatechars)
 	RegisterFilter("truncatechars_html", filterTruncatecharsHTML)
 	RegisterFilter("truncatewords", filterTruncatewords)
@@ -88,12 +113,29 @@ func init() {
 	RegisterFilter("urlencode", filterUrlencode)
 	RegisterFilter("urlize", filterUrlize)
 	RegisterFilter("urlizetrunc", filterUrlizetrunc)
+	RegisterFilter("values", filterValues)
 	RegisterFilter("wordcount", filterWordcount)
 	RegisterFilter("wordwrap", filterWordwrap)
 	RegisterFilter("yesno", filterYesno)
+	RegisterFilter("zip", filterZip)
 
 	RegisterFilter("float", filterFloat)     // pongo-specific
 	RegisterFilter("integer", filterInteger) // pongo-specific
+
+	// Filters requiring a numeric argument
+	for _, name := range []string{"truncatechars", "truncatechars_html",
+		"truncatewords_html", "urlizetrunc", "wordwrap"} {
+		RegisterFilterValidator(name, validateIntegerArgument)
+	}
+	RegisterFilterValidator("truncatewords", validateTruncatewordsArgument)
+
+	// Expensive filters whose results are memoized within an execution
+	MarkFilterPure("regex_match")
+	MarkFilterPure("regex_replace")
+
+	contextFilters["random"] = filterRandomWithContext
+	contextFilters["regex_match"] = filterRegexMatchWithContext
+	contextFilters["regex_replace"] = filterRegexReplaceWithContext
 }
 
 func filterTruncatecharsHelper(s string, newLen int) string {
@@ -214,6 +256,26 @@ func filterTruncateHTMLHelper(value string, newOutput *bytes.Buffer, cond func()
 	}
 }
 
+// validateIntegerArgument accepts integers and strings containing an integer.
+func validateIntegerArgument(param *Value) error {
+	if param.IsInteger() {
+		return nil
+	}
+	if _, err := strconv.Atoi(param.String()); err != nil {
+		return errors.Errorf("argument must be an integer (got: '%s')", param.String())
+	}
+	return nil
+}
+
+// validateTruncatewordsArgument accepts the number of words, optionally
+// followed by a comma and the suffix (e. g. "5, [more]").
+func validateTruncatewordsArgument(param *Value) error {
+	if !param.IsString() {
+		return validateIntegerArgument(param)
+	}
+	return validateIntegerArgument(AsValue(strings.SplitN(param.String(), ",", 2)[0]))
+}
+
 func filterTruncatechars(in *Value, param *Value) (*Value, *Error) {
 	s := in.String()
 	newLen := param.Integer()
@@ -245,8 +307,19 @@ func filterTruncatecharsHTML(in *Value, param *Value) (*Value, *Error) {
 }
 
 func filterTruncatewords(in *Value, param *Value) (*Value, *Error) {
+	// The parameter is either the number of words or a string of the form
+	// "n,suffix" (the suffix replaces the default "...")
+	n, suffix := param.Integer(), "..."
+	if param.IsString() {
+		args := strings.SplitN(param.String(), ",", 2)
+		n = AsValue(args[0]).Integer()
+		if len(args) == 2 {
+			suffix = args[1]
+		}
+	}
+
+	// Runs of whitespace are treated as a single separator
 	words := strings.Fields(in.String())
-	n := param.Integer()
 	if n <= 0 {
 		return AsValue(""), nil
 	}
@@ -256,8 +329,8 @@ func filterTruncatewords(in *Value, param *Value) (*Value, *Error) {
 		out = append(out, words[i])
 	}
 
-	if n < len(words) {
-		out = append(out, "...")
+	if n < len(words) && suffix != "" {
+		out = append(out, suffix)
 	}
 
 	return AsValue(strings.Join(out, " ")), nil
@@ -314,17 +387,46 @@ func filterTruncatewordsHTML(in *Value, param *Value) (*Value, *Error) {
 	return AsSafeValue(newOutput.String()), nil
 }
 
+// filterEscape escapes the input unless it is already safe (e. g. because it
+// has been escaped before or has been marked safe) like Django's
+// conditional_escape. The result is safe, so it isn't autoescaped again.
 func filterEscape(in *Value, param *Value) (*Value, *Error) {
+	if in.safe {
+		return in, nil
+	}
+	return filterForceEscape(in, param)
+}
+
+// filterForceEscape escapes the input even if it is safe already.
+func filterForceEscape(in *Value, param *Value) (*Value, *Error) {
 	output := strings.Replace(in.String(), "&", "&amp;", -1)
 	output = strings.Replace(output, ">", "&gt;", -1)
 	output = strings.Replace(output, "<", "&lt;", -1)
 	output = strings.Replace(output, "\"", "&quot;", -1)
 	output = strings.Replace(output, "'", "&#39;", -1)
-	return AsValue(output), nil
+	return AsSafeValue(output), nil
+}
+
+// filterEscapeTemplateReplacer replaces template delimiters with the
+// templatetag-tag outputting them, so the result can be parsed as a template
+// again (rendering the original text).
+var filterEscapeTemplateReplacer = strings.NewReplacer(
+	"{{", "{% templatetag openvariable %}",
+	"}}", "{% templatetag closevariable %}",
+	"{%", "{% templatetag openblock %}",
+	"%}", "{% templatetag closeblock %}",
+	"{#", "{% templatetag opencomment %}",
+	"#}", "{% templatetag closecomment %}",
+)
+
+func filterEscapeTemplate(in *Value, param *Value) (*Value, *Error) {
+	return AsValue(filterEscapeTemplateReplacer.Replace(in.String())), nil
 }
 
 func filterSafe(in *Value, param *Value) (*Value, *Error) {
-	return in, nil // nothing to do here, just to keep track of the safe application
+	// Mark the value safe (e. g. for the escape filter); the safe application
+	// itself is tracked by the variable node
+	return &Value{val: in.val, safe: true}, nil
 }
 
 func filterEscapejs(in *Value, param *Value) (*Value, *Error) {
@@ -395,6 +497,37 @@ func filterAddslashes(in *Value, param *Value) (*Value, *Error) {
 	return AsValue(output), nil
 }
 
+func filterAttr(in *Value, param *Value) (*Value, *Error) {
+	path := param.String()
+	if path == "" {
+		return nil, &Error{
+			Sender:    "filter:attr",
+			OrigError: errors.New("filter 'attr' requires a field/key name (or a dotted path) as argument"),
+		}
+	}
+
+	// Resolve the path the same way "{{ in.path }}" would
+	resolver := &variableResolver{
+		parts: []*variablePart{{typ: varTypeIdent, s: "attr"}},
+	}
+	for _, name := range strings.Split(path, ".") {
+		if i, err := strconv.Atoi(name); err == nil {
+			resolver.parts = append(resolver.parts, &variablePart{typ: varTypeInt, i: i})
+		} else {
+			resolver.parts = append(resolver.parts, &variablePart{typ: varTypeIdent, s: name})
+		}
+	}
+
+	value, err := resolver.resolve(&ExecutionContext{Private: Context{"attr": in}})
+	if err != nil {
+		return nil, &Error{
+			Sender:    "filter:attr",
+			OrigError: err,
+		}
+	}
+	return value, nil
+}
+
 func filterCut(in *Value, param *Value) (*Value, *Error) {
 	return AsValue(strings.Replace(in.String(), param.String(), "", -1)), nil
 }
@@ -414,6 +547,12 @@ func filterDefault(in *Value, param *Value) (*Value, *Error) {
 	return in, nil
 }
 
+// The fallback is handled by the filter pipeline (see errorFallbackFilters),
+// so the filter itself passes the value through.
+func filterDefaultIfError(in *Value, param *Value) (*Value, *Error) {
+	return in, nil
+}
+
 func filterDefaultIfNone(in *Value, param *Value) (*Value, *Error) {
 	if in.IsNil() {
 		return param, nil
@@ -465,6 +604,115 @@ func filterFloatformat(in *Value, param *Value) (*Value, *Error) {
 	return AsValue(strconv.FormatFloat(val, 'f', decimals, 64)), nil
 }
 
+// filterNumberFormatArgs parses the argument of number_format:
+//     decimals[,'decimal separator'[,'grouping separator']]
+// The separators must be enclosed in single quotes (they might contain commas).
+func filterNumberFormatArgs(s string) (int, string, string, *Error) {
+	decimals, decSep, groupSep := 0, ".", ","
+
+	var args []string
+	for i := 0; i < len(s); {
+		if s[i] == '\'' {
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return 0, "", "", &Error{
+					Sender:    "filter:number_format",
+					OrigError: errors.New("separator not terminated by a single quote"),
+				}
+			}
+			args = append(args, s[i+1:i+1+end])
+			i += end + 2
+		} else {
+			end := strings.IndexByte(s[i:], ',')
+			if end < 0 {
+				end = len(s) - i
+			}
+			args = append(args, strings.TrimSpace(s[i:i+end]))
+			i += end
+		}
+		for i < len(s) && s[i] == ' ' {
+			i++
+		}
+		if i < len(s) {
+			if s[i] != ',' {
+				return 0, "", "", &Error{
+					Sender:    "filter:number_format",
+					OrigError: errors.Errorf("expected ',' after argument %d", len(args)),
+				}
+			}
+			i++
+			for i < len(s) && s[i] == ' ' {
+				i++
+			}
+		}
+	}
+
+	if len(args) > 3 {
+		return 0, "", "", &Error{
+			Sender:    "filter:number_format",
+			OrigError: errors.New("filter takes at most 3 arguments (decimals, decimal and grouping separator)"),
+		}
+	}
+	if len(args) > 0 && args[0] != "" {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 0 {
+			return 0, "", "", &Error{
+				Sender:    "filter:number_format",
+				OrigError: errors.Errorf("decimals must be a non-negative integer (got: '%s')", args[0]),
+			}
+		}
+		decimals = n
+	}
+	if len(args) > 1 {
+		decSep = args[1]
+	}
+	if len(args) > 2 {
+		groupSep = args[2]
+	}
+	return decimals, decSep, groupSep, nil
+}
+
+func filterNumberFormat(in *Value, param *Value) (*Value, *Error) {
+	if !in.IsNumber() {
+		if _, err := strconv.ParseFloat(in.String(), 64); err != nil {
+			return nil, &Error{
+				Sender:    "filter:number_format",
+				OrigError: errors.Errorf("input must be a number (got: '%s')", in.String()),
+			}
+		}
+	}
+
+	decimals, decSep, groupSep, err := filterNumberFormatArgs(param.String())
+	if err != nil {
+		return nil, err
+	}
+
+	val := in.Float()
+	formatted := strconv.FormatFloat(math.Abs(val), 'f', decimals, 64)
+	intPart, fracPart := formatted, ""
+	if idx := strings.IndexByte(formatted, '.'); idx >= 0 {
+		intPart, fracPart = formatted[:idx], formatted[idx+1:]
+	}
+
+	var b bytes.Buffer
+	// Omit the sign if the number has been rounded to zero
+	if val < 0 && strings.Trim(intPart+fracPart, "0") != "" {
+		b.WriteByte('-')
+	}
+	for i, digit := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteString(groupSep)
+		}
+		b.WriteRune(digit)
+	}
+	if fracPart != "" {
+		b.WriteString(decSep)
+		b.WriteString(fracPart)
+	}
+
+	return AsValue(b.String()), nil
+}
+
 func filterGetdigit(in *Value, param *Value) (*Value, *Error) {
 	i := param.Integer()
 	l := len(in.String()) // do NOT use in.Len() here!
@@ -474,8 +722,244 @@ func filterGetdigit(in *Value, param *Value) (*Value, *Error) {
 	return AsValue(in.String()[l-i] - 48), nil
 }
 
+func filterJSON(in *Value, param *Value) (*Value, *Error) {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return nil, &Error{
+			Sender:    "filter:json",
+			OrigError: err,
+		}
+	}
+	return AsValue(string(b)), nil
+}
+
+// filterToJSON produces canonical JSON: the input is marshalled and decoded
+// into generic maps and slices again, so the keys of every object (including
+// those generated from structs) are written in sorted order.
+func filterToJSON(in *Value, param *Value) (*Value, *Error) {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return nil, &Error{
+			Sender:    "filter:tojson",
+			OrigError: err,
+		}
+	}
+
+	var generic interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber() // keep numbers exactly as marshalled
+	if err := dec.Decode(&generic); err != nil {
+		return nil, &Error{
+			Sender:    "filter:tojson",
+			OrigError: err,
+		}
+	}
+
+	b, err = json.Marshal(generic)
+	if err != nil {
+		return nil, &Error{
+			Sender:    "filter:tojson",
+			OrigError: err,
+		}
+	}
+	return AsValue(string(b)), nil
+}
+
+// pprintVisit identifies a map, pointer or slice currently being printed
+// (used to detect cyclic references).
+type pprintVisit struct {
+	typ reflect.Type
+	ptr uintptr
+}
+
+const pprintIndent = "    "
+
+// pprintKeys sorts map keys by their Go-syntax representation.
+type pprintKeys []reflect.Value
+
+func (k pprintKeys) Len() int      { return len(k) }
+func (k pprintKeys) Swap(i, j int) { k[i], k[j] = k[j], k[i] }
+func (k pprintKeys) Less(i, j int) bool {
+	return fmt.Sprintf("%#v", k[i]) < fmt.Sprintf("%#v", k[j])
+}
+
+func pprintValue(b *bytes.Buffer, v reflect.Value, depth int, visiting map[pprintVisit]bool) {
+	if !v.IsValid() {
+		b.WriteString("nil")
+		return
+	}
+
+	indent := strings.Repeat(pprintIndent, depth)
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			b.WriteString("nil")
+			return
+		}
+		pprintValue(b, v.Elem(), depth, visiting)
+		return
+	case reflect.Ptr, reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			fmt.Fprintf(b, "%#v", v)
+			return
+		}
+		visit := pprintVisit{v.Type(), v.Pointer()}
+		if visiting[visit] {
+			fmt.Fprintf(b, "<cycle %s>", v.Type())
+			return
+		}
+		visiting[visit] = true
+		defer delete(visiting, visit)
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		b.WriteString("&")
+		pprintValue(b, v.Elem(), depth, visiting)
+	case reflect.Map:
+		keys := v.MapKeys()
+		if len(keys) == 0 {
+			fmt.Fprintf(b, "%s{}", v.Type())
+			return
+		}
+		// Sort the keys to get a stable output
+		sort.Sort(pprintKeys(keys))
+		fmt.Fprintf(b, "%s{\n", v.Type())
+		for _, key := range keys {
+			b.WriteString(indent + pprintIndent)
+			pprintValue(b, key, depth+1, visiting)
+			b.WriteString(": ")
+			pprintValue(b, v.MapIndex(key), depth+1, visiting)
+			b.WriteString(",\n")
+		}
+		b.WriteString(indent + "}")
+	case reflect.Slice, reflect.Array:
+		if v.Len() == 0 {
+			fmt.Fprintf(b, "%s{}", v.Type())
+			return
+		}
+		fmt.Fprintf(b, "%s{\n", v.Type())
+		for i := 0; i < v.Len(); i++ {
+			b.WriteString(indent + pprintIndent)
+			pprintValue(b, v.Index(i), depth+1, visiting)
+			b.WriteString(",\n")
+		}
+		b.WriteString(indent + "}")
+	case reflect.Struct:
+		if v.NumField() == 0 {
+			fmt.Fprintf(b, "%s{}", v.Type())
+			return
+		}
+		fmt.Fprintf(b, "%s{\n", v.Type())
+		for i := 0; i < v.NumField(); i++ {
+			b.WriteString(indent + pprintIndent + v.Type().Field(i).Name + ": ")
+			pprintValue(b, v.Field(i), depth+1, visiting)
+			b.WriteString(",\n")
+		}
+		b.WriteString(indent + "}")
+	default:
+		// fmt handles reflect.Values (even of unexported fields) itself
+		fmt.Fprintf(b, "%#v", v)
+	}
+}
+
+func filterPprint(in *Value, param *Value) (*Value, *Error) {
+	var b bytes.Buffer
+	pprintValue(&b, in.val, 0, make(map[pprintVisit]bool))
+
+	// The dump may contain arbitrary strings, so it's escaped here already
+	escaped, err := filterEscape(AsValue(b.String()), nil)
+	if err != nil {
+		return nil, err
+	}
+	return AsSafeValue(escaped.String()), nil
+}
+
+// filterItems returns the entries of a map (sorted by key) or the exported
+// fields of a struct (in declaration order) as (key, value) pairs:
+// {% for name, value in obj|items %}. The name of a field can be changed
+// using the field tag `pongo2:"name"`; fields tagged `pongo2:"-"` are skipped.
+func filterItems(in *Value, param *Value) (*Value, *Error) {
+	rv := in.getResolvedValue()
+	if rv.Kind() == reflect.Map {
+		keys := sortedKeys(rv.MapKeys())
+		sort.Sort(keys)
+
+		items := make([][]interface{}, 0, len(keys))
+		for _, key := range keys {
+			items = append(items, []interface{}{key.Interface(), rv.MapIndex(key).Interface()})
+		}
+		return AsValue(items), nil
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, &Error{
+			Sender:    "filter:items",
+			OrigError: errors.Errorf("filter input must be a map or a struct (got: %s)", rv.Kind().String()),
+		}
+	}
+
+	t := rv.Type()
+	items := make([][]interface{}, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			// unexported
+			continue
+		}
+		name := field.Name
+		if tag := field.Tag.Get("pongo2"); tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+		items = append(items, []interface{}{name, rv.Field(i).Interface()})
+	}
+	return AsValue(items), nil
+}
+
 const filterIRIChars = "/#%[]=:;$&()+,!?*@'~"
 
+// filterKeys returns the keys of a map in sorted order: {% for k in m|keys %}
+func filterKeys(in *Value, param *Value) (*Value, *Error) {
+	rv := in.getResolvedValue()
+	if rv.Kind() != reflect.Map {
+		return nil, &Error{
+			Sender:    "filter:keys",
+			OrigError: errors.Errorf("filter input must be a map (got: %s)", rv.Kind().String()),
+		}
+	}
+
+	keys := sortedKeys(rv.MapKeys())
+	sort.Sort(keys)
+
+	result := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, key.Interface())
+	}
+	return AsValue(result), nil
+}
+
+// filterValues returns the values of a map in the order of their (sorted)
+// keys: {% for v in m|values %}
+func filterValues(in *Value, param *Value) (*Value, *Error) {
+	rv := in.getResolvedValue()
+	if rv.Kind() != reflect.Map {
+		return nil, &Error{
+			Sender:    "filter:values",
+			OrigError: errors.Errorf("filter input must be a map (got: %s)", rv.Kind().String()),
+		}
+	}
+
+	keys := sortedKeys(rv.MapKeys())
+	sort.Sort(keys)
+
+	result := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, rv.MapIndex(key).Interface())
+	}
+	return AsValue(result), nil
+}
+
 func filterIriencode(in *Value, param *Value) (*Value, *Error) {
 	var b bytes.Buffer
 
@@ -518,6 +1002,34 @@ func filterLower(in *Value, param *Value) (*Value, *Error) {
 	return AsValue(strings.ToLower(in.String())), nil
 }
 
+// filterMerge returns a new map containing the keys of the input overlaid by
+// the keys of the parameter (neither of them is modified). Both must be maps
+// with string keys (like pongo2.Context), the parameter may be nil:
+//     {{ base_attrs|merge:extra_attrs }}
+func filterMerge(in *Value, param *Value) (*Value, *Error) {
+	merged := make(map[string]interface{})
+	for idx, v := range []*Value{in, param} {
+		if idx == 1 && v.IsNil() {
+			break
+		}
+		rv := v.getResolvedValue()
+		if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+			what := "filter input"
+			if idx == 1 {
+				what = "parameter"
+			}
+			return nil, &Error{
+				Sender:    "filter:merge",
+				OrigError: errors.Errorf("%s must be a map with string keys (got: %s)", what, rv.Kind().String()),
+			}
+		}
+		for _, key := range rv.MapKeys() {
+			merged[key.String()] = rv.MapIndex(key).Interface()
+		}
+	}
+	return AsValue(merged), nil
+}
+
 func filterMakelist(in *Value, param *Value) (*Value, *Error) {
 	s := in.String()
 	result := make([]string, 0, len(s))
@@ -536,8 +1048,34 @@ func filterCapfirst(in *Value, param *Value) (*Value, *Error) {
 	return AsValue(strings.ToUpper(string(r)) + t[size:]), nil
 }
 
+// filterPadHelper extracts the width and the (optional) fill character
+// from a padding filter's parameter. The parameter is either the width
+// itself or a string of the form "width,fillchar" (e. g. "20,*").
+func filterPadHelper(name string, param *Value) (int, string, *Error) {
+	if !param.IsString() {
+		return param.Integer(), " ", nil
+	}
+
+	args := strings.SplitN(param.String(), ",", 2)
+	width := AsValue(args[0]).Integer()
+	if len(args) == 1 {
+		return width, " ", nil
+	}
+
+	if utf8.RuneCountInString(args[1]) != 1 {
+		return 0, "", &Error{
+			Sender:    fmt.Sprintf("filter:%s", name),
+			OrigError: errors.Errorf("fill character must be exactly one character (got: '%s')", args[1]),
+		}
+	}
+	return width, args[1], nil
+}
+
 func filterCenter(in *Value, param *Value) (*Value, *Error) {
-	width := param.Integer()
+	width, fill, err := filterPadHelper("center", param)
+	if err != nil {
+		return nil, err
+	}
 	slen := in.Len()
 	if width <= slen {
 		return in, nil
@@ -547,8 +1085,8 @@ func filterCenter(in *Value, param *Value) (*Value, *Error) {
 	left := spaces/2 + spaces%2
 	right := spaces / 2
 
-	return AsValue(fmt.Sprintf("%s%s%s", strings.Repeat(" ", left),
-		in.String(), strings.Repeat(" ", right))), nil
+	return AsValue(fmt.Sprintf("%s%s%s", strings.Repeat(fill, left),
+		in.String(), strings.Repeat(fill, right))), nil
 }
 
 func filterDate(in *Value, param *Value) (*Value, *Error) {
@@ -620,10 +1158,205 @@ func filterSplit(in *Value, param *Value) (*Value, *Error) {
 	return AsValue(chunks), nil
 }
 
+// splitEscapedArgs splits a comma-separated filter argument. A literal comma
+// is escaped as \, and a literal backslash as \\ (note that backslashes must
+// be escaped in template strings as well, e. g. {{ s|replace:"a\\,b,c" }}).
+func splitEscapedArgs(s string) []string {
+	var args []string
+	var current bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && (s[i+1] == ',' || s[i+1] == '\\'):
+			i++
+			current.WriteByte(s[i])
+		case s[i] == ',':
+			args = append(args, current.String())
+			current.Reset()
+		default:
+			current.WriteByte(s[i])
+		}
+	}
+	return append(args, current.String())
+}
+
+func filterReplace(in *Value, param *Value) (*Value, *Error) {
+	args := splitEscapedArgs(param.String())
+	if len(args) < 2 || len(args) > 3 {
+		return nil, &Error{
+			Sender:    "filter:replace",
+			OrigError: errors.New("filter argument must be of the form 'old,new' or 'old,new,count'"),
+		}
+	}
+
+	count := -1
+	if len(args) == 3 {
+		n, err := strconv.Atoi(strings.TrimSpace(args[2]))
+		if err != nil || n < 0 {
+			return nil, &Error{
+				Sender:    "filter:replace",
+				OrigError: errors.Errorf("count must be a non-negative integer (got: '%s')", args[2]),
+			}
+		}
+		count = n
+	}
+
+	return AsValue(strings.Replace(in.String(), args[0], args[1], count)), nil
+}
+
+// splitRegexArgs splits a filter argument at the first comma of the pattern
+// into the pattern and the remainder. Commas within character classes and
+// repetitions (like [,;] or {2,3}) don't count; elsewhere a literal comma can
+// be escaped as \, (escape sequences are kept since they are valid in a pattern).
+func splitRegexArgs(s string) (string, string, bool) {
+	inClass, inRepetition := false, false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++ // skip the escaped character
+		case inClass:
+			inClass = s[i] != ']'
+		case inRepetition:
+			inRepetition = s[i] != '}'
+		case s[i] == '[':
+			inClass = true
+		case s[i] == '{':
+			inRepetition = true
+		case s[i] == ',':
+			return s[:i], s[i+1:], true
+		}
+	}
+	return s, "", false
+}
+
+func filterRegexReplaceHelper(set *TemplateSet, in *Value, param *Value) (*Value, *Error) {
+	pattern, replacement, ok := splitRegexArgs(param.String())
+	if !ok {
+		return nil, &Error{
+			Sender:    "filter:regex_replace",
+			OrigError: errors.New("filter argument must be of the form 'pattern,replacement'"),
+		}
+	}
+
+	re, err := set.compileRegexp(pattern)
+	if err != nil {
+		return nil, &Error{
+			Sender:    "filter:regex_replace",
+			OrigError: err,
+		}
+	}
+
+	return AsValue(re.ReplaceAllString(in.String(), replacement)), nil
+}
+
+func filterRegexReplace(in *Value, param *Value) (*Value, *Error) {
+	return filterRegexReplaceHelper(DefaultSet, in, param)
+}
+
+func filterRegexReplaceWithContext(ctx *ExecutionContext, in *Value, param *Value) (*Value, *Error) {
+	return filterRegexReplaceHelper(ctx.template.set, in, param)
+}
+
+func filterRegexMatchHelper(set *TemplateSet, in *Value, param *Value) (*Value, *Error) {
+	pattern, flags, hasFlags := splitRegexArgs(param.String())
+	if hasFlags && flags != "" {
+		// Go's regexp supports the flags i, m, s and U
+		if strings.Trim(flags, "imsU") != "" {
+			return nil, &Error{
+				Sender:    "filter:regex_match",
+				OrigError: errors.Errorf("unsupported flags '%s' (allowed: i, m, s and U)", flags),
+			}
+		}
+		pattern = fmt.Sprintf("(?%s)%s", flags, pattern)
+	}
+
+	re, err := set.compileRegexp(pattern)
+	if err != nil {
+		return nil, &Error{
+			Sender:    "filter:regex_match",
+			OrigError: err,
+		}
+	}
+
+	return AsValue(re.MatchString(in.String())), nil
+}
+
+func filterRegexMatch(in *Value, param *Value) (*Value, *Error) {
+	return filterRegexMatchHelper(DefaultSet, in, param)
+}
+
+func filterRegexMatchWithContext(ctx *ExecutionContext, in *Value, param *Value) (*Value, *Error) {
+	return filterRegexMatchHelper(ctx.template.set, in, param)
+}
+
+func filterStartswith(in *Value, param *Value) (*Value, *Error) {
+	return AsValue(strings.HasPrefix(in.String(), param.String())), nil
+}
+
+// filterIcontains checks whether the input contains the parameter (case-insensitive).
+func filterIcontains(in *Value, param *Value) (*Value, *Error) {
+	return AsValue(strings.Contains(strings.ToLower(in.String()), strings.ToLower(param.String()))), nil
+}
+
+// filterEnumerate returns the items of a slice, array or string as (index,
+// item) pairs: {% for i, x in items|enumerate %}. The index starts at 0 unless
+// another start is given ({{ items|enumerate:1 }}).
+func filterEnumerate(in *Value, param *Value) (*Value, *Error) {
+	if !in.CanSlice() {
+		return nil, &Error{
+			Sender:    "filter:enumerate",
+			OrigError: errors.Errorf("filter input must be a slice, array or string (got: %s)", in.getResolvedValue().Kind().String()),
+		}
+	}
+
+	start := 0
+	if !param.IsNil() {
+		if !param.IsInteger() {
+			return nil, &Error{
+				Sender:    "filter:enumerate",
+				OrigError: errors.Errorf("start must be an integer (got: %s)", param.String()),
+			}
+		}
+		start = param.Integer()
+	}
+
+	pairs := make([][]interface{}, 0, in.Len())
+	in.Iterate(func(idx, count int, item, value *Value) bool {
+		pairs = append(pairs, []interface{}{start + idx, item.Interface()})
+		return true
+	}, func() {})
+	return AsValue(pairs), nil
+}
+
+func filterEndswith(in *Value, param *Value) (*Value, *Error) {
+	return AsValue(strings.HasSuffix(in.String(), param.String())), nil
+}
+
 func filterLinebreaksbr(in *Value, param *Value) (*Value, *Error) {
 	return AsValue(strings.Replace(in.String(), "\n", "<br />", -1)), nil
 }
 
+var reBr = regexp.MustCompile(`(?i)<br\s*/?\s*>`)
+
+// filterBr2nl is the inverse of linebreaksbr: it replaces all <br>-tags
+// (<br>, <br/> and <br />, case-insensitive) with newlines.
+func filterBr2nl(in *Value, param *Value) (*Value, *Error) {
+	return AsValue(reBr.ReplaceAllString(in.String(), "\n")), nil
+}
+
+func filterLinecount(in *Value, param *Value) (*Value, *Error) {
+	s := in.String()
+	if s == "" {
+		return AsValue(0), nil
+	}
+
+	// A trailing newline does not start a new line
+	count := strings.Count(s, "\n")
+	if !strings.HasSuffix(s, "\n") {
+		count++
+	}
+	return AsValue(count), nil
+}
+
 func filterLinenumbers(in *Value, param *Value) (*Value, *Error) {
 	lines := strings.Split(in.String(), "\n")
 	output := make([]string, 0, len(lines))
@@ -634,11 +1367,15 @@ func filterLinenumbers(in *Value, param *Value) (*Value, *Error) {
 }
 
 func filterLjust(in *Value, param *Value) (*Value, *Error) {
-	times := param.Integer() - in.Len()
+	width, fill, err := filterPadHelper("ljust", param)
+	if err != nil {
+		return nil, err
+	}
+	times := width - in.Len()
 	if times < 0 {
 		times = 0
 	}
-	return AsValue(fmt.Sprintf("%s%s", in.String(), strings.Repeat(" ", times))), nil
+	return AsValue(fmt.Sprintf("%s%s", in.String(), strings.Repeat(fill, times))), nil
 }
 
 func filterUrlencode(in *Value, param *Value) (*Value, *Error) {
@@ -737,15 +1474,45 @@ func filterStringformat(in *Value, param *Value) (*Value, *Error) {
 	return AsValue(fmt.Sprintf(param.String(), in.Interface())), nil
 }
 
-var reStriptags = regexp.MustCompile("<[^>]*?>")
+var (
+	// A tag's attribute values may contain '>' when they are quoted
+	reStriptags          = regexp.MustCompile(`<!--[\s\S]*?-->|<(?:[^>"']|"[^"]*"|'[^']*')*>`)
+	reStriptagsSpace     = regexp.MustCompile(`[ \t\f\v]+`)
+	reStriptagsLineSpace = regexp.MustCompile(`[ \t\f\v]*\n\s*`)
+	reStriptagsEntity    = regexp.MustCompile(`&(?:#[0-9]+|#[xX][0-9a-fA-F]+|[a-zA-Z][a-zA-Z0-9]*);?`)
+)
 
+// filterStriptags removes all HTML tags (and comments) and unescapes the
+// entities of the remaining text, except for the ones of '<', '>' and '&'
+// (so the result can't turn into markup if it's marked as safe). Runs of
+// whitespace are collapsed to a single space (or a single newline if they
+// contain a line break). The result is not safe.
 func filterStriptags(in *Value, param *Value) (*Value, *Error) {
 	s := in.String()
 
 	// Strip all tags
 	s = reStriptags.ReplaceAllString(s, "")
 
-	return AsValue(strings.TrimSpace(s)), nil
+	// Collapse whitespace, keeping line breaks
+	s = reStriptagsSpace.ReplaceAllString(s, " ")
+	s = reStriptagsLineSpace.ReplaceAllString(s, "\n")
+
+	s = reStriptagsEntity.ReplaceAllStringFunc(strings.TrimSpace(s), func(entity string) string {
+		unescaped := html.UnescapeString(entity)
+		if strings.ContainsAny(unescaped, "<>&") {
+			return entity
+		}
+		return unescaped
+	})
+
+	return AsValue(s), nil
+}
+
+// filterNormalizeSpace collapses all runs of whitespace (including newlines
+// and tabs) to single spaces and trims the ends (like XSLT's normalize-space).
+// Use it with the filter-tag to normalize the rendered content of a block.
+func filterNormalizeSpace(in *Value, param *Value) (*Value, *Error) {
+	return AsValue(strings.Join(strings.Fields(in.String()), " ")), nil
 }
 
 // https://en.wikipedia.org/wiki/Phoneword
@@ -802,12 +1569,37 @@ func filterPluralize(in *Value, param *Value) (*Value, *Error) {
 	}
 }
 
+// RandomSource is the source of randomness for the 'random'-filter. Intn
+// returns a number in [0, n). Set it on a TemplateSet using the Rand field.
+type RandomSource interface {
+	Intn(n int) int
+}
+
+func filterRandomHelper(in *Value, intn func(int) int) (*Value, *Error) {
+	if !in.CanSlice() {
+		return nil, &Error{
+			Sender:    "filter:random",
+			OrigError: errors.New("filter input argument must be a slice, an array or a string"),
+		}
+	}
+	if in.Len() <= 0 {
+		return nil, &Error{
+			Sender:    "filter:random",
+			OrigError: errors.New("cannot pick a random element from an empty input"),
+		}
+	}
+	return in.Index(intn(in.Len())), nil
+}
+
 func filterRandom(in *Value, param *Value) (*Value, *Error) {
-	if !in.CanSlice() || in.Len() <= 0 {
-		return in, nil
+	return filterRandomHelper(in, rand.Intn)
+}
+
+func filterRandomWithContext(ctx *ExecutionContext, in *Value, param *Value) (*Value, *Error) {
+	if r := ctx.template.set.Rand; r != nil {
+		return filterRandomHelper(in, r.Intn)
 	}
-	i := rand.Intn(in.Len())
-	return in.Index(i), nil
+	return filterRandomHelper(in, rand.Intn)
 }
 
 func filterRemovetags(in *Value, param *Value) (*Value, *Error) {
@@ -824,35 +1616,154 @@ func filterRemovetags(in *Value, param *Value) (*Value, *Error) {
 }
 
 func filterRjust(in *Value, param *Value) (*Value, *Error) {
-	return AsValue(fmt.Sprintf(fmt.Sprintf("%%%ds", param.Integer()), in.String())), nil
+	width, fill, err := filterPadHelper("rjust", param)
+	if err != nil {
+		return nil, err
+	}
+	times := width - in.Len()
+	if times < 0 {
+		times = 0
+	}
+	return AsValue(fmt.Sprintf("%s%s", strings.Repeat(fill, times), in.String())), nil
 }
 
-func filterSlice(in *Value, param *Value) (*Value, *Error) {
-	comp := strings.Split(param.String(), ":")
-	if len(comp) != 2 {
+// filterSortkeys returns the items of a map as a list of [key, value] pairs
+// sorted by key (numbers numerically, anything else by its string
+// representation). This allows a deterministic iteration over maps:
+//     {% for key, value in mymap|sortkeys %}
+func filterSortkeys(in *Value, param *Value) (*Value, *Error) {
+	rv := in.getResolvedValue()
+	if rv.Kind() != reflect.Map {
 		return nil, &Error{
-			Sender:    "filter:slice",
-			OrigError: errors.New("Slice string must have the format 'from:to' [from/to can be omitted, but the ':' is required]"),
+			Sender:    "filter:sortkeys",
+			OrigError: errors.Errorf("filter input must be a map (got: %s)", rv.Kind().String()),
 		}
 	}
 
+	keys := sortedKeys(rv.MapKeys())
+	sort.Sort(keys)
+
+	items := make([][]interface{}, 0, len(keys))
+	for _, key := range keys {
+		items = append(items, []interface{}{key.Interface(), rv.MapIndex(key).Interface()})
+	}
+	return AsValue(items), nil
+}
+
+// sliceIndices parses a Python-style slice spec ("start:stop" or
+// "start:stop:step", each part can be omitted) and returns the index of the
+// first item, the step and the number of selected items of a sequence with
+// the given length. Negative indices count from the end; bounds exceeding the
+// sequence are clamped (like in Python).
+func sliceIndices(spec string, length int) (start, step, count int, err error) {
+	comp := strings.Split(spec, ":")
+	if len(comp) < 2 || len(comp) > 3 {
+		return 0, 0, 0, errors.New("Slice string must have the format 'start:stop' or 'start:stop:step' [start/stop/step can be omitted, but the first ':' is required]")
+	}
+
+	bounds := make([]*int, 3)
+	for idx, part := range comp {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		i, err := strconv.Atoi(part)
+		if err != nil {
+			return 0, 0, 0, errors.Errorf("Slice string contains an invalid integer: '%s'", part)
+		}
+		bounds[idx] = &i
+	}
+
+	step = 1
+	if bounds[2] != nil {
+		step = *bounds[2]
+		if step == 0 {
+			return 0, 0, 0, errors.New("Slice step cannot be zero")
+		}
+	}
+
+	// adjust resolves negative indices and clamps them to [lower, upper]
+	adjust := func(i *int, def, lower, upper int) int {
+		if i == nil {
+			return def
+		}
+		v := *i
+		if v < 0 {
+			v += length
+		}
+		if v < lower {
+			return lower
+		}
+		if v > upper {
+			return upper
+		}
+		return v
+	}
+
+	var stop int
+	if step > 0 {
+		start = adjust(bounds[0], 0, 0, length)
+		stop = adjust(bounds[1], length, 0, length)
+		if stop > start {
+			count = (stop-start-1)/step + 1
+		}
+	} else {
+		// -1 means "before the first item"
+		start = adjust(bounds[0], length-1, -1, length-1)
+		stop = adjust(bounds[1], -1, -1, length-1)
+		if start > stop {
+			count = (start-stop-1)/(-step) + 1
+		}
+	}
+	return start, step, count, nil
+}
+
+// filterSlice returns a part of a string (rune-aware), slice or array using a
+// Python-style slice spec: {{ list|slice:"1:3" }}, {{ list|slice:"-2:" }} or
+// {{ list|slice:"::-1" }}. Other values are returned unchanged.
+func filterSlice(in *Value, param *Value) (*Value, *Error) {
 	if !in.CanSlice() {
+		if _, _, _, err := sliceIndices(param.String(), 0); err != nil {
+			return nil, &Error{
+				Sender:    "filter:slice",
+				OrigError: err,
+			}
+		}
 		return in, nil
 	}
 
-	from := AsValue(comp[0]).Integer()
-	to := in.Len()
+	rv := in.getResolvedValue()
+	var runes []rune
+	length := rv.Len()
+	if rv.Kind() == reflect.String {
+		runes = []rune(rv.String())
+		length = len(runes)
+	}
 
-	if from > to {
-		from = to
+	start, step, count, err := sliceIndices(param.String(), length)
+	if err != nil {
+		return nil, &Error{
+			Sender:    "filter:slice",
+			OrigError: err,
+		}
 	}
 
-	vto := AsValue(comp[1]).Integer()
-	if vto >= from && vto <= in.Len() {
-		to = vto
+	if rv.Kind() == reflect.String {
+		result := make([]rune, 0, count)
+		for i := 0; i < count; i++ {
+			result = append(result, runes[start+i*step])
+		}
+		return AsValue(string(result)), nil
 	}
 
-	return in.Slice(from, to), nil
+	if step == 1 && rv.Kind() == reflect.Slice {
+		return AsValue(rv.Slice(start, start+count).Interface()), nil
+	}
+	result := reflect.MakeSlice(reflect.SliceOf(rv.Type().Elem()), 0, count)
+	for i := 0; i < count; i++ {
+		result = reflect.Append(result, rv.Index(start+i*step))
+	}
+	return AsValue(result.Interface()), nil
 }
 
 func filterTitle(in *Value, param *Value) (*Value, *Error) {
@@ -925,3 +1836,32 @@ func filterYesno(in *Value, param *Value) (*Value, *Error) {
 	// no
 	return AsValue(choices[1]), nil
 }
+
+// zipTuple is an element of the zip-filter's output. Zipping the output of
+// the zip-filter again extends its tuples ({{ a|zip:b|zip:c }}).
+type zipTuple []interface{}
+
+func filterZip(in *Value, param *Value) (*Value, *Error) {
+	if !in.CanSlice() || !param.CanSlice() {
+		return nil, &Error{
+			Sender:    "filter:zip",
+			OrigError: errors.New("filter input and argument must be slices, arrays or strings"),
+		}
+	}
+
+	// The result is truncated to the shortest input
+	count := min(in.Len(), param.Len())
+	result := make([]zipTuple, 0, count)
+	for i := 0; i < count; i++ {
+		item := in.Index(i).Interface()
+
+		var tuple zipTuple
+		if t, ok := item.(zipTuple); ok {
+			tuple = append(zipTuple{}, t...)
+		} else {
+			tuple = zipTuple{item}
+		}
+		result = append(result, append(tuple, param.Index(i).Interface()))
+	}
+	return AsValue(result), nil
+}
@@ -1,5 +1,10 @@
 package pongo2
 
+import (
+	"bytes"
+	"sync"
+)
+
 func max(a, b int) int {
 	if a > b {
 		return a
@@ -13,3 +18,40 @@ func min(a, b int) int {
 	}
 	return b
 }
+
+// bufferPool holds the buffers tags render their body into (e. g. the
+// spaceless-tag or macros), so they can be reused by subsequent node
+// executions instead of being allocated over and over again.
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return bytes.NewBuffer(make([]byte, 0, 1024)) // 1 KiB
+	},
+}
+
+// maxPooledBufferSize prevents that a single huge output keeps its memory
+// allocated forever.
+const maxPooledBufferSize = 64 * 1024 // 64 KiB
+
+// bufferPoolDisabled disables the pool (for benchmarks only).
+var bufferPoolDisabled = false
+
+// getBuffer returns an empty buffer. Release it using putBuffer once its
+// content isn't needed anymore.
+func getBuffer() *bytes.Buffer {
+	if bufferPoolDisabled {
+		return bytes.NewBuffer(make([]byte, 0, 1024))
+	}
+	b := bufferPool.Get().(*bytes.Buffer)
+	b.Reset()
+	return b
+}
+
+// putBuffer returns the buffer to the pool. The caller must not keep any
+// reference to the buffer or its bytes (see bytes.Buffer.Bytes()); strings
+// returned by String() are copies and therefore safe to keep.
+func putBuffer(b *bytes.Buffer) {
+	if bufferPoolDisabled || b.Cap() > maxPooledBufferSize {
+		return
+	}
+	bufferPool.Put(b)
+}
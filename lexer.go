@@ -2,6 +2,7 @@ package pongo2
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 	"unicode/utf8"
 
@@ -32,10 +33,10 @@ var (
 		// 3-Char symbols
 
 		// 2-Char symbols
-		"==", ">=", "<=", "&&", "||", "{{", "}}", "{%", "%}", "!=", "<>",
+		"==", ">=", "<=", "&&", "||", "{{", "}}", "{%", "%}", "!=", "<>", "??",
 
 		// 1-Char symbol
-		"(", ")", "+", "-", "*", "<", ">", "/", "^", ",", ".", "!", "|", ":", "=", "%",
+		"(", ")", "+", "-", "*", "<", ">", "/", "^", ",", ".", "!", "|", ":", "=", "%", "~", "[", "]", "{", "}",
 	}
 
 	// Available keywords in pongo2
@@ -49,6 +50,8 @@ type Token struct {
 	Val      string
 	Line     int
 	Col      int
+	Start    int // byte offset of the token's first byte within the template
+	End      int // byte offset after the token's last byte (Start+length)
 }
 
 type lexerStateFn func() lexerStateFn
@@ -67,6 +70,53 @@ type lexer struct {
 
 	inVerbatim   bool
 	verbatimName string
+
+	rawBlock *lexerRawBlock // set while lexing the body of a raw block
+
+	trimNext bool // set by "-%}" and "-}}": the whitespace after the tag is removed
+
+	braceDepth int  // number of open dict literals within the current tag/variable
+	inVariable bool // whether the current tag/variable is a variable ({{ ... }})
+}
+
+// lexerTrimSymbols are the tag/variable delimiters with whitespace control:
+// "{%-" and "{{-" remove the whitespace before, "-%}" and "-}}" the
+// whitespace after the tag/variable (like in Jinja2).
+var lexerTrimSymbols = []string{"{%-", "{{-", "-%}", "-}}"}
+
+var (
+	// {% verbatim %} or {% verbatim name %} (and the corresponding end tags)
+	reVerbatimStart = regexp.MustCompile(`^\{%[ \t]*verbatim(?:[ \t]+([\w-]+))?[ \t]*%\}`)
+	reVerbatimEnd   = regexp.MustCompile(`^\{%[ \t]*endverbatim(?:[ \t]+([\w-]+))?[ \t]*%\}`)
+)
+
+// matchVerbatimTag returns the length and the name of the verbatim tag at the
+// beginning of input (the length is 0 if there is none).
+func matchVerbatimTag(re *regexp.Regexp, input string) (int, string) {
+	if !strings.HasPrefix(input, "{%") {
+		return 0, ""
+	}
+	m := re.FindStringSubmatch(input)
+	if m == nil {
+		return 0, ""
+	}
+	return len(m[0]), m[1]
+}
+
+// lexerRawBlock is a tag whose body is emitted as is (like verbatim), but in
+// contrast to verbatim the tags itself are kept.
+type lexerRawBlock struct {
+	start, end *regexp.Regexp
+	endTag     string // used in error messages
+}
+
+var lexerRawBlocks = []*lexerRawBlock{
+	{
+		// {% markdown raw %} (with any whitespace and whitespace control)
+		start:  regexp.MustCompile(`^\{%-?\s*markdown\s+raw\s*-?%\}`),
+		end:    regexp.MustCompile(`^\{%-?\s*endmarkdown\s*-?%\}`),
+		endTag: "{% endmarkdown %}",
+	},
 }
 
 func (t *Token) String() string {
@@ -138,9 +188,15 @@ func (l *lexer) emit(t TokenType) {
 		Val:      l.value(),
 		Line:     l.startline,
 		Col:      l.startcol,
+		Start:    l.start,
+		End:      l.pos,
 	}
 
 	if t == TokenString {
+		// The span includes the quotation marks
+		tok.Start--
+		tok.End++
+
 		// Escape sequence \" in strings
 		tok.Val = strings.Replace(tok.Val, `\"`, `"`, -1)
 		tok.Val = strings.Replace(tok.Val, `\\`, `\`, -1)
@@ -202,6 +258,8 @@ func (l *lexer) errorf(format string, args ...interface{}) lexerStateFn {
 		Val:      fmt.Sprintf(format, args...),
 		Line:     l.startline,
 		Col:      l.startcol,
+		Start:    l.start,
+		End:      l.pos,
 	}
 	l.tokens = append(l.tokens, t)
 	l.errored = true
@@ -216,35 +274,38 @@ func (l *lexer) eof() bool {
 
 func (l *lexer) run() {
 	for {
-		// TODO: Support verbatim tag names
-		// https://docs.djangoproject.com/en/dev/ref/templates/builtins/#verbatim
 		if l.inVerbatim {
-			name := l.verbatimName
-			if name != "" {
-				name += " "
-			}
-			if strings.HasPrefix(l.input[l.pos:], fmt.Sprintf("{%% endverbatim %s%%}", name)) { // end verbatim
+			// Only the endverbatim-tag with the same name closes the block, so
+			// a named block can contain other (unnamed) verbatim blocks
+			if w, name := matchVerbatimTag(reVerbatimEnd, l.input[l.pos:]); w > 0 && name == l.verbatimName { // end verbatim
 				if l.pos > l.start {
 					l.emit(TokenHTML)
 				}
-				w := len("{% endverbatim %}")
 				l.pos += w
 				l.col += w
 				l.ignore()
 				l.inVerbatim = false
+				l.verbatimName = ""
 			}
-		} else if strings.HasPrefix(l.input[l.pos:], "{% verbatim %}") { // tag
+		} else if l.rawBlock != nil {
+			if strings.HasPrefix(l.input[l.pos:], "{%") && l.rawBlock.end.MatchString(l.input[l.pos:]) {
+				if l.pos > l.start {
+					l.emit(TokenHTML)
+				}
+				l.rawBlock = nil // the end tag is lexed as usual
+			}
+		} else if w, name := matchVerbatimTag(reVerbatimStart, l.input[l.pos:]); w > 0 { // tag
 			if l.pos > l.start {
 				l.emit(TokenHTML)
 			}
 			l.inVerbatim = true
-			w := len("{% verbatim %}")
+			l.verbatimName = name
 			l.pos += w
 			l.col += w
 			l.ignore()
 		}
 
-		if !l.inVerbatim {
+		if !l.inVerbatim && l.rawBlock == nil {
 			// Ignore single-line comments {# ... #}
 			if strings.HasPrefix(l.input[l.pos:], "{#") {
 				if l.pos > l.start {
@@ -273,6 +334,9 @@ func (l *lexer) run() {
 					l.next()
 				}
 				l.ignore() // ignore whole comment
+				if l.trimNext {
+					l.skipWhitespace()
+				}
 
 				// Comment skipped
 				continue // next token
@@ -283,10 +347,21 @@ func (l *lexer) run() {
 				if l.pos > l.start {
 					l.emit(TokenHTML)
 				}
+				var rawBlock *lexerRawBlock
+				for _, block := range lexerRawBlocks {
+					if block.start.MatchString(l.input[l.pos:]) {
+						rawBlock = block
+						break
+					}
+				}
 				l.tokenize()
 				if l.errored {
 					return
 				}
+				l.rawBlock = rawBlock
+				if l.trimNext {
+					l.skipWhitespace()
+				}
 				continue
 			}
 		}
@@ -306,16 +381,42 @@ func (l *lexer) run() {
 	}
 
 	if l.inVerbatim {
-		l.errorf("verbatim-tag not closed, got EOF.")
+		if l.verbatimName != "" {
+			l.errorf("verbatim-tag '%s' not closed, got EOF.", l.verbatimName)
+		} else {
+			l.errorf("verbatim-tag not closed, got EOF.")
+		}
+	}
+	if l.rawBlock != nil {
+		l.errorf("Raw block not closed (expected '%s'), got EOF.", l.rawBlock.endTag)
 	}
 }
 
 func (l *lexer) tokenize() {
+	l.braceDepth = 0
+	l.inVariable = strings.HasPrefix(l.input[l.pos:], "{{")
 	for state := l.stateCode; state != nil; {
 		state = state()
 	}
 }
 
+// endsUnclosedVariable reports whether the "}}" at the current position ends a
+// variable in which not all dict literals are closed (like {{ {"a": 1 }}). This
+// is the case if the rest of the line (up to the next tag or variable) has too
+// few closing braces left for both the open dict literals and the variable.
+func (l *lexer) endsUnclosedVariable() bool {
+	rest := l.input[l.start:]
+	if !l.inVariable || !strings.HasPrefix(rest, "}}") {
+		return false
+	}
+	for _, end := range []string{"\n", "{{", "{%"} {
+		if idx := strings.Index(rest, end); idx >= 0 {
+			rest = rest[:idx]
+		}
+	}
+	return strings.Count(rest, "}") < l.braceDepth+2
+}
+
 func (l *lexer) stateCode() lexerStateFn {
 outer_loop:
 	for {
@@ -334,6 +435,35 @@ outer_loop:
 			return l.stateString
 		}
 
+		// Within a dict literal, "}}" closes two of them (e. g. {"a": {"b": 1}})
+		// unless it's the end of a variable with an unclosed dict literal
+		if l.braceDepth > 0 && strings.HasPrefix(l.input[l.start:], "}") && !l.endsUnclosedVariable() {
+			l.pos++
+			l.col++
+			l.braceDepth--
+			l.emit(TokenSymbol)
+			continue
+		}
+
+		// Check for a delimiter with whitespace control; it's emitted
+		// without the '-'
+		for _, sym := range lexerTrimSymbols {
+			if strings.HasPrefix(l.input[l.start:], sym) {
+				l.pos += len(sym)
+				l.col += l.length()
+				if sym[0] == '-' {
+					l.emit(TokenSymbol)
+					l.tokens[len(l.tokens)-1].Val = sym[1:]
+					l.trimNext = true
+					return nil // Tag/variable end
+				}
+				l.trimTrailingHTML()
+				l.emit(TokenSymbol)
+				l.tokens[len(l.tokens)-1].Val = sym[:2]
+				continue outer_loop
+			}
+		}
+
 		// Check for symbol
 		for _, sym := range TokenSymbols {
 			if strings.HasPrefix(l.input[l.start:], sym) {
@@ -341,6 +471,9 @@ outer_loop:
 				l.col += l.length()
 				l.emit(TokenSymbol)
 
+				if sym == "{" {
+					l.braceDepth++
+				}
 				if sym == "%}" || sym == "}}" {
 					// Tag/variable end, return after emit
 					return nil
@@ -357,6 +490,37 @@ outer_loop:
 	return nil
 }
 
+// trimTrailingHTML removes the trailing whitespace of the HTML tokens lexed
+// right before the current tag/variable (which might be several tokens, e. g.
+// if separated by comments). Tokens consisting of whitespace only are removed.
+func (l *lexer) trimTrailingHTML() {
+	for len(l.tokens) > 0 {
+		tok := l.tokens[len(l.tokens)-1]
+		if tok.Typ != TokenHTML {
+			return
+		}
+		tok.Val = strings.TrimRight(tok.Val, tokenSpaceChars)
+		tok.End = tok.Start + len(tok.Val)
+		if tok.Val != "" {
+			return
+		}
+		l.tokens = l.tokens[:len(l.tokens)-1]
+	}
+}
+
+// skipWhitespace ignores the whitespace following the current position (for
+// "-%}" and "-}}"). Trimming continues after a comment.
+func (l *lexer) skipWhitespace() {
+	for l.pos < len(l.input) && strings.IndexByte(tokenSpaceChars, l.input[l.pos]) >= 0 {
+		if l.next() == '\n' {
+			l.line++
+			l.col = 1
+		}
+	}
+	l.ignore()
+	l.trimNext = strings.HasPrefix(l.input[l.pos:], "{#")
+}
+
 func (l *lexer) stateIdentifier() lexerStateFn {
 	l.acceptRun(tokenIdentifierChars)
 	l.acceptRun(tokenIdentifierCharsWithDigits)
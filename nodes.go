@@ -7,10 +7,36 @@ type nodeDocument struct {
 
 func (doc *nodeDocument) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
 	for _, n := range doc.Nodes {
-		err := n.Execute(ctx, writer)
+		err := executeNode(n, ctx, writer)
 		if err != nil {
 			return err
 		}
+		flushWriter(writer)
 	}
 	return nil
 }
+
+// errorRecovery holds the state of the error-recovery mode during an execution
+// (see TemplateSet.ErrorPlaceholder).
+type errorRecovery struct {
+	placeholder func(err *Error) string
+	errors      RecoveredErrors
+}
+
+// executeNode executes a single node of a document or a wrapper. In error-recovery
+// mode the node's output is buffered and replaced by the placeholder on error.
+func executeNode(n INode, ctx *ExecutionContext, writer TemplateWriter) *Error {
+	if ctx.recovery == nil {
+		return n.Execute(ctx, writer)
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := n.Execute(ctx, buf); err != nil {
+		ctx.recovery.errors = append(ctx.recovery.errors, err)
+		writer.WriteString(ctx.recovery.placeholder(err))
+		return nil
+	}
+	writer.Write(buf.Bytes())
+	return nil
+}
@@ -7,7 +7,7 @@ type NodeWrapper struct {
 
 func (wrapper *NodeWrapper) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
 	for _, n := range wrapper.nodes {
-		err := n.Execute(ctx, writer)
+		err := executeNode(n, ctx, writer)
 		if err != nil {
 			return err
 		}
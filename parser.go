@@ -36,6 +36,11 @@ type Parser struct {
 	// if the parser parses a template document, here will be
 	// a reference to it (needed to access the template through Tags)
 	template *Template
+
+	// Assignment expressions ("(name := expr)") are only allowed if set (by
+	// the if-tag); assigned reports whether the parser parsed one
+	allowAssignments bool
+	assigned         bool
 }
 
 // Creates a new parser to parse tokens.
@@ -213,6 +218,16 @@ func (p *Parser) Error(msg string, token *Token) *Error {
 func (p *Parser) WrapUntilTag(names ...string) (*NodeWrapper, *Parser, *Error) {
 	wrapper := &NodeWrapper{}
 
+	// Filter aliases defined within the wrapped block are only valid inside it
+	if p.template != nil {
+		aliases := p.template.filterAliases
+		p.template.filterAliases = make(map[string][]*filterCall, len(aliases))
+		for name, chain := range aliases {
+			p.template.filterAliases[name] = chain
+		}
+		defer func() { p.template.filterAliases = aliases }()
+	}
+
 	var tagArgs []*Token
 
 	for p.Remaining() > 0 {
@@ -1,5 +1,7 @@
 package pongo2
 
+import "bytes"
+
 // Doc = { ( Filter | Tag | HTML ) }
 func (p *Parser) parseDocElement() (INode, *Error) {
 	t := p.Current()
@@ -36,6 +38,20 @@ func (tpl *Template) parse() *Error {
 		return err
 	}
 	tpl.root = doc
+
+	// Templates consisting of plain HTML only are rendered without
+	// executing any nodes (see Template.execute)
+	var static bytes.Buffer
+	for _, n := range doc.Nodes {
+		html, ok := n.(*nodeHTML)
+		if !ok {
+			return nil
+		}
+		static.WriteString(html.token.Val)
+	}
+	tpl.static = true
+	tpl.staticContent = static.String()
+
 	return nil
 }
 
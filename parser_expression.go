@@ -3,6 +3,9 @@ package pongo2
 import (
 	"fmt"
 	"math"
+	"reflect"
+	"strconv"
+	"strings"
 )
 
 type Expression struct {
@@ -17,6 +20,54 @@ type relationalExpression struct {
 	expr1   IEvaluator
 	expr2   IEvaluator
 	opToken *Token
+	negate  bool // for "not in"
+}
+
+type concatExpression struct {
+	expr1   IEvaluator
+	expr2   IEvaluator
+	opToken *Token
+}
+
+// coalesceExpression evaluates to the first operand which isn't nil (`a ?? b`);
+// unlike `or` and the default-filter, falsy values like "" or 0 are kept.
+type coalesceExpression struct {
+	expr1   IEvaluator
+	expr2   IEvaluator
+	opToken *Token
+}
+
+// assignExpression binds the value of an expression to a variable and
+// evaluates to that value (`(name := expr)`, if-conditions only).
+type assignExpression struct {
+	nameToken *Token
+	expr      IEvaluator
+}
+
+// isExpression applies a test like `empty` to a value (`x is empty`
+// or `x is not empty`).
+type isExpression struct {
+	expr    IEvaluator
+	test    func(*Value) bool
+	negate  bool
+	opToken *Token
+}
+
+// isTests contains the tests available for the is-operator
+var isTests = map[string]func(*Value) bool{
+	"empty": isTestEmpty,
+}
+
+// isTestEmpty checks whether a value is nil or an empty string, slice, array
+// or map. Unlike `not x`, numbers (including 0), bools and structs are never empty.
+func isTestEmpty(v *Value) bool {
+	switch v.getResolvedValue().Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.String, reflect.Array, reflect.Slice, reflect.Map:
+		return v.getResolvedValue().Len() == 0
+	}
+	return false
 }
 
 type simpleExpression struct {
@@ -27,6 +78,12 @@ type simpleExpression struct {
 	opToken      *Token
 }
 
+type unaryExpression struct {
+	negative bool
+	factor   IEvaluator
+	opToken  *Token
+}
+
 type term struct {
 	// TODO: Add location token?
 	factor1 IEvaluator
@@ -40,6 +97,13 @@ type power struct {
 	power2 IEvaluator
 }
 
+// constantExpression is the result of folding an expression which only
+// consists of literals (like `2 + 3 * 4`) at parse time.
+type constantExpression struct {
+	locationToken *Token
+	value         *Value
+}
+
 func (expr *Expression) FilterApplied(name string) bool {
 	return expr.expr1.FilterApplied(name) && (expr.expr2 == nil ||
 		(expr.expr2 != nil && expr.expr2.FilterApplied(name)))
@@ -50,11 +114,31 @@ func (expr *relationalExpression) FilterApplied(name string) bool {
 		(expr.expr2 != nil && expr.expr2.FilterApplied(name)))
 }
 
+func (expr *concatExpression) FilterApplied(name string) bool {
+	return expr.expr1.FilterApplied(name) && expr.expr2.FilterApplied(name)
+}
+
+func (expr *coalesceExpression) FilterApplied(name string) bool {
+	return expr.expr1.FilterApplied(name) && expr.expr2.FilterApplied(name)
+}
+
+func (expr *isExpression) FilterApplied(name string) bool {
+	return false
+}
+
+func (expr *assignExpression) FilterApplied(name string) bool {
+	return expr.expr.FilterApplied(name)
+}
+
 func (expr *simpleExpression) FilterApplied(name string) bool {
 	return expr.term1.FilterApplied(name) && (expr.term2 == nil ||
 		(expr.term2 != nil && expr.term2.FilterApplied(name)))
 }
 
+func (expr *unaryExpression) FilterApplied(name string) bool {
+	return expr.factor.FilterApplied(name)
+}
+
 func (expr *term) FilterApplied(name string) bool {
 	return expr.factor1.FilterApplied(name) && (expr.factor2 == nil ||
 		(expr.factor2 != nil && expr.factor2.FilterApplied(name)))
@@ -65,6 +149,10 @@ func (expr *power) FilterApplied(name string) bool {
 		(expr.power2 != nil && expr.power2.FilterApplied(name)))
 }
 
+func (expr *constantExpression) FilterApplied(name string) bool {
+	return false
+}
+
 func (expr *Expression) GetPositionToken() *Token {
 	return expr.expr1.GetPositionToken()
 }
@@ -73,10 +161,30 @@ func (expr *relationalExpression) GetPositionToken() *Token {
 	return expr.expr1.GetPositionToken()
 }
 
+func (expr *concatExpression) GetPositionToken() *Token {
+	return expr.expr1.GetPositionToken()
+}
+
+func (expr *coalesceExpression) GetPositionToken() *Token {
+	return expr.opToken
+}
+
+func (expr *isExpression) GetPositionToken() *Token {
+	return expr.expr.GetPositionToken()
+}
+
+func (expr *assignExpression) GetPositionToken() *Token {
+	return expr.nameToken
+}
+
 func (expr *simpleExpression) GetPositionToken() *Token {
 	return expr.term1.GetPositionToken()
 }
 
+func (expr *unaryExpression) GetPositionToken() *Token {
+	return expr.opToken
+}
+
 func (expr *term) GetPositionToken() *Token {
 	return expr.factor1.GetPositionToken()
 }
@@ -85,6 +193,10 @@ func (expr *power) GetPositionToken() *Token {
 	return expr.power1.GetPositionToken()
 }
 
+func (expr *constantExpression) GetPositionToken() *Token {
+	return expr.locationToken
+}
+
 func (expr *Expression) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
 	value, err := expr.Evaluate(ctx)
 	if err != nil {
@@ -103,6 +215,42 @@ func (expr *relationalExpression) Execute(ctx *ExecutionContext, writer Template
 	return nil
 }
 
+func (expr *concatExpression) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	value, err := expr.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+	writer.WriteString(value.String())
+	return nil
+}
+
+func (expr *coalesceExpression) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	value, err := expr.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+	writer.WriteString(value.String())
+	return nil
+}
+
+func (expr *isExpression) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	value, err := expr.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+	writer.WriteString(value.String())
+	return nil
+}
+
+func (expr *assignExpression) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	value, err := expr.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+	writer.WriteString(value.String())
+	return nil
+}
+
 func (expr *simpleExpression) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
 	value, err := expr.Evaluate(ctx)
 	if err != nil {
@@ -112,6 +260,15 @@ func (expr *simpleExpression) Execute(ctx *ExecutionContext, writer TemplateWrit
 	return nil
 }
 
+func (expr *unaryExpression) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	value, err := expr.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+	writer.WriteString(value.String())
+	return nil
+}
+
 func (expr *term) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
 	value, err := expr.Evaluate(ctx)
 	if err != nil {
@@ -130,6 +287,11 @@ func (expr *power) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error
 	return nil
 }
 
+func (expr *constantExpression) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	writer.WriteString(expr.value.String())
+	return nil
+}
+
 func (expr *Expression) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
 	v1, err := expr.expr1.Evaluate(ctx)
 	if err != nil {
@@ -164,6 +326,15 @@ func (expr *relationalExpression) Evaluate(ctx *ExecutionContext) (*Value, *Erro
 			return nil, err
 		}
 		switch expr.opToken.Val {
+		case "<=", ">=", ">", "<":
+			if v1, err = numericOperand(ctx, v1, expr.opToken.Val, expr.opToken); err != nil {
+				return nil, err
+			}
+			if v2, err = numericOperand(ctx, v2, expr.opToken.Val, expr.opToken); err != nil {
+				return nil, err
+			}
+		}
+		switch expr.opToken.Val {
 		case "<=":
 			if v1.IsFloat() || v2.IsFloat() {
 				return AsValue(v1.Float() <= v2.Float()), nil
@@ -189,7 +360,7 @@ func (expr *relationalExpression) Evaluate(ctx *ExecutionContext) (*Value, *Erro
 		case "!=", "<>":
 			return AsValue(!v1.EqualValueTo(v2)), nil
 		case "in":
-			return AsValue(v2.Contains(v1)), nil
+			return AsValue(v2.Contains(v1) != expr.negate), nil
 		default:
 			return nil, ctx.Error(fmt.Sprintf("unimplemented: %s", expr.opToken.Val), expr.opToken)
 		}
@@ -198,6 +369,48 @@ func (expr *relationalExpression) Evaluate(ctx *ExecutionContext) (*Value, *Erro
 	}
 }
 
+func (expr *concatExpression) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
+	v1, err := expr.expr1.Evaluate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	v2, err := expr.expr2.Evaluate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Both operands are converted to strings (numbers included)
+	return AsValue(v1.String() + v2.String()), nil
+}
+
+func (expr *coalesceExpression) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
+	v1, err := expr.expr1.Evaluate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !v1.IsNil() {
+		// The other operand isn't evaluated at all
+		return v1, nil
+	}
+	return expr.expr2.Evaluate(ctx)
+}
+
+func (expr *assignExpression) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
+	value, err := expr.expr.Evaluate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx.Private[expr.nameToken.Val] = value
+	return value, nil
+}
+
+func (expr *isExpression) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
+	value, err := expr.expr.Evaluate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return AsValue(expr.test(value) != expr.negate), nil
+}
+
 func (expr *simpleExpression) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
 	t1, err := expr.term1.Evaluate(ctx)
 	if err != nil {
@@ -210,18 +423,14 @@ func (expr *simpleExpression) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
 	}
 
 	if expr.negativeSign {
-		if result.IsNumber() {
-			switch {
-			case result.IsFloat():
-				result = AsValue(-1 * result.Float())
-			case result.IsInteger():
-				result = AsValue(-1 * result.Integer())
-			default:
-				return nil, ctx.Error("Operation between a number and a non-(float/integer) is not possible", nil)
-			}
-		} else {
+		if result, err = numericOperand(ctx, result, "-", expr.GetPositionToken()); err != nil {
+			return nil, err
+		}
+		negated, err := result.NegateNumber()
+		if err != nil {
 			return nil, ctx.Error("Negative sign on a non-number expression", expr.GetPositionToken())
 		}
+		result = negated
 	}
 
 	if expr.term2 != nil {
@@ -229,6 +438,12 @@ func (expr *simpleExpression) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
 		if err != nil {
 			return nil, err
 		}
+		if result, err = numericOperand(ctx, result, expr.opToken.Val, expr.opToken); err != nil {
+			return nil, err
+		}
+		if t2, err = numericOperand(ctx, t2, expr.opToken.Val, expr.opToken); err != nil {
+			return nil, err
+		}
 		switch expr.opToken.Val {
 		case "+":
 			if result.IsFloat() || t2.IsFloat() {
@@ -252,6 +467,32 @@ func (expr *simpleExpression) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
 	return result, nil
 }
 
+func (expr *unaryExpression) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
+	result, err := expr.factor.Evaluate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if result, err = numericOperand(ctx, result, expr.opToken.Val, expr.opToken); err != nil {
+		return nil, err
+	}
+
+	if !result.IsNumber() {
+		if expr.negative {
+			return nil, ctx.Error("Negative sign on a non-number expression", expr.opToken)
+		}
+		return nil, ctx.Error("Positive sign on a non-number expression", expr.opToken)
+	}
+
+	if expr.negative {
+		negated, err := result.NegateNumber()
+		if err != nil {
+			return nil, ctx.OrigError(err, expr.opToken)
+		}
+		return negated, nil
+	}
+	return result, nil
+}
+
 func (expr *term) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
 	f1, err := expr.factor1.Evaluate(ctx)
 	if err != nil {
@@ -262,6 +503,12 @@ func (expr *term) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
 		if err != nil {
 			return nil, err
 		}
+		if f1, err = numericOperand(ctx, f1, expr.opToken.Val, expr.opToken); err != nil {
+			return nil, err
+		}
+		if f2, err = numericOperand(ctx, f2, expr.opToken.Val, expr.opToken); err != nil {
+			return nil, err
+		}
 		switch expr.opToken.Val {
 		case "*":
 			if f1.IsFloat() || f2.IsFloat() {
@@ -276,9 +523,15 @@ func (expr *term) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
 				return AsValue(f1.Float() / f2.Float()), nil
 			}
 			// Result will be int
+			if f2.Integer() == 0 {
+				return nil, ctx.Error("Cannot apply '/': division by zero.", expr.opToken)
+			}
 			return AsValue(f1.Integer() / f2.Integer()), nil
 		case "%":
 			// Result will be int
+			if f2.Integer() == 0 {
+				return nil, ctx.Error("Cannot apply '%': division by zero.", expr.opToken)
+			}
 			return AsValue(f1.Integer() % f2.Integer()), nil
 		default:
 			return nil, ctx.Error("unimplemented", expr.opToken)
@@ -298,13 +551,115 @@ func (expr *power) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
 		if err != nil {
 			return nil, err
 		}
+		if p1, err = numericOperand(ctx, p1, "^", expr.GetPositionToken()); err != nil {
+			return nil, err
+		}
+		if p2, err = numericOperand(ctx, p2, "^", expr.GetPositionToken()); err != nil {
+			return nil, err
+		}
 		return AsValue(math.Pow(p1.Float(), p2.Float())), nil
 	}
 	return p1, nil
 }
 
+// numericOperand prepares an operand of an arithmetic operator or an ordering
+// comparison: numeric strings (e. g. form values) are converted to an integer
+// or a float. If the template set uses StrictNumbers, strings are an error.
+func numericOperand(ctx *ExecutionContext, v *Value, op string, token *Token) (*Value, *Error) {
+	if !v.IsString() {
+		return v, nil
+	}
+	if ctx.template != nil && ctx.template.set.StrictNumbers {
+		return nil, ctx.Error(fmt.Sprintf("Operator '%s' requires numbers, got the string '%s' (StrictNumbers is enabled).",
+			op, v.String()), token)
+	}
+
+	s := strings.TrimSpace(v.String())
+	if i, err := strconv.Atoi(s); err == nil {
+		return AsValue(i), nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return AsValue(f), nil
+	}
+	return v, nil
+}
+
+func (expr *constantExpression) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
+	return expr.value, nil
+}
+
+// isConstant reports whether expr only consists of literals and operators
+// (filters and function calls are never considered to be constant).
+func isConstant(expr IEvaluator) bool {
+	switch e := expr.(type) {
+	case *stringResolver, *intResolver, *floatResolver, *boolResolver, *constantExpression:
+		return true
+	case *nodeFilteredVariable:
+		return len(e.filterChain) == 0 && isConstant(e.resolver)
+	case *Expression:
+		return isConstant(e.expr1) && (e.expr2 == nil || isConstant(e.expr2))
+	case *relationalExpression:
+		return isConstant(e.expr1) && (e.expr2 == nil || isConstant(e.expr2))
+	case *concatExpression:
+		return isConstant(e.expr1) && isConstant(e.expr2)
+	case *coalesceExpression:
+		return isConstant(e.expr1) && isConstant(e.expr2)
+	case *simpleExpression:
+		return isConstant(e.term1) && (e.term2 == nil || isConstant(e.term2))
+	case *unaryExpression:
+		return isConstant(e.factor)
+	case *term:
+		return isConstant(e.factor1) && (e.factor2 == nil || isConstant(e.factor2))
+	case *power:
+		return isConstant(e.power1) && (e.power2 == nil || isConstant(e.power2))
+	}
+	return false
+}
+
+// foldConstant replaces a constant expression by its value. Expressions which
+// fail to evaluate (e. g. a division by zero) are left untouched, so the error
+// is still raised on execution.
+func (p *Parser) foldConstant(expr IEvaluator) IEvaluator {
+	if p.template == nil || !isConstant(expr) {
+		return expr
+	}
+	if _, ok := expr.(*constantExpression); ok {
+		return expr
+	}
+
+	value, err := expr.Evaluate(&ExecutionContext{template: p.template})
+	if err != nil {
+		return expr
+	}
+	return &constantExpression{
+		locationToken: expr.GetPositionToken(),
+		value:         value,
+	}
+}
+
 func (p *Parser) parseFactor() (IEvaluator, *Error) {
+	// Unary plus/minus (e. g. "5 * -x" or "--x")
+	if sign := p.MatchOne(TokenSymbol, "+", "-"); sign != nil {
+		factor, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		return p.foldConstant(&unaryExpression{
+			negative: sign.Val == "-",
+			factor:   factor,
+			opToken:  sign,
+		}), nil
+	}
+
 	if p.Match(TokenSymbol, "(") != nil {
+		// Assignment expression: "(" IDENT ":=" Expression ")" (no whitespace
+		// allowed between ':' and '=')
+		if colon := p.PeekN(1, TokenSymbol, ":"); colon != nil && p.PeekTypeN(0, TokenIdentifier) != nil {
+			if eq := p.PeekN(2, TokenSymbol, "="); eq != nil && colon.End == eq.Start {
+				return p.parseAssignExpression()
+			}
+		}
+
 		expr, err := p.ParseExpression()
 		if err != nil {
 			return nil, err
@@ -318,6 +673,29 @@ func (p *Parser) parseFactor() (IEvaluator, *Error) {
 	return p.parseVariableOrLiteralWithFilter()
 }
 
+// parseAssignExpression parses the part after "(" of an assignment expression.
+func (p *Parser) parseAssignExpression() (IEvaluator, *Error) {
+	if !p.allowAssignments {
+		return nil, p.Error("Assignment expressions (':=') are only allowed within if-conditions.", nil)
+	}
+
+	nameToken := p.MatchType(TokenIdentifier)
+	p.ConsumeN(2) // ':='
+	expr, err := p.ParseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if p.Match(TokenSymbol, ")") == nil {
+		return nil, p.Error("Closing bracket expected after assignment expression", nil)
+	}
+
+	p.assigned = true
+	return &assignExpression{
+		nameToken: nameToken,
+		expr:      expr,
+	}, nil
+}
+
 func (p *Parser) parsePower() (IEvaluator, *Error) {
 	pw := new(power)
 
@@ -340,7 +718,7 @@ func (p *Parser) parsePower() (IEvaluator, *Error) {
 		return pw.power1, nil
 	}
 
-	return pw, nil
+	return p.foldConstant(pw), nil
 }
 
 func (p *Parser) parseTerm() (IEvaluator, *Error) {
@@ -377,7 +755,7 @@ func (p *Parser) parseTerm() (IEvaluator, *Error) {
 		return returnTerm.factor1, nil
 	}
 
-	return returnTerm, nil
+	return p.foldConstant(returnTerm), nil
 }
 
 func (p *Parser) parseSimpleExpression() (IEvaluator, *Error) {
@@ -424,15 +802,63 @@ func (p *Parser) parseSimpleExpression() (IEvaluator, *Error) {
 		return expr.term1, nil
 	}
 
+	return p.foldConstant(expr), nil
+}
+
+// The concatenation operator (~) binds weaker than the arithmetic operators,
+// so `"total: " ~ 2 + 3` is "total: 5".
+func (p *Parser) parseConcatExpression() (IEvaluator, *Error) {
+	expr, err := p.parseSimpleExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.Peek(TokenSymbol, "~") != nil {
+		op := p.Current()
+		p.Consume()
+
+		expr2, err := p.parseSimpleExpression()
+		if err != nil {
+			return nil, err
+		}
+
+		expr = p.foldConstant(&concatExpression{
+			expr1:   expr,
+			expr2:   expr2,
+			opToken: op,
+		})
+	}
+
 	return expr, nil
 }
 
 func (p *Parser) parseRelationalExpression() (IEvaluator, *Error) {
-	expr1, err := p.parseSimpleExpression()
+	expr1, err := p.parseConcatExpression()
 	if err != nil {
 		return nil, err
 	}
 
+	// Tests like `x is empty` or `x is not empty`
+	if t := p.Match(TokenIdentifier, "is"); t != nil {
+		isExpr := &isExpression{
+			expr:    expr1,
+			opToken: t,
+		}
+		if p.Match(TokenKeyword, "not") != nil {
+			isExpr.negate = true
+		}
+		testToken := p.MatchType(TokenIdentifier)
+		if testToken == nil {
+			return nil, p.Error("Expected a test name (like 'empty') after 'is'.", nil)
+		}
+		test, has := isTests[testToken.Val]
+		if !has {
+			return nil, p.Error(fmt.Sprintf("Unknown test '%s'.", testToken.Val), testToken)
+		}
+		isExpr.test = test
+		return isExpr, nil
+	}
+
 	expr := &relationalExpression{
 		expr1: expr1,
 	}
@@ -445,12 +871,24 @@ func (p *Parser) parseRelationalExpression() (IEvaluator, *Error) {
 		expr.opToken = t
 		expr.expr2 = expr2
 	} else if t := p.MatchOne(TokenKeyword, "in"); t != nil {
-		expr2, err := p.parseSimpleExpression()
+		expr2, err := p.parseConcatExpression()
 		if err != nil {
 			return nil, err
 		}
 		expr.opToken = t
 		expr.expr2 = expr2
+	} else if p.Peek(TokenKeyword, "not") != nil && p.PeekN(1, TokenKeyword, "in") != nil {
+		// "not in" is a single operator (same precedence as "in")
+		p.Consume() // not
+		t := p.Current()
+		p.Consume() // in
+		expr2, err := p.parseConcatExpression()
+		if err != nil {
+			return nil, err
+		}
+		expr.opToken = t
+		expr.expr2 = expr2
+		expr.negate = true
 	}
 
 	if expr.expr2 == nil {
@@ -458,10 +896,54 @@ func (p *Parser) parseRelationalExpression() (IEvaluator, *Error) {
 		return expr.expr1, nil
 	}
 
-	return expr, nil
+	return p.foldConstant(expr), nil
 }
 
+// ParseExpression parses an expression (like `user.age >= 18 and not banned`,
+// including filters and function calls) starting at the current token and
+// consumes its tokens. The returned IEvaluator can be stored in your tag's
+// node and evaluated on execution:
+//
+//     func myTagParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
+//         expr, err := arguments.ParseExpression()
+//         if err != nil {
+//             return nil, err
+//         }
+//         return &myTagNode{expr: expr}, nil
+//     }
+//
+//     func (node *myTagNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+//         value, err := node.expr.Evaluate(ctx)
+//         ...
+//     }
+//
+// Parsing stops at the first token which can't continue the expression, so
+// it's up to the caller to check for remaining (unexpected) tokens.
 func (p *Parser) ParseExpression() (IEvaluator, *Error) {
+	// The null-coalescing operator (??) binds weakest, so
+	// `a or b ?? c` is `(a or b) ?? c`
+	expr, err := p.parseLogicalExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	if op := p.Match(TokenSymbol, "??"); op != nil {
+		expr2, err := p.ParseExpression()
+		if err != nil {
+			return nil, err
+		}
+		return p.foldConstant(&coalesceExpression{
+			expr1:   expr,
+			expr2:   expr2,
+			opToken: op,
+		}), nil
+	}
+
+	return expr, nil
+}
+
+// parseLogicalExpression parses the operators and/&& and or/||.
+func (p *Parser) parseLogicalExpression() (IEvaluator, *Error) {
 	rexpr1, err := p.parseRelationalExpression()
 	if err != nil {
 		return nil, err
@@ -474,7 +956,7 @@ func (p *Parser) ParseExpression() (IEvaluator, *Error) {
 	if p.PeekOne(TokenSymbol, "&&", "||") != nil || p.PeekOne(TokenKeyword, "and", "or") != nil {
 		op := p.Current()
 		p.Consume()
-		expr2, err := p.ParseExpression()
+		expr2, err := p.parseLogicalExpression()
 		if err != nil {
 			return nil, err
 		}
@@ -487,5 +969,5 @@ func (p *Parser) ParseExpression() (IEvaluator, *Error) {
 		return exp.expr1, nil
 	}
 
-	return exp, nil
+	return p.foldConstant(exp), nil
 }
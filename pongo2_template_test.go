@@ -399,6 +399,57 @@ func TestBaseDirectory(t *testing.T) {
 	}
 }
 
+const staticTemplate = "<html>\n<head><title>Static</title></head>\n<body>\n<p>Hello world!</p>\n</body>\n</html>\n"
+
+func TestStaticTemplate(t *testing.T) {
+	tpl, err := pongo2.FromString(staticTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := tpl.Execute(tplContext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != staticTemplate {
+		t.Errorf("Execute: got '%s', expected '%s'", out, staticTemplate)
+	}
+
+	outBytes, err := tpl.ExecuteBytes(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(outBytes) != staticTemplate {
+		t.Errorf("ExecuteBytes: got '%s', expected '%s'", outBytes, staticTemplate)
+	}
+
+	var buf bytes.Buffer
+	if err := tpl.ExecuteWriter(nil, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != staticTemplate {
+		t.Errorf("ExecuteWriter: got '%s', expected '%s'", buf.String(), staticTemplate)
+	}
+
+	// The context is still validated
+	if _, err := tpl.Execute(pongo2.Context{"invalid-name": 1}); err == nil {
+		t.Errorf("Execute with an invalid context key should fail")
+	}
+
+	// Comments don't make a template dynamic
+	tpl, err = pongo2.FromString("<p>{# comment #}Hello</p>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err = tpl.Execute(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "<p>Hello</p>" {
+		t.Errorf("got '%s', expected '<p>Hello</p>'", out)
+	}
+}
+
 func BenchmarkCache(b *testing.B) {
 	cacheSet := pongo2.NewSet("cache set", pongo2.MustNewLocalFileSystemLoader(""))
 	for i := 0; i < b.N; i++ {
@@ -428,6 +479,34 @@ func BenchmarkCacheDebugOn(b *testing.B) {
 	}
 }
 
+func benchmarkIncludeInLoop(b *testing.B, debug bool) {
+	s := pongo2.NewSet("include loop set", pongo2.MustNewLocalFileSystemLoader(""))
+	s.Debug = debug // debug mode disables the template cache
+	tpl, err := s.FromString("{% for i in items %}{% include name with what_am_i=i %}{% endfor %}")
+	if err != nil {
+		b.Fatal(err)
+	}
+	ctx := pongo2.Context{
+		"name":  "template_tests/includes.helper",
+		"items": make([]int, 1000),
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err = tpl.ExecuteWriterUnbuffered(ctx, ioutil.Discard)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkIncludeInLoopCached(b *testing.B) {
+	benchmarkIncludeInLoop(b, false)
+}
+
+func BenchmarkIncludeInLoopUncached(b *testing.B) {
+	benchmarkIncludeInLoop(b, true)
+}
+
 func BenchmarkExecuteComplexWithSandboxActive(b *testing.B) {
 	tpl, err := pongo2.FromFile("template_tests/complex.tpl")
 	if err != nil {
@@ -442,6 +521,63 @@ func BenchmarkExecuteComplexWithSandboxActive(b *testing.B) {
 	}
 }
 
+func BenchmarkExecuteStatic(b *testing.B) {
+	tpl, err := pongo2.FromString(staticTemplate)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err = tpl.Execute(tplContext)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkExecuteStaticNoFastPath(b *testing.B) {
+	// The (empty) comment-tag prevents the static fast path
+	tpl, err := pongo2.FromString(staticTemplate + "{% comment %}{% endcomment %}")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err = tpl.Execute(tplContext)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkExpressionInLoop(b *testing.B, expr string) {
+	tpl, err := pongo2.FromString("{% for i in items %}{{ " + expr + " }}{% endfor %}")
+	if err != nil {
+		b.Fatal(err)
+	}
+	ctx := pongo2.Context{
+		"items": make([]int, 100),
+		"two":   2,
+		"three": 3,
+		"four":  4,
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err = tpl.ExecuteWriterUnbuffered(ctx, ioutil.Discard)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkConstantExpressionFolded(b *testing.B) {
+	benchmarkExpressionInLoop(b, "(2 + 3) * 4 - 2 ^ 3 > 10 and 4 % 3 == 1")
+}
+
+func BenchmarkConstantExpressionUnfolded(b *testing.B) {
+	benchmarkExpressionInLoop(b, "(two + three) * four - two ^ three > 10 and four % three == 1")
+}
+
 func BenchmarkCompileAndExecuteComplexWithSandboxActive(b *testing.B) {
 	buf, err := ioutil.ReadFile("template_tests/complex.tpl")
 	if err != nil {
@@ -532,3 +668,127 @@ func BenchmarkParallelExecuteComplexWithoutSandbox(b *testing.B) {
 		}
 	})
 }
+
+// The regex-filters compile each pattern only once per template set; compare
+// with BenchmarkRegexReplaceCompileEachTime (the cost without caching).
+func BenchmarkRegexReplaceCached(b *testing.B) {
+	s := pongo2.NewSet("regex", pongo2.MustNewLocalFileSystemLoader(""))
+	tpl, err := s.FromString(`{{ text|regex_replace:"(\\w+)@(\\w+)\\.com,$1 at $2" }}`)
+	if err != nil {
+		b.Fatal(err)
+	}
+	ctx := pongo2.Context{"text": "mail john@example.com or jane@example.com"}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err = tpl.ExecuteWriterUnbuffered(ctx, ioutil.Discard)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRegexReplaceCompileEachTime(b *testing.B) {
+	text := "mail john@example.com or jane@example.com"
+	for i := 0; i < b.N; i++ {
+		re, err := regexp.Compile(`(\w+)@(\w+)\.com`)
+		if err != nil {
+			b.Fatal(err)
+		}
+		ioutil.Discard.Write([]byte(re.ReplaceAllString(text, "$1 at $2")))
+	}
+}
+
+func BenchmarkForLoopAttributeAccess(b *testing.B) {
+	type item struct {
+		Name  string
+		Price int
+	}
+	items := make([]item, 10000)
+	for i := range items {
+		items[i] = item{Name: "item", Price: i}
+	}
+
+	tpl, err := pongo2.FromString("{% for item in items %}{{ item.Name }}:{{ item.Price }}{% endfor %}")
+	if err != nil {
+		b.Fatal(err)
+	}
+	ctx := pongo2.Context{"items": items}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err = tpl.ExecuteWriterUnbuffered(ctx, ioutil.Discard)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// A loop calling a macro and rendering buffered tags in every iteration
+const bufferedLoopTemplate = `{% macro row(item) %}<td>{{ item }}</td>{% endmacro %}` +
+	`{% for i in items %}{% spaceless %}<tr> {{ row(i) }} </tr>{% endspaceless %}` +
+	`{% filter upper %}{{ i }}{% endfilter %}{% endfor %}`
+
+func benchmarkBufferedLoop(b *testing.B, pooling bool) {
+	pongo2.SetBufferPooling(pooling)
+	defer pongo2.SetBufferPooling(true)
+
+	tpl, err := pongo2.FromString(bufferedLoopTemplate)
+	if err != nil {
+		b.Fatal(err)
+	}
+	ctx := pongo2.Context{"items": make([]int, 1000)}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err = tpl.ExecuteWriterUnbuffered(ctx, ioutil.Discard)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkBufferedLoopPooled(b *testing.B) {
+	benchmarkBufferedLoop(b, true)
+}
+
+func BenchmarkBufferedLoopUnpooled(b *testing.B) {
+	benchmarkBufferedLoop(b, false)
+}
+
+func BenchmarkNestedStructFieldAccess(b *testing.B) {
+	type address struct {
+		City    string
+		Country string
+	}
+	type customer struct {
+		Name    string
+		Address *address
+	}
+	type order struct {
+		ID       int
+		Customer customer
+	}
+	orders := make([]*order, 10000)
+	for i := range orders {
+		orders[i] = &order{ID: i, Customer: customer{Name: "name", Address: &address{City: "city", Country: "country"}}}
+	}
+
+	tpl, err := pongo2.FromString("{% for o in orders %}{{ o.ID }}:{{ o.Customer.Name }}:" +
+		"{{ o.Customer.Address.City }}:{{ o.Customer.Address.Country }}{% endfor %}")
+	if err != nil {
+		b.Fatal(err)
+	}
+	ctx := pongo2.Context{"orders": orders}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err = tpl.ExecuteWriterUnbuffered(ctx, ioutil.Discard)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -1,7 +1,19 @@
 package pongo2_test
 
 import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/flosch/pongo2"
 	. "gopkg.in/check.v1"
@@ -101,3 +113,1894 @@ func (s *TestSuite) TestImplicitExecCtx(c *C) {
 
 	c.Check(res, Equals, val)
 }
+
+func (s *TestSuite) TestExecutionErrorPosition(c *C) {
+	tpl, err := testSuite2.FromString("Line 1\nLine 2 {{ 5|date:\"2006\" }}\n")
+	if err != nil {
+		c.Fatal(err)
+	}
+	c.Check(tpl.Name(), Equals, "<string>")
+
+	_, err = tpl.Execute(nil)
+	c.Assert(err, NotNil)
+	perr, ok := err.(*pongo2.Error)
+	c.Assert(ok, Equals, true)
+	c.Check(perr.Filename, Equals, "<string>")
+	c.Check(perr.Line, Equals, 2)
+	c.Check(perr.Column, Equals, 13)
+	c.Check(err.Error(), Matches, `\[Error \(where: filter:date\) in <string> \| Line 2 Col 13 near 'date'\] .*`)
+
+	// Errors within tags are carrying the tag's position
+	tpl, err = testSuite2.FromString("\n\n{% for i in items %}{{ i|date:\"2006\" }}{% endfor %}")
+	if err != nil {
+		c.Fatal(err)
+	}
+	_, err = tpl.Execute(pongo2.Context{"items": []int{1}})
+	c.Assert(err, NotNil)
+	c.Check(err.(*pongo2.Error).Line, Equals, 3)
+
+	tpl, err = testSuite2.FromFile("template_tests/empty.tpl")
+	if err != nil {
+		c.Fatal(err)
+	}
+	c.Check(tpl.Name(), Equals, "template_tests/empty.tpl")
+}
+
+func (s *TestSuite) TestDebugAnnotate(c *C) {
+	set := pongo2.NewSet("debug annotate", pongo2.MustNewLocalFileSystemLoader(""))
+	tpl, err := set.FromString(`{% include "template_tests/includes.helper" %}|{% block content %}Block{% endblock %}`)
+	if err != nil {
+		c.Fatal(err)
+	}
+	ctx := pongo2.Context{"what_am_i": "included", "number": 1}
+
+	// Annotations are only active in debug mode
+	set.DebugAnnotate = true
+	out, err := tpl.Execute(ctx)
+	if err != nil {
+		c.Fatal(err)
+	}
+	c.Check(out, Equals, "I'm included1|Block")
+
+	set.Debug = true
+	out, err = tpl.Execute(ctx)
+	if err != nil {
+		c.Fatal(err)
+	}
+	c.Check(out, Matches, `<!-- begin include \(.*template_tests/includes.helper\) -->I'm included1<!-- end -->\|`+
+		`<!-- begin block content \(<string>\) -->Block<!-- end -->`)
+}
+
+func (s *TestSuite) TestRequiredVars(c *C) {
+	tpl, err := testSuite2.FromString("{{ name }} ({{ age }})")
+	if err != nil {
+		c.Fatal(err)
+	}
+	tpl.SetRequiredVars(map[string]reflect.Kind{
+		"name": reflect.String,
+		"age":  reflect.Int,
+	})
+
+	out, err := tpl.Execute(pongo2.Context{"name": "john", "age": 42})
+	if err != nil {
+		c.Fatal(err)
+	}
+	c.Check(out, Equals, "john (42)")
+
+	// Missing key
+	_, err = tpl.Execute(pongo2.Context{"name": "john"})
+	c.Check(err, ErrorMatches, `\[Error \(where: validate\) in <string>\] required context-key 'age' is missing`)
+
+	// Wrong kind
+	_, err = tpl.Execute(pongo2.Context{"name": "john", "age": "42"})
+	c.Check(err, ErrorMatches, `.*context-key 'age' must be of kind int \(is string\)`)
+
+	// Kinds of pointers are resolved; reflect.Invalid accepts any kind
+	age := 42
+	c.Check(pongo2.Context{"age": &age, "x": nil}.Validate(map[string]reflect.Kind{
+		"age": reflect.Int,
+		"x":   reflect.Invalid,
+	}), IsNil)
+}
+
+type flushCountingWriter struct {
+	bytes.Buffer
+	flushes int
+}
+
+func (w *flushCountingWriter) Flush() {
+	w.flushes++
+}
+
+func (s *TestSuite) TestExecuteWriterFlush(c *C) {
+	tpl, err := testSuite2.FromString("<ul>{% for i in items %}<li>{{ i }}</li>{% endfor %}</ul>")
+	if err != nil {
+		c.Fatal(err)
+	}
+	ctx := pongo2.Context{"items": []int{1, 2, 3}}
+
+	w := &flushCountingWriter{}
+	err = tpl.ExecuteWriterFlush(ctx, w)
+	if err != nil {
+		c.Fatal(err)
+	}
+	c.Check(w.String(), Equals, "<ul><li>1</li><li>2</li><li>3</li></ul>")
+	// 3 top-level nodes + 3 loop iterations
+	c.Check(w.flushes, Equals, 6)
+
+	// Writers without flush support are written to directly
+	var buf bytes.Buffer
+	err = tpl.ExecuteWriterFlush(ctx, &buf)
+	if err != nil {
+		c.Fatal(err)
+	}
+	c.Check(buf.String(), Equals, "<ul><li>1</li><li>2</li><li>3</li></ul>")
+}
+
+type stubCSRFProvider struct{}
+
+func (stubCSRFProvider) Token(ctx *pongo2.ExecutionContext) string {
+	return "token-" + ctx.Public["user"].(string)
+}
+
+func (s *TestSuite) TestCSRFToken(c *C) {
+	set := pongo2.NewSet("csrf", pongo2.MustNewLocalFileSystemLoader(""))
+	tpl, err := set.FromString("<form>{% csrf_token %}</form>")
+	if err != nil {
+		c.Fatal(err)
+	}
+
+	// Token from the context
+	out, err := tpl.Execute(pongo2.Context{"csrf_token": `a"b`})
+	if err != nil {
+		c.Fatal(err)
+	}
+	c.Check(out, Equals, `<form><input type="hidden" name="csrfmiddlewaretoken" value="a&quot;b"></form>`)
+
+	// No token available
+	out, err = tpl.Execute(nil)
+	if err != nil {
+		c.Fatal(err)
+	}
+	c.Check(out, Equals, "<form></form>")
+
+	// Token from the provider
+	set.CSRFProvider = stubCSRFProvider{}
+	out, err = tpl.Execute(pongo2.Context{"user": "john", "csrf_token": "unused"})
+	if err != nil {
+		c.Fatal(err)
+	}
+	c.Check(out, Equals, `<form><input type="hidden" name="csrfmiddlewaretoken" value="token-john"></form>`)
+
+	_, err = set.FromString("{% csrf_token foo %}")
+	c.Check(err, ErrorMatches, ".*Tag 'csrf_token' does not take any argument.")
+}
+
+func (s *TestSuite) TestRegexpCache(c *C) {
+	set := pongo2.NewSet("regexp cache", pongo2.MustNewLocalFileSystemLoader(""))
+	tpl, err := set.FromString("{{ s|regex_replace:pattern }}")
+	if err != nil {
+		c.Fatal(err)
+	}
+
+	// Repeated executions use the cached pattern
+	for i := 0; i < 3; i++ {
+		out, err := tpl.Execute(pongo2.Context{"s": "aaa-b", "pattern": "a+,x"})
+		c.Check(err, IsNil)
+		c.Check(out, Equals, "x-b")
+	}
+	c.Check(set.RegexpCacheSize(), Equals, 1)
+
+	// The cache stops growing at its limit
+	for i := 0; i < pongo2.MaxRegexpCacheSize+10; i++ {
+		_, err := tpl.Execute(pongo2.Context{"s": "a", "pattern": fmt.Sprintf("a{%d},x", i)})
+		c.Check(err, IsNil)
+	}
+	c.Check(set.RegexpCacheSize(), Equals, pongo2.MaxRegexpCacheSize)
+}
+
+func (s *TestSuite) TestRandomFilter(c *C) {
+	set := pongo2.NewSet("random", pongo2.MustNewLocalFileSystemLoader(""))
+	set.Rand = rand.New(rand.NewSource(42))
+	tpl, err := set.FromString("{{ banners|random }}|{% filter random %}abcdef{% endfilter %}")
+	if err != nil {
+		c.Fatal(err)
+	}
+
+	banners := []string{"a", "b", "c", "d", "e", "f", "g"}
+	expected := rand.New(rand.NewSource(42))
+	want := banners[expected.Intn(len(banners))] + "|" + string("abcdef"[expected.Intn(6)])
+
+	out, err := tpl.Execute(pongo2.Context{"banners": banners})
+	if err != nil {
+		c.Fatal(err)
+	}
+	c.Check(out, Equals, want)
+}
+
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+func (l *lockedRand) Intn(n int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Intn(n)
+}
+
+func (s *TestSuite) TestRandomFilterConcurrent(c *C) {
+	set := pongo2.NewSet("random concurrent", pongo2.MustNewLocalFileSystemLoader(""))
+	set.Rand = &lockedRand{r: rand.New(rand.NewSource(42))}
+	tpl, err := set.FromString("{{ banners|random }}")
+	if err != nil {
+		c.Fatal(err)
+	}
+
+	results := make(chan string, 10*50)
+	var wg sync.WaitGroup
+	for g := 0; g < 10; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				out, err := tpl.Execute(pongo2.Context{"banners": []string{"a", "b", "c"}})
+				if err != nil {
+					out = err.Error()
+				}
+				results <- out
+			}
+		}()
+	}
+	wg.Wait()
+	close(results)
+	for out := range results {
+		if out != "a" && out != "b" && out != "c" {
+			c.Fatalf("unexpected output %q", out)
+		}
+	}
+}
+
+func (s *TestSuite) TestCyclePerExecution(c *C) {
+	// The positions of the cycles are kept per execution, so each execution
+	// starts with the first value and concurrent executions don't interfere
+	tpl, err := pongo2.FromString(`{% for i in items %}{% cycle "a" "b" "c" %}{% if i == 2 %}{% resetcycle %}{% endif %}{% endfor %}`)
+	if err != nil {
+		c.Fatal(err)
+	}
+	const expected = "abcabaabcababc"
+	items := []int{0, 1, 2, 0, 2, 2, 0, 0, 2, 0, 2, 0, 0, 1}
+
+	var wg sync.WaitGroup
+	errs := make(chan string, 10)
+	for g := 0; g < 10; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				out, err := tpl.Execute(pongo2.Context{"items": items})
+				if err != nil || out != expected {
+					errs <- fmt.Sprintf("goroutine %d: %v %q", g, err, out)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for e := range errs {
+		c.Error(e)
+	}
+}
+
+type stringerValue struct{ name string }
+
+func (s stringerValue) String() string { return "stringer:" + s.name }
+
+type stringerPtrValue struct{ name string }
+
+func (s *stringerPtrValue) String() string { return "ptr-stringer:" + s.name }
+
+type textMarshalerValue struct{ id int }
+
+func (t textMarshalerValue) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("text:%d", t.id)), nil
+}
+
+// Values of numeric or string kinds (like time.Duration) are rendered by
+// their kind, String() is ignored
+type numericStringerValue int
+
+func (n numericStringerValue) String() string { return "enum" }
+
+type stringStringerValue string
+
+func (s stringStringerValue) String() string { return "stringer" }
+
+func (s *TestSuite) TestStringerOutput(c *C) {
+	tpl, err := pongo2.FromString("{{ a }}|{{ b }}|{{ c }}|{{ d }}|{{ e }}|{{ f }}|{{ g }}|{{ h }}")
+	if err != nil {
+		c.Fatal(err)
+	}
+	out, err := tpl.Execute(pongo2.Context{
+		"a": stringerValue{"a"},
+		"b": &stringerValue{"b"},
+		"c": stringerPtrValue{"c"},
+		"d": &stringerPtrValue{"d"},
+		"e": textMarshalerValue{5},
+		"f": (*stringerPtrValue)(nil),
+		"g": stringStringerValue("plain"),
+		"h": numericStringerValue(3),
+	})
+	if err != nil {
+		c.Fatal(err)
+	}
+	c.Check(out, Equals, "stringer:a|stringer:b|ptr-stringer:c|ptr-stringer:d|text:5||plain|3")
+}
+
+func (s *TestSuite) TestErrorRecovery(c *C) {
+	set := pongo2.NewSet("error recovery", pongo2.MustNewLocalFileSystemLoader(""))
+	set.ErrorPlaceholder = func(err *pongo2.Error) string {
+		return "[" + err.Sender + "]"
+	}
+	tpl, err := set.FromString(`<{{ "a" }}|{{ "" | random }}|{% for i in items %}{{ i }}{% if i == 2 %}{{ 5|random }}{% endif %}{% endfor %}|{{ "b" }}>`)
+	if err != nil {
+		c.Fatal(err)
+	}
+
+	out, err := tpl.Execute(pongo2.Context{"items": []int{1, 2, 3}})
+	c.Check(out, Equals, "<a|[filter:random]|12[filter:random]3|b>")
+	recovered, ok := err.(pongo2.RecoveredErrors)
+	c.Assert(ok, Equals, true)
+	c.Check(len(recovered), Equals, 2)
+	c.Check(recovered[0].Sender, Equals, "filter:random")
+	c.Check(recovered[0].Line, Equals, 1)
+
+	// The try-tag takes precedence over the error-recovery mode
+	tryTpl, err := set.FromString(`{% try %}{{ 5|random }}{% except %}fallback{% endtry %}|{{ 5|random }}`)
+	if err != nil {
+		c.Fatal(err)
+	}
+	out, err = tryTpl.Execute(nil)
+	c.Check(out, Equals, "fallback|[filter:random]")
+	c.Check(err, FitsTypeOf, pongo2.RecoveredErrors{})
+
+	// The errors of parsed SSIs are recovered as well
+	ssiTpl, err := set.FromString(`<{% ssi "template_tests/recovery.helper" parsed %}>`)
+	if err != nil {
+		c.Fatal(err)
+	}
+	out, err = ssiTpl.Execute(nil)
+	c.Check(out, Equals, "<a|[filter:random]\n>")
+	recovered, ok = err.(pongo2.RecoveredErrors)
+	c.Assert(ok, Equals, true)
+	c.Check(len(recovered), Equals, 1)
+
+	// Error-free executions don't return an error
+	okTpl, err := set.FromString("{{ 1 }}")
+	if err != nil {
+		c.Fatal(err)
+	}
+	out, err = okTpl.Execute(nil)
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "1")
+
+	// Without a placeholder, the execution is aborted
+	set.ErrorPlaceholder = nil
+	out, err = tpl.Execute(pongo2.Context{"items": []int{1, 2, 3}})
+	c.Check(err, NotNil)
+	c.Check(out, Equals, "")
+}
+
+func (s *TestSuite) TestFilterAliasScope(c *C) {
+	tpl, err := testSuite2.FromString(`{% filteralias shout = upper %}{{ "hi"|shout }}`)
+	if err != nil {
+		c.Fatal(err)
+	}
+	out, err := tpl.Execute(nil)
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "HI")
+
+	// Aliases don't leak into other templates or into the global filters
+	c.Check(pongo2.FilterExists("shout"), Equals, false)
+	_, err = testSuite2.FromString(`{{ "hi"|shout }}`)
+	c.Check(err, ErrorMatches, ".*Filter 'shout' does not exist.*")
+}
+
+func (s *TestSuite) TestFilterArgumentValidation(c *C) {
+	// Literal arguments are validated at compile time
+	_, err := testSuite2.FromString(`{{ "Hello"|truncatechars:"abc" }}`)
+	c.Check(err, ErrorMatches, ".*Invalid argument for filter 'truncatechars': argument must be an integer.*")
+	_, err = testSuite2.FromString(`{{ "Hello"|truncatechars:1.5 }}`)
+	c.Check(err, ErrorMatches, ".*Invalid argument for filter 'truncatechars': argument must be an integer.*")
+	_, err = testSuite2.FromString(`{{ "Hello"|truncatechars:"1.5" }}`)
+	c.Check(err, ErrorMatches, ".*Invalid argument for filter 'truncatechars': argument must be an integer.*")
+
+	// Valid literals stay unaffected
+	tpl, err := testSuite2.FromString(`{{ "Hello"|truncatechars:"4" }}`)
+	if err != nil {
+		c.Fatal(err)
+	}
+	out, err := tpl.Execute(nil)
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "H...")
+
+	// Variable arguments aren't validated, the filter converts them as before
+	tpl, err = testSuite2.FromString(`{{ "Hello"|truncatechars:length }}`)
+	if err != nil {
+		c.Fatal(err)
+	}
+	out, err = tpl.Execute(pongo2.Context{"length": 4})
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "H...")
+	out, err = tpl.Execute(pongo2.Context{"length": "abc"})
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "")
+
+	// Validators can only be registered for existing filters
+	c.Check(pongo2.RegisterFilterValidator("non_existent_filter", nil), NotNil)
+}
+
+func (s *TestSuite) TestOutputTransform(c *C) {
+	set := pongo2.NewSet("output transform", pongo2.MustNewLocalFileSystemLoader(""))
+	c.Assert(set.RegisterOutputTransform("upper", func(output string) (string, error) {
+		return strings.ToUpper(output), nil
+	}), IsNil)
+	c.Assert(set.RegisterOutputTransform("fail", func(output string) (string, error) {
+		return "", fmt.Errorf("transform failed for '%s'", output)
+	}), IsNil)
+	c.Check(set.RegisterOutputTransform("upper", nil), NotNil)
+
+	tpl, err := set.FromString(`{% transform upper %}Hello {{ name }} <b>{% endtransform %}|{{ name }}`)
+	if err != nil {
+		c.Fatal(err)
+	}
+	out, err := tpl.Execute(pongo2.Context{"name": "<john>"})
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "HELLO &LT;JOHN&GT; <B>|&lt;john&gt;")
+
+	tpl, err = set.FromString(`{% transform fail %}body{% endtransform %}`)
+	if err != nil {
+		c.Fatal(err)
+	}
+	_, err = tpl.Execute(nil)
+	c.Check(err, ErrorMatches, ".*transform failed for 'body'.*")
+
+	_, err = set.FromString(`{% transform unknown %}body{% endtransform %}`)
+	c.Check(err, ErrorMatches, ".*Output transform 'unknown' does not exist.*")
+}
+
+type stubMarkdownRenderer struct{}
+
+func (stubMarkdownRenderer) Render(markdown []byte) ([]byte, error) {
+	if bytes.Contains(markdown, []byte("fail")) {
+		return nil, fmt.Errorf("cannot render markdown")
+	}
+	return []byte("<md>" + string(markdown) + "</md>"), nil
+}
+
+func (s *TestSuite) TestMarkdown(c *C) {
+	set := pongo2.NewSet("markdown", pongo2.MustNewLocalFileSystemLoader(""))
+	tpl, err := set.FromString(`{% markdown %}# Hello {{ name }}{% endmarkdown %}|` +
+		`{% markdown raw %}# Hello {{ name }} {% if %}{% endmarkdown %}|{{ name }}`)
+	if err != nil {
+		c.Fatal(err)
+	}
+	ctx := pongo2.Context{"name": "<john>"}
+
+	// A renderer is required
+	_, err = tpl.Execute(ctx)
+	c.Check(err, ErrorMatches, ".*No markdown renderer set.*")
+
+	set.MarkdownRenderer = stubMarkdownRenderer{}
+	out, err := tpl.Execute(ctx)
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "<md># Hello &lt;john&gt;</md>|<md># Hello {{ name }} {% if %}</md>|&lt;john&gt;")
+
+	tpl, err = set.FromString(`{% markdown %}fail{% endmarkdown %}`)
+	if err != nil {
+		c.Fatal(err)
+	}
+	_, err = tpl.Execute(nil)
+	c.Check(err, ErrorMatches, ".*cannot render markdown.*")
+
+	// The raw-form allows any whitespace and whitespace control
+	tpl, err = set.FromString("{%markdown raw%}{{ a }}{%endmarkdown%}|{% markdown  raw %}{{ b }}{% endmarkdown %}|" +
+		"x {%- markdown raw -%} {{ c }} {%- endmarkdown -%} x")
+	if err != nil {
+		c.Fatal(err)
+	}
+	out, err = tpl.Execute(nil)
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "<md>{{ a }}</md>|<md>{{ b }}</md>|x<md>{{ c }}</md>x")
+
+	_, err = set.FromString(`{% markdown raw %}# Not closed`)
+	c.Check(err, ErrorMatches, ".*Raw block not closed \\(expected '{% endmarkdown %}'\\), got EOF.*")
+}
+
+func (s *TestSuite) TestValueMarshalJSON(c *C) {
+	type user struct {
+		Name string `json:"name"`
+		Age  int    `json:"age"`
+	}
+
+	b, err := json.Marshal(pongo2.AsValue(user{Name: "John", Age: 42}))
+	c.Check(err, IsNil)
+	c.Check(string(b), Equals, `{"name":"John","age":42}`)
+
+	b, err = json.Marshal(pongo2.AsValue(&user{Name: "Jane"}))
+	c.Check(err, IsNil)
+	c.Check(string(b), Equals, `{"name":"Jane","age":0}`)
+
+	b, err = json.Marshal(pongo2.AsValue(nil))
+	c.Check(err, IsNil)
+	c.Check(string(b), Equals, "null")
+
+	b, err = json.Marshal(map[string]*pongo2.Value{"list": pongo2.AsValue([]int{1, 2})})
+	c.Check(err, IsNil)
+	c.Check(string(b), Equals, `{"list":[1,2]}`)
+
+	// The json-filter
+	out, err := pongo2.ApplyFilter("json", pongo2.AsValue(user{Name: "John"}), nil)
+	c.Check(err, IsNil)
+	c.Check(out.String(), Equals, `{"name":"John","age":0}`)
+	_, err = pongo2.ApplyFilter("json", pongo2.AsValue(make(chan int)), nil)
+	c.Check(err, ErrorMatches, ".*filter:json.*unsupported type: chan int.*")
+}
+
+func (s *TestSuite) TestToJSON(c *C) {
+	type point struct {
+		Y int `json:"y"`
+		X int `json:"x"`
+	}
+
+	in := map[string]interface{}{
+		"zeta":  []interface{}{point{Y: 2, X: 1}, map[string]int{"b": 2, "a": 1}},
+		"alpha": map[string]interface{}{"nested": point{Y: 4, X: 3}, "big": 1.5},
+	}
+	expected := `{"alpha":{"big":1.5,"nested":{"x":3,"y":4}},"zeta":[{"x":1,"y":2},{"a":1,"b":2}]}`
+
+	// The output must be stable across runs
+	for i := 0; i < 10; i++ {
+		out, err := pongo2.ApplyFilter("tojson", pongo2.AsValue(in), nil)
+		c.Check(err, IsNil)
+		c.Check(out.String(), Equals, expected)
+	}
+
+	_, err := pongo2.ApplyFilter("tojson", pongo2.AsValue(make(chan int)), nil)
+	c.Check(err, ErrorMatches, ".*filter:tojson.*unsupported type: chan int.*")
+}
+
+func (s *TestSuite) TestForLimitOffsetErrors(c *C) {
+	tpl, err := testSuite2.FromString("{% for i in items limit n %}{{ i }}{% endfor %}")
+	if err != nil {
+		c.Fatal(err)
+	}
+	_, err = tpl.Execute(pongo2.Context{"items": []int{1, 2}, "n": -1})
+	c.Check(err, ErrorMatches, ".*The for-loop's limit must be a non-negative integer \\(got: '-1'\\).*")
+	_, err = tpl.Execute(pongo2.Context{"items": []int{1, 2}, "n": "abc"})
+	c.Check(err, ErrorMatches, ".*The for-loop's limit must be a non-negative integer \\(got: 'abc'\\).*")
+}
+
+func (s *TestSuite) TestReadOnlyContext(c *C) {
+	set := pongo2.NewSet("read-only context", pongo2.MustNewLocalFileSystemLoader(""))
+	set.ReadOnlyContext = true
+
+	ctx := pongo2.Context{"name": "john"}
+
+	tpl, err := set.FromString("{% set name = 'jane' %}{{ name }}")
+	if err != nil {
+		c.Fatal(err)
+	}
+	_, err = tpl.Execute(ctx)
+	c.Check(err, ErrorMatches, ".*Cannot set 'name': the top-level context is read-only.*")
+
+	// The same applies to other tags assigning variables
+	for _, src := range []string{"{% widthratio 1 2 100 as name %}", "{% cycle 'a' 'b' as name %}"} {
+		tpl, err = set.FromString(src)
+		if err != nil {
+			c.Fatal(err)
+		}
+		_, err = tpl.Execute(ctx)
+		c.Check(err, ErrorMatches, ".*Cannot set 'name': the top-level context is read-only.*")
+	}
+	tpl, err = set.FromString("{% for i in '12' %}{% cycle 'a' 'b' as name %}{{ name }}{% endfor %}")
+	if err != nil {
+		c.Fatal(err)
+	}
+	out, err := tpl.Execute(ctx)
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "aabb")
+
+	// Assignments within a child scope still work
+	tpl, err = set.FromString("{% with name='jane' %}{{ name }}{% set name = 'max' %} {{ name }}{% endwith %} {{ name }}")
+	if err != nil {
+		c.Fatal(err)
+	}
+	out, err = tpl.Execute(ctx)
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "jane max john")
+	c.Check(ctx["name"], Equals, "john")
+
+	// Only if enabled
+	set.ReadOnlyContext = false
+	tpl, err = set.FromString("{% set name = 'jane' %}{{ name }}")
+	if err != nil {
+		c.Fatal(err)
+	}
+	out, err = tpl.Execute(ctx)
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "jane")
+	c.Check(ctx["name"], Equals, "john")
+}
+
+func (s *TestSuite) TestFunctionCalls(c *C) {
+	ctx := pongo2.Context{
+		"greet": func(name string) string {
+			return "Hello " + name + "!"
+		},
+		"divide": func(a, b float64) (float64, error) {
+			if b == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			return a / b, nil
+		},
+		"join": func(sep string, parts ...string) string {
+			return strings.Join(parts, sep)
+		},
+		"sum64": func(nums ...int64) int64 {
+			var sum int64
+			for _, n := range nums {
+				sum += n
+			}
+			return sum
+		},
+		"isnil": func(v interface{}) bool {
+			return v == nil
+		},
+		"f_int":  func(i int) int { return i },
+		"f_i8":   func(i int8) int8 { return i },
+		"f_uint": func(u uint) uint { return u },
+		"f_f32":  func(f float32) float32 { return f },
+	}
+
+	tpl, err := testSuite2.FromString(`{{ greet("world") }}|{{ divide(10, 4) }}|{{ join("-", "a", "b", "c") }}|{{ join(",") }}|{{ sum64(1, 2, 3) }}|{{ isnil(nothing) }}`)
+	if err != nil {
+		c.Fatal(err)
+	}
+	out, err := tpl.Execute(ctx)
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "Hello world!|2.500000|a-b-c||6|True")
+
+	// Errors returned by functions abort the execution
+	tpl, err = testSuite2.FromString(`{{ divide(1, 0) }}`)
+	if err != nil {
+		c.Fatal(err)
+	}
+	_, err = tpl.Execute(ctx)
+	c.Check(err, ErrorMatches, ".*division by zero.*")
+
+	// Arguments which can't be converted
+	tpl, err = testSuite2.FromString(`{{ greet(5) }}`)
+	if err != nil {
+		c.Fatal(err)
+	}
+	_, err = tpl.Execute(ctx)
+	c.Check(err, ErrorMatches, ".*Function input argument 0 of 'greet' must be of type string or \\*pongo2.Value \\(not int\\).*")
+
+	// Numbers are only converted without losing data
+	tpl, err = testSuite2.FromString(`{{ f_int(2.0) }}|{{ f_i8(-128) }}|{{ f_uint(300) }}|{{ f_f32(16777216) }}`)
+	if err != nil {
+		c.Fatal(err)
+	}
+	out, err = tpl.Execute(ctx)
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "2|-128|300|16777216.000000")
+
+	for src, msg := range map[string]string{
+		`{{ f_int(1.9) }}`:      "int or \\*pongo2.Value \\(not float64\\)",
+		`{{ f_i8(300) }}`:       "int8 or \\*pongo2.Value \\(not int\\)",
+		`{{ f_uint(-1) }}`:      "uint or \\*pongo2.Value \\(not int\\)",
+		`{{ f_f32(16777217) }}`: "float32 or \\*pongo2.Value \\(not int\\)",
+	} {
+		tpl, err = testSuite2.FromString(src)
+		if err != nil {
+			c.Fatal(err)
+		}
+		_, err = tpl.Execute(ctx)
+		c.Check(err, ErrorMatches, ".*must be of type "+msg+".*", Commentf("%s", src))
+	}
+}
+
+func (s *TestSuite) TestPprintFilter(c *C) {
+	type item struct {
+		Name string
+		Tags []string
+	}
+	data := map[string]interface{}{
+		"items": []item{{Name: "<a>", Tags: []string{"x"}}},
+		"count": 2,
+		"empty": []int{},
+	}
+	out, err := pongo2.ApplyFilter("pprint", pongo2.AsValue(data), nil)
+	c.Check(err, IsNil)
+	c.Check(out.String(), Equals, `map[string]interface {}{
+    &quot;count&quot;: 2,
+    &quot;empty&quot;: []int{},
+    &quot;items&quot;: []pongo2_test.item{
+        pongo2_test.item{
+            Name: &quot;&lt;a&gt;&quot;,
+            Tags: []string{
+                &quot;x&quot;,
+            },
+        },
+    },
+}`)
+
+	// Cyclic references are marked instead of being followed
+	cyclic := map[string]interface{}{"name": "root"}
+	cyclic["self"] = cyclic
+	out, err = pongo2.ApplyFilter("pprint", pongo2.AsValue(cyclic), nil)
+	c.Check(err, IsNil)
+	c.Check(out.String(), Equals, `map[string]interface {}{
+    &quot;name&quot;: &quot;root&quot;,
+    &quot;self&quot;: &lt;cycle map[string]interface {}&gt;,
+}`)
+
+	// The output is escaped already and not escaped twice
+	tpl, tplErr := pongo2.FromString("{{ s|pprint }}")
+	if tplErr != nil {
+		c.Fatal(tplErr)
+	}
+	res, tplErr := tpl.Execute(pongo2.Context{"s": []string{"<b>"}})
+	c.Check(tplErr, IsNil)
+	c.Check(res, Equals, "[]string{\n    &quot;&lt;b&gt;&quot;,\n}")
+}
+
+func (s *TestSuite) TestExecuteBlock(c *C) {
+	tpl, err := testSuite2.FromFile("template_tests/extends_super.tpl")
+	if err != nil {
+		c.Fatal(err)
+	}
+
+	// The child's override including block.Super
+	out, err := tpl.ExecuteBlock("content", nil)
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "Default contentextends-level-1")
+
+	// A block of the base template containing an overridden block
+	out, err = tpl.ExecuteBlock("body", nil)
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "This is base's bodyDefault contentextends-level-1")
+
+	// A standalone block using the context
+	tpl, err = testSuite2.FromString("Head{% block row %}<td>{{ name }}</td>{% endblock %}Foot")
+	if err != nil {
+		c.Fatal(err)
+	}
+	out, err = tpl.ExecuteBlock("row", pongo2.Context{"name": "<b>"})
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "<td>&lt;b&gt;</td>")
+
+	_, err = tpl.ExecuteBlock("missing", nil)
+	c.Check(err, ErrorMatches, ".*block 'missing' does not exist.*")
+}
+
+func (s *TestSuite) TestFloatFormat(c *C) {
+	set := pongo2.NewSet("float format", pongo2.MustNewLocalFileSystemLoader(""))
+	tpl, err := set.FromString("{{ whole }} {{ fractional }} {{ 2.50 }} {{ number }} {{ fractional|floatformat:3 }}")
+	if err != nil {
+		c.Fatal(err)
+	}
+	ctx := pongo2.Context{"whole": 1.0, "fractional": 3.25, "number": 7}
+
+	// Default (unchanged behavior)
+	out, err := tpl.Execute(ctx)
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "1.000000 3.250000 2.500000 7 3.250")
+
+	set.FloatFormat = "%v"
+	out, err = tpl.Execute(ctx)
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "1 3.25 2.5 7 3.250")
+
+	set.FloatFormat = "%.1f"
+	out, err = tpl.Execute(ctx)
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "1.0 3.2 2.5 7 3.250")
+}
+
+func (s *TestSuite) TestForChannel(c *C) {
+	tpl, err := testSuite2.FromString("{% for v in ch %}{{ forloop.Counter }}:{{ v }}/{{ forloop.Revcounter }}/{{ forloop.First }}/{{ forloop.Last }} {% empty %}empty{% endfor %}")
+	if err != nil {
+		c.Fatal(err)
+	}
+
+	ch := make(chan string)
+	go func() {
+		for _, v := range []string{"a", "b", "c"} {
+			ch <- v
+		}
+		close(ch)
+	}()
+	out, err := tpl.Execute(pongo2.Context{"ch": ch})
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "1:a/0/True/False 2:b/0/False/False 3:c/0/False/False ")
+
+	closed := make(chan int)
+	close(closed)
+	out, err = tpl.Execute(pongo2.Context{"ch": closed})
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "empty")
+
+	// Modifiers drain the channel first
+	tpl, err = testSuite2.FromString("{% for v in ch reversed limit 2 %}{{ v }}/{{ forloop.Revcounter }} {% endfor %}")
+	if err != nil {
+		c.Fatal(err)
+	}
+	buffered := make(chan int, 3)
+	buffered <- 1
+	buffered <- 2
+	buffered <- 3
+	close(buffered)
+	out, err = tpl.Execute(pongo2.Context{"ch": buffered})
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "3/2 2/1 ")
+}
+
+// stubMinifier collapses all whitespace and prefixes the mediatype.
+type stubMinifier struct{}
+
+func (stubMinifier) Minify(mediatype, content string) (string, error) {
+	if strings.Contains(content, "fail") {
+		return "", fmt.Errorf("cannot minify %s", mediatype)
+	}
+	return mediatype + ":" + strings.Join(strings.Fields(content), " "), nil
+}
+
+func (s *TestSuite) TestMinify(c *C) {
+	set := pongo2.NewSet("minify", pongo2.MustNewLocalFileSystemLoader(""))
+	tpl, err := set.FromString("{% minify html %}<p>\n    {{ text }}\n</p>{% endminify %}|" +
+		"{% minify css %}a {\n  color: red;\n}{% endminify %}|{% minify js %}var a  =  1;{% endminify %}")
+	if err != nil {
+		c.Fatal(err)
+	}
+	ctx := pongo2.Context{"text": "a  <b>"}
+
+	// Without a minifier the body is unchanged
+	out, err := tpl.Execute(ctx)
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "<p>\n    a  &lt;b&gt;\n</p>|a {\n  color: red;\n}|var a  =  1;")
+
+	set.Minifier = stubMinifier{}
+	out, err = tpl.Execute(ctx)
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "text/html:<p> a &lt;b&gt; </p>|text/css:a { color: red; }|application/javascript:var a = 1;")
+
+	tpl, err = set.FromString("{% minify js %}fail{% endminify %}")
+	if err != nil {
+		c.Fatal(err)
+	}
+	_, err = tpl.Execute(nil)
+	c.Check(err, ErrorMatches, ".*cannot minify application/javascript.*")
+}
+
+type tokenSpanNode struct{}
+
+func (tokenSpanNode) Execute(ctx *pongo2.ExecutionContext, writer pongo2.TemplateWriter) *pongo2.Error {
+	return nil
+}
+
+// registerTestTag registers the tag or replaces it if it has been registered
+// already (by a previous run of the test, e. g. using -count).
+func registerTestTag(name string, parserFn pongo2.TagParser) error {
+	if err := pongo2.ReplaceTag(name, parserFn); err == nil {
+		return nil
+	}
+	return pongo2.RegisterTag(name, parserFn)
+}
+
+func (s *TestSuite) TestTokenSpans(c *C) {
+	var tokens []*pongo2.Token
+	err := registerTestTag("tokenspans", func(doc *pongo2.Parser, start *pongo2.Token, arguments *pongo2.Parser) (pongo2.INodeTag, *pongo2.Error) {
+		tokens = append(tokens, start)
+		for i := 0; i < arguments.Count(); i++ {
+			tokens = append(tokens, arguments.Get(i))
+		}
+		return tokenSpanNode{}, nil
+	})
+	c.Assert(err, IsNil)
+
+	src := "Hello\nWörld {{ x }}\n  {% tokenspans name \"a \\\"b\\\"\" 42 %}"
+	_, err = pongo2.FromString(src)
+	c.Assert(err, IsNil)
+
+	expected := []string{"tokenspans", "name", `"a \"b\""`, "42"}
+	c.Assert(tokens, HasLen, len(expected))
+	for idx, tok := range tokens {
+		c.Check(src[tok.Start:tok.End], Equals, expected[idx])
+		c.Check(tok.Line, Equals, 3)
+	}
+	c.Check(tokens[0].Start, Equals, strings.Index(src, "tokenspans"))
+	c.Check(tokens[2].Val, Equals, `a "b"`)
+}
+
+// tagRepeatNode is the example tag of docs/write_tags.md.
+type tagRepeatNode struct {
+	count   pongo2.IEvaluator
+	wrapper *pongo2.NodeWrapper
+}
+
+func (node *tagRepeatNode) Execute(ctx *pongo2.ExecutionContext, writer pongo2.TemplateWriter) *pongo2.Error {
+	count, err := node.count.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < count.Integer(); i++ {
+		if err := node.wrapper.Execute(ctx, writer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func tagRepeatParser(doc *pongo2.Parser, start *pongo2.Token, arguments *pongo2.Parser) (pongo2.INodeTag, *pongo2.Error) {
+	count, err := arguments.ParseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if arguments.Remaining() > 0 {
+		return nil, arguments.Error("Malformed repeat-tag arguments.", nil)
+	}
+
+	wrapper, _, err := doc.WrapUntilTag("endrepeat")
+	if err != nil {
+		return nil, err
+	}
+	return &tagRepeatNode{count: count, wrapper: wrapper}, nil
+}
+
+func (s *TestSuite) TestCustomTagParseExpression(c *C) {
+	c.Assert(registerTestTag("repeat", tagRepeatParser), IsNil)
+
+	tpl, err := pongo2.FromString("{% repeat n * 2 %}{{ word|upper }}{% endrepeat %}|{% repeat items|length %}.{% endrepeat %}")
+	if err != nil {
+		c.Fatal(err)
+	}
+	out, err := tpl.Execute(pongo2.Context{"n": 2, "word": "ab", "items": []int{1, 2, 3}})
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "ABABABAB|...")
+
+	_, err = pongo2.FromString("{% repeat 2 3 %}{% endrepeat %}")
+	c.Check(err, ErrorMatches, ".*Malformed repeat-tag arguments.*")
+	_, err = pongo2.FromString("{% repeat (1 %}{% endrepeat %}")
+	c.Check(err, NotNil)
+}
+
+type tagApplyFiltersNode struct {
+	chain   *pongo2.FilterChain
+	wrapper *pongo2.NodeWrapper
+}
+
+func (node *tagApplyFiltersNode) Execute(ctx *pongo2.ExecutionContext, writer pongo2.TemplateWriter) *pongo2.Error {
+	var buf bytes.Buffer
+	if err := node.wrapper.Execute(ctx, &buf); err != nil {
+		return err
+	}
+	value, err := node.chain.Apply(ctx, pongo2.AsValue(buf.String()))
+	if err != nil {
+		return err
+	}
+	writer.WriteString(value.String())
+	return nil
+}
+
+func (s *TestSuite) TestCustomTagParseFilterChain(c *C) {
+	err := registerTestTag("applyfilters", func(doc *pongo2.Parser, start *pongo2.Token, arguments *pongo2.Parser) (pongo2.INodeTag, *pongo2.Error) {
+		chain, err := arguments.ParseFilterChain()
+		if err != nil {
+			return nil, err
+		}
+		if arguments.Remaining() > 0 {
+			return nil, arguments.Error("Malformed applyfilters-tag arguments.", nil)
+		}
+		wrapper, _, err := doc.WrapUntilTag("endapplyfilters")
+		if err != nil {
+			return nil, err
+		}
+		if !chain.FilterApplied("upper") {
+			return nil, arguments.Error("The upper-filter is required.", nil)
+		}
+		return &tagApplyFiltersNode{chain: chain, wrapper: wrapper}, nil
+	})
+	c.Assert(err, IsNil)
+
+	tpl, err := pongo2.FromString("{% applyfilters upper|truncatechars:n|cut:\" \" %}hello {{ name }}{% endapplyfilters %}")
+	if err != nil {
+		c.Fatal(err)
+	}
+	out, err := tpl.Execute(pongo2.Context{"name": "world", "n": 9})
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "HELLO...")
+
+	_, err = pongo2.FromString("{% applyfilters lower %}{% endapplyfilters %}")
+	c.Check(err, ErrorMatches, ".*The upper-filter is required.*")
+	_, err = pongo2.FromString("{% applyfilters upper|doesnotexist %}{% endapplyfilters %}")
+	c.Check(err, ErrorMatches, ".*Filter 'doesnotexist' does not exist.*")
+	_, err = pongo2.FromString("{% applyfilters upper lower %}{% endapplyfilters %}")
+	c.Check(err, ErrorMatches, ".*Malformed applyfilters-tag arguments.*")
+}
+
+var registryListingsRuns int
+
+func (s *TestSuite) TestRegistryListings(c *C) {
+	contains := func(list []string, name string) bool {
+		for _, item := range list {
+			if item == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Filters can't be unregistered, so every run of the test (e. g. using
+	// -count) registers a new one
+	registryListingsRuns++
+	name := fmt.Sprintf("registry_test_filter_%d", registryListingsRuns)
+
+	filters := pongo2.RegisteredFilters()
+	c.Check(sort.StringsAreSorted(filters), Equals, true)
+	c.Check(contains(filters, "upper"), Equals, true)
+	c.Check(contains(filters, name), Equals, false)
+
+	c.Assert(pongo2.RegisterFilter(name, func(in *pongo2.Value, param *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
+		return in, nil
+	}), IsNil)
+	c.Check(contains(pongo2.RegisteredFilters(), name), Equals, true)
+
+	tags := pongo2.RegisteredTags()
+	c.Check(sort.StringsAreSorted(tags), Equals, true)
+	c.Check(contains(tags, "for"), Equals, true)
+	c.Check(contains(tags, "if"), Equals, true)
+
+	// Banned tags and filters are not available within a set
+	set := pongo2.NewSet("registry", pongo2.MustNewLocalFileSystemLoader(""))
+	c.Assert(set.BanTag("for"), IsNil)
+	c.Assert(set.BanFilter("upper"), IsNil)
+	c.Check(contains(set.AvailableTags(), "for"), Equals, false)
+	c.Check(contains(set.AvailableTags(), "if"), Equals, true)
+	c.Check(contains(set.AvailableFilters(), "upper"), Equals, false)
+	c.Check(contains(set.AvailableFilters(), "lower"), Equals, true)
+}
+
+func (s *TestSuite) TestEscapeTemplateFilter(c *C) {
+	input := "{{ user.name }} {% if x %}y{% endif %} {# note #} {a} 50%}"
+	out, err := pongo2.ApplyFilter("escape_template", pongo2.AsValue(input), nil)
+	c.Check(err, IsNil)
+	c.Check(out.String(), Equals, "{% templatetag openvariable %} user.name {% templatetag closevariable %} "+
+		"{% templatetag openblock %} if x {% templatetag closeblock %}y{% templatetag openblock %} endif {% templatetag closeblock %} "+
+		"{% templatetag opencomment %} note {% templatetag closecomment %} {a} 50{% templatetag closeblock %}")
+
+	// Parsing the escaped output as a template renders the original text
+	tpl, tplErr := pongo2.FromString(out.String())
+	if tplErr != nil {
+		c.Fatal(tplErr)
+	}
+	res, tplErr := tpl.Execute(pongo2.Context{"x": true})
+	c.Check(tplErr, IsNil)
+	c.Check(res, Equals, input)
+}
+
+// nullableAmount follows the Valid+value pattern of the database/sql types.
+type nullableAmount struct {
+	Amount float64
+	Valid  bool
+}
+
+// valuerID implements driver.Valuer only.
+type valuerID int
+
+func (id valuerID) Value() (driver.Value, error) {
+	if id == 0 {
+		return nil, nil
+	}
+	return fmt.Sprintf("ID-%d", int(id)), nil
+}
+
+func (s *TestSuite) TestNullableValues(c *C) {
+	tpl, err := pongo2.FromString("[{{ v }}] [{{ v|default:\"none\" }}] {% if v %}set{% else %}unset{% endif %}")
+	if err != nil {
+		c.Fatal(err)
+	}
+
+	tests := []struct {
+		value    interface{}
+		expected string
+	}{
+		{sql.NullString{String: "<john>", Valid: true}, "[&lt;john&gt;] [&lt;john&gt;] set"},
+		{sql.NullString{String: "ignored", Valid: false}, "[] [none] unset"},
+		{sql.NullInt64{Int64: 42, Valid: true}, "[42] [42] set"},
+		{sql.NullInt64{Int64: 42}, "[] [none] unset"},
+		{&sql.NullBool{Bool: true, Valid: true}, "[True] [True] set"},
+		{nullableAmount{Amount: 1.5, Valid: true}, "[1.500000] [1.500000] set"},
+		{valuerID(7), "[ID-7] [ID-7] set"},
+		{valuerID(0), "[] [none] unset"},
+	}
+	for _, test := range tests {
+		out, err := tpl.Execute(pongo2.Context{"v": test.value})
+		c.Check(err, IsNil)
+		c.Check(out, Equals, test.expected, Commentf("value: %#v", test.value))
+	}
+
+	// Nullable struct fields and loop items
+	tpl, err = pongo2.FromString("{{ row.Name }}|{% for n in names %}{{ n }},{% endfor %}")
+	if err != nil {
+		c.Fatal(err)
+	}
+	out, err := tpl.Execute(pongo2.Context{
+		"row":   struct{ Name sql.NullString }{sql.NullString{String: "x", Valid: true}},
+		"names": []sql.NullInt64{{Int64: 1, Valid: true}, {}, {Int64: 3, Valid: true}},
+	})
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "x|1,,3,")
+
+	// Nullable values are compared by their underlying value, but they are
+	// passed to functions as they are
+	tpl, err = pongo2.FromString("{% if n == 5 %}five{% endif %}|{% if null == nil %}null{% endif %}|{{ f(n) }}")
+	if err != nil {
+		c.Fatal(err)
+	}
+	out, err = tpl.Execute(pongo2.Context{
+		"n":    sql.NullInt64{Int64: 5, Valid: true},
+		"null": sql.NullString{},
+		"f": func(n sql.NullInt64) int64 {
+			return n.Int64 * 2
+		},
+	})
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "five|null|10")
+}
+
+func (s *TestSuite) TestItemsFilter(c *C) {
+	type profile struct {
+		Name     string
+		Age      int    `pongo2:"age"`
+		Password string `pongo2:"-"`
+		internal bool
+		Email    string `json:"email"`
+	}
+	p := profile{Name: "John", Age: 42, Password: "secret", internal: true, Email: "john@example.com"}
+
+	tpl, err := pongo2.FromString("{% for name, value in p|items %}{{ name }}={{ value }};{% endfor %}|" +
+		"{% for item in p|items %}{{ item.0 }},{% endfor %}|{{ p|items|length }}")
+	if err != nil {
+		c.Fatal(err)
+	}
+	expected := "Name=John;age=42;Email=john@example.com;|Name,age,Email,|3"
+	out, err := tpl.Execute(pongo2.Context{"p": p})
+	c.Check(err, IsNil)
+	c.Check(out, Equals, expected)
+
+	// Pointers to structs work as well
+	out, err = tpl.Execute(pongo2.Context{"p": &p})
+	c.Check(err, IsNil)
+	c.Check(out, Equals, expected)
+
+	_, err = pongo2.ApplyFilter("items", pongo2.AsValue([]int{1}), nil)
+	c.Check(err, ErrorMatches, ".*filter:items.*filter input must be a map or a struct \\(got: slice\\).*")
+}
+
+func (s *TestSuite) TestBlockModifiers(c *C) {
+	// Rendered directly, all blocks render their default content
+	tpl, err := testSuite2.FromFile("template_tests/inheritance/components.tpl")
+	if err != nil {
+		c.Fatal(err)
+	}
+	out, err := tpl.Execute(nil)
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "[Default title][Default sidebar][Default footer]")
+
+	// A required block must be overridden by the extending template
+	tpl, err = testSuite2.FromString(`{% extends "template_tests/inheritance/components.tpl" %}{% block footer %}Footer{% endblock %}`)
+	if err != nil {
+		c.Fatal(err)
+	}
+	_, err = tpl.Execute(nil)
+	c.Check(err, ErrorMatches, ".*Block 'title' is required and must be overridden by templates extending 'template_tests/inheritance/components.tpl'.*")
+
+	_, err = testSuite2.FromString("{% block title mandatory %}{% endblock %}")
+	c.Check(err, ErrorMatches, ".*Tag 'block' takes exactly 1 argument \\(an identifier\\) and an optional modifier \\('required' or 'optional'\\).*")
+}
+
+func (s *TestSuite) TestConstantFolding(c *C) {
+	// Literal expressions are folded at parse time; the same expressions
+	// with variables are evaluated on execution. Both must render the same.
+	ctx := pongo2.Context{"two": 2, "three": 3, "four": 4, "half": 0.5, "yes": true, "abc": "abc"}
+	exprs := [][2]string{
+		{"2 + 3 * 4", "two + three * four"},
+		{"(2 + 3) * 4", "(two + three) * four"},
+		{"2 ^ 3 - 4 / 2", "two ^ three - four / two"},
+		{"4 % 3 + 0.5", "four % three + half"},
+		{"-3 + 2", "-three + two"},
+		{"2 < 3 and not false", "two < three and not (yes == false)"},
+		{"3 >= 4 or 2 != 3", "three >= four or two != three"},
+		{"\"b\" in \"abc\"", "\"b\" in abc"},
+		{"\"x\" not in \"abc\"", "\"x\" not in abc"},
+		{"(2 + 3) * 4 + two", "(two + three) * four + two"},
+	}
+	for _, expr := range exprs {
+		folded, err := testSuite2.RenderTemplateString("{{ "+expr[0]+" }}", ctx)
+		c.Assert(err, IsNil, Commentf("%s", expr[0]))
+		unfolded, err := testSuite2.RenderTemplateString("{{ "+expr[1]+" }}", ctx)
+		c.Assert(err, IsNil, Commentf("%s", expr[1]))
+		c.Check(folded, Equals, unfolded, Commentf("%s vs. %s", expr[0], expr[1]))
+	}
+
+	// Constant expressions which can't be evaluated still fail on execution
+	tpl, err := testSuite2.FromString("{{ -\"text\" }}")
+	c.Assert(err, IsNil)
+	_, err = tpl.Execute(nil)
+	c.Check(err, ErrorMatches, ".*Negative sign on a non-number expression.*")
+}
+
+func (s *TestSuite) TestMustHelpers(c *C) {
+	tpl := pongo2.MustFromString("Hello {{ name }}!")
+	c.Check(tpl.MustExecute(pongo2.Context{"name": "john"}), Equals, "Hello john!")
+
+	tpl = testSuite2.MustFromString("{{ 1 + 2 }}")
+	c.Check(tpl.MustExecute(nil), Equals, "3")
+
+	c.Check(func() { pongo2.MustFromString("{% if %}") }, PanicMatches, `\[Error \(where: parser\).*`)
+	c.Check(func() { testSuite2.MustFromString("{{ }}") }, PanicMatches, `\[Error \(where: parser\).*`)
+
+	tpl = pongo2.MustFromString("{{ pongo2.version }}")
+	c.Check(func() { tpl.MustExecute(pongo2.Context{"'illegal": nil}) }, PanicMatches, ".*not a valid identifier.*")
+}
+
+func (s *TestSuite) TestCallables(c *C) {
+	// The same expression calls an imported macro or a Go function
+	tpl, err := testSuite2.FromString(`{% if use_macro %}{% import "template_tests/macro.helper" imported_macro as greet %}{% endif %}{{ greet("john") }}`)
+	c.Assert(err, IsNil)
+	out, err := tpl.Execute(pongo2.Context{"use_macro": true})
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "<p>Hey john!</p>")
+	out, err = tpl.Execute(pongo2.Context{
+		"use_macro": false,
+		"greet": func(name string) *pongo2.Value {
+			return pongo2.AsSafeValue("<p>Hey " + name + "!</p>")
+		},
+	})
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "<p>Hey john!</p>")
+
+	// Macros can be stored in variables and passed to functions
+	apply := func(fn *pongo2.Value, arg *pongo2.Value) *pongo2.Value {
+		if !fn.IsCallable() {
+			return pongo2.AsValue("not callable")
+		}
+		result, err := fn.Call(arg)
+		if err != nil {
+			return pongo2.AsValue(err.Error())
+		}
+		return result
+	}
+	out, err = testSuite2.RenderTemplateString(`{% macro hi(name) %}Hi {{ name }}{% endmacro %}`+
+		`{% set say_hi = hi %}{{ say_hi("a") }}|{{ apply(hi, "b") }}|{{ apply(42, "c") }}`,
+		pongo2.Context{"apply": apply})
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "Hi a|Hi b|not callable")
+
+	// Macros output or evaluated directly are still called (even without parentheses)
+	out, err = testSuite2.RenderTemplateString(`{% macro hi(name) %}Hi {{ name }}{% endmacro %}`+
+		`{{ hi }}|{% set say_hi = hi %}{{ say_hi }}|{% with w=hi %}{{ w }}{% endwith %}|{{ apply(hi|safe, "d") }}`,
+		pongo2.Context{"apply": apply})
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "Hi |Hi |Hi |not callable")
+
+	// Calling from Go
+	fn := pongo2.AsValue(func(a, b int) int { return a + b })
+	c.Check(fn.IsCallable(), Equals, true)
+	result, err := fn.Call(pongo2.AsValue(1), pongo2.AsValue(2))
+	c.Check(err, IsNil)
+	c.Check(result.Integer(), Equals, 3)
+	_, err = fn.Call(pongo2.AsValue(1))
+	c.Check(err, ErrorMatches, ".*Function input argument count \\(2\\).*")
+
+	c.Check(pongo2.AsValue(42).IsCallable(), Equals, false)
+	_, err = pongo2.AsValue(42).Call()
+	c.Check(err, ErrorMatches, "value is not callable \\(it is int\\)")
+}
+
+func (s *TestSuite) TestIsEmpty(c *C) {
+	ctx := pongo2.Context{
+		"emptymap":   map[string]int{},
+		"emptyslice": []string{},
+		"nilptr":     (*post)(nil),
+		"strct":      post{},
+		"zero":       0,
+		"zerofloat":  0.0,
+	}
+	out, err := testSuite2.RenderTemplateString("{{ emptymap is empty }} {{ emptyslice is empty }} {{ nilptr is empty }} "+
+		"{{ strct is empty }} {{ zero is empty }} {{ zerofloat is empty }} {{ zero is not empty }}", ctx)
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "True True True False False False True")
+
+	_, err = testSuite2.FromString("{{ x is emptyy }}")
+	c.Check(err, ErrorMatches, ".*Unknown test 'emptyy'.*")
+	_, err = testSuite2.FromString("{{ x is }}")
+	c.Check(err, ErrorMatches, ".*Expected a test name \\(like 'empty'\\) after 'is'.*")
+}
+
+type recordingProfiler struct {
+	mu      sync.Mutex
+	records []string
+}
+
+func (p *recordingProfiler) Record(name string, d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.records = append(p.records, name)
+}
+
+func (s *TestSuite) TestProfiler(c *C) {
+	profiler := &recordingProfiler{}
+	set := pongo2.NewSet("profiler set", pongo2.MustNewLocalFileSystemLoader(""))
+	set.Debug = true
+	set.Profiler = profiler
+
+	tpl, err := set.FromString(`{% for i in range(0, 2) %}{% include "template_tests/includes.helper" with what_am_i="a" %}{% endfor %}{{ "no tag" }}`)
+	c.Assert(err, IsNil)
+	out, err := tpl.Execute(nil)
+	c.Assert(err, IsNil)
+	c.Check(out, Equals, "I'm aI'm ano tag")
+	// Nested tags are recorded before the enclosing tag
+	c.Check(profiler.records, DeepEquals, []string{"include", "include", "for"})
+
+	// The profiler isn't used if debug mode is disabled
+	profiler.records = nil
+	set.Debug = false
+	_, err = tpl.Execute(nil)
+	c.Assert(err, IsNil)
+	c.Check(profiler.records, IsNil)
+}
+
+func (s *TestSuite) TestContextFromValues(c *C) {
+	values, err := url.ParseQuery("name=john&tag=a&tag=b&empty=")
+	c.Assert(err, IsNil)
+	ctx := pongo2.ContextFromValues(values)
+	c.Check(ctx, DeepEquals, pongo2.Context{
+		"name":  "john",
+		"tag":   []string{"a", "b"},
+		"empty": "",
+	})
+
+	out, err := testSuite2.RenderTemplateString(`{{ name }}:{% for t in tag %}{{ t }}{% endfor %}:{{ tag|length }}:[{{ empty }}]`, ctx)
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "john:ab:2:[]")
+
+	c.Check(pongo2.ContextFromValues(url.Values{"novalue": {}}), DeepEquals, pongo2.Context{"novalue": ""})
+	c.Check(pongo2.ContextFromValues(nil), DeepEquals, pongo2.Context{})
+}
+
+func (s *TestSuite) TestIncludeFallbacks(c *C) {
+	tpl, err := testSuite2.FromString(`{% include candidates with what_am_i="themed" %}`)
+	if err != nil {
+		c.Fatal(err)
+	}
+
+	// A list of filenames given by the context is tried in order
+	out, err := tpl.Execute(pongo2.Context{
+		"number":     1,
+		"candidates": []string{"template_tests/custom/includes.helper", "template_tests/includes.helper"},
+	})
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "I'm themed1")
+
+	_, err = tpl.Execute(pongo2.Context{"candidates": []string{}})
+	c.Check(err, ErrorMatches, ".*evaluated to an empty list.*")
+}
+
+func (s *TestSuite) TestStriptags(c *C) {
+	in := "<ul>\n  <li>One</li>\n\n  <li>Two   <em>and</em>\tthree</li>\n</ul>"
+	out, err := pongo2.ApplyFilter("striptags", pongo2.AsValue(in), nil)
+	c.Check(err, IsNil)
+	c.Check(out.String(), Equals, "One\nTwo and three")
+
+	// The result gets escaped on output, the entities of '<', '>' and '&'
+	// are kept (so they can't become markup, even if marked as safe)
+	tpl, err2 := pongo2.FromString("{{ html|striptags }}|{{ html|striptags|safe }}")
+	if err2 != nil {
+		c.Fatal(err2)
+	}
+	rendered, err2 := tpl.Execute(pongo2.Context{"html": "<b>&lt;script&gt;&#60;b&#x3e;&amp;lt;</b> caf&eacute;"})
+	c.Check(err2, IsNil)
+	c.Check(rendered, Equals, "&amp;lt;script&amp;gt;&amp;#60;b&amp;#x3e;&amp;amp;lt; café|&lt;script&gt;&#60;b&#x3e;&amp;lt; café")
+}
+
+func (s *TestSuite) TestInResolvedAttributes(c *C) {
+	type account struct {
+		Roles []string
+		Meta  map[string]interface{}
+	}
+	ctx := pongo2.Context{
+		"user": &account{
+			Roles: []string{"admin", "editor"},
+			Meta:  map[string]interface{}{"groups": []interface{}{"staff", 42}, "team": "core"},
+		},
+	}
+
+	tests := map[string]string{
+		// a struct field holding a slice
+		`{% if "admin" in user.Roles %}yes{% else %}no{% endif %}`:            "yes",
+		`{% if "guest" in user.Roles %}yes{% else %}no{% endif %}`:            "no",
+		`{% if "guest" not in user.Roles %}yes{% else %}no{% endif %}`:        "yes",
+		`{% if "admin" in user.Roles|slice:":1" %}yes{% else %}no{% endif %}`: "yes",
+
+		// a struct field holding a map (and the items within it)
+		`{% if "team" in user.Meta %}yes{% else %}no{% endif %}`:          "yes",
+		`{% if "owner" in user.Meta %}yes{% else %}no{% endif %}`:         "no",
+		`{% if "staff" in user.Meta.groups %}yes{% else %}no{% endif %}`:  "yes",
+		`{% if 42 in user.Meta.groups %}yes{% else %}no{% endif %}`:       "yes",
+		`{% if "or" in user.Meta.team %}yes{% else %}no{% endif %}`:       "yes",
+		`{% if "admin" in user.Meta.missing %}yes{% else %}no{% endif %}`: "no",
+	}
+	for src, expected := range tests {
+		tpl, err := pongo2.FromString(src)
+		if err != nil {
+			c.Fatal(err)
+		}
+		out, err := tpl.Execute(ctx)
+		c.Check(err, IsNil)
+		c.Check(out, Equals, expected, Commentf("template: %s", src))
+	}
+}
+
+func (s *TestSuite) TestNumericStrings(c *C) {
+	ctx := pongo2.Context{"age": "21", "price": " 18.5 ", "name": "john"}
+	src := `{% if age > 18 %}adult{% else %}minor{% endif %}|{{ age + 1 }}|{% if price > 18 %}more{% else %}less{% endif %}|{{ price * 2 }}|{{ -age }}|{% if age == 21 %}equal{% else %}not equal{% endif %}`
+
+	// Numeric strings are converted by default
+	set := pongo2.NewSet("numeric strings", pongo2.MustNewLocalFileSystemLoader(""))
+	tpl, err := set.FromString(src)
+	if err != nil {
+		c.Fatal(err)
+	}
+	out, err := tpl.Execute(ctx)
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "adult|22|more|37.000000|-21|not equal")
+
+	// Non-numeric strings behave as before
+	tpl, err = set.FromString(`{% if name > 18 %}yes{% else %}no{% endif %}`)
+	if err != nil {
+		c.Fatal(err)
+	}
+	out, err = tpl.Execute(ctx)
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "no")
+
+	// Strict numbers
+	set = pongo2.NewSet("strict numbers", pongo2.MustNewLocalFileSystemLoader(""))
+	set.StrictNumbers = true
+	tpl, err = set.FromString(`{% if age > 18 %}adult{% else %}minor{% endif %}`)
+	if err != nil {
+		c.Fatal(err)
+	}
+	_, err = tpl.Execute(ctx)
+	c.Check(err, ErrorMatches, ".*Operator '>' requires numbers, got the string '21' \\(StrictNumbers is enabled\\).*")
+	out, err = tpl.Execute(pongo2.Context{"age": 21})
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "adult")
+
+	tpl, err = set.FromString(`{{ age + 1 }}`)
+	if err != nil {
+		c.Fatal(err)
+	}
+	_, err = tpl.Execute(ctx)
+	c.Check(err, ErrorMatches, ".*Operator '\\+' requires numbers.*")
+}
+
+func (s *TestSuite) TestPureFilters(c *C) {
+	calls := 0
+	counting := func(in *pongo2.Value, param *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
+		calls++
+		return pongo2.AsValue(strings.ToUpper(fmt.Sprint(in.Interface())) + param.String()), nil
+	}
+	if pongo2.FilterExists("test_pure_counting") {
+		c.Assert(pongo2.ReplaceFilter("test_pure_counting", counting), IsNil)
+	} else {
+		c.Assert(pongo2.RegisterFilter("test_pure_counting", counting), IsNil)
+	}
+	c.Assert(pongo2.MarkFilterPure("test_pure_counting"), IsNil)
+	c.Check(pongo2.MarkFilterPure("test_pure_not_existing"), ErrorMatches, ".*does not exist.*")
+
+	tpl, err := pongo2.FromString(`{{ "a"|test_pure_counting }}{% for i in range(0, 3) %}{{ "a"|test_pure_counting }}{{ name|test_pure_counting:"!" }}{% endfor %}{{ "b"|test_pure_counting }}{{ items|test_pure_counting }}{{ items|test_pure_counting }}`)
+	if err != nil {
+		c.Fatal(err)
+	}
+	ctx := pongo2.Context{"name": "a", "items": []string{"x"}}
+	out, err := tpl.Execute(ctx)
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "AAA!AA!AA!B[X][X]")
+	// "a", "a" with "!" and "b" once each, the (uncacheable) slice twice
+	c.Check(calls, Equals, 5)
+
+	// The cache is per execution
+	_, err = tpl.Execute(ctx)
+	c.Check(err, IsNil)
+	c.Check(calls, Equals, 10)
+
+	// Replacing the filter removes the mark
+	c.Assert(pongo2.ReplaceFilter("test_pure_counting", counting), IsNil)
+	tpl, err = pongo2.FromString(`{{ "a"|test_pure_counting }}{{ "a"|test_pure_counting }}`)
+	if err != nil {
+		c.Fatal(err)
+	}
+	calls = 0
+	_, err = tpl.Execute(ctx)
+	c.Check(err, IsNil)
+	c.Check(calls, Equals, 2)
+}
+
+func (s *TestSuite) TestIfAssignment(c *C) {
+	calls := 0
+	expensive := func() []string {
+		calls++
+		return []string{"a", "b"}
+	}
+
+	tpl, err := pongo2.FromString(`{% if (result := expensive()) %}{{ result|join:"," }} ({{ result|length }}){% endif %}[{{ result }}]`)
+	if err != nil {
+		c.Fatal(err)
+	}
+	out, err := tpl.Execute(pongo2.Context{"expensive": expensive})
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "a,b (2)[]")
+	c.Check(calls, Equals, 1)
+}
+
+type recordingLogger struct {
+	debug    []string
+	warnings []string
+}
+
+func (l *recordingLogger) Debugf(format string, args ...interface{}) {
+	l.debug = append(l.debug, fmt.Sprintf(format, args...))
+}
+
+func (l *recordingLogger) Warnf(format string, args ...interface{}) {
+	l.warnings = append(l.warnings, fmt.Sprintf(format, args...))
+}
+
+func (s *TestSuite) TestIncludeWithDebugLog(c *C) {
+	logger := &recordingLogger{}
+	set := pongo2.NewSet("logging set", pongo2.MustNewLocalFileSystemLoader(""))
+	set.Debug = true
+	set.Logger = logger
+
+	tpl, err := set.FromString(`{% include "template_tests/includes.helper" with what_am_i="guest" number=2 extra=1 %}`)
+	c.Assert(err, IsNil)
+	out, err := tpl.Execute(pongo2.Context{"what_am_i": "user", "number": 1})
+	c.Assert(err, IsNil)
+	c.Check(out, Equals, "I'm guest2")
+	c.Check(logger.debug, DeepEquals, []string{"include in <string>: 'with' overrides 'number' (was: '1', now: '2'), 'what_am_i' (was: 'user', now: 'guest')"})
+
+	// Nothing is logged without debug mode
+	logger.debug = nil
+	set.Debug = false
+	_, err = tpl.Execute(pongo2.Context{"what_am_i": "user"})
+	c.Assert(err, IsNil)
+	c.Check(logger.debug, IsNil)
+}
+
+func (s *TestSuite) TestLoggerWarnings(c *C) {
+	logger := &recordingLogger{}
+	set := pongo2.NewSet("warning set", pongo2.MustNewLocalFileSystemLoader(""))
+	set.Logger = logger
+
+	tpl, err := set.FromString(`Hello {{ name }}{{ user.name|default:"guest" }}{% if forloop %}{% endif %}{% include missing_file if_exists %}`)
+	c.Assert(err, IsNil)
+	out, err := tpl.Execute(pongo2.Context{"missing_file": "template_tests/not_existing.helper"})
+	c.Assert(err, IsNil)
+	c.Check(out, Equals, "Hello guest")
+	c.Check(logger.warnings, DeepEquals, []string{
+		"<string>: variable 'name' is not defined (line 1, column 10)",
+		"<string>: variable 'user.name' is not defined (line 1, column 20)",
+		"<string>: variable 'forloop' is not defined (line 1, column 54)",
+		"<string>: template to include (if_exists) not found: 'template_tests/not_existing.helper'",
+	})
+	c.Check(logger.debug, IsNil)
+
+	// Defined variables (even nil ones) don't lead to warnings
+	logger.warnings = nil
+	tpl, err = set.FromString(`{{ name }}{{ pongo2.version|length > 0 }}{% include "template_tests/not_existing.helper" if_exists %}`)
+	c.Assert(err, IsNil)
+	c.Check(logger.warnings, DeepEquals, []string{"<string>: template to include (if_exists) not found: 'template_tests/not_existing.helper'"})
+	_, err = tpl.Execute(pongo2.Context{"name": nil})
+	c.Assert(err, IsNil)
+	c.Check(logger.warnings, HasLen, 1)
+}
+
+func (s *TestSuite) TestWhitespaceControlPositions(c *C) {
+	// Error positions are still correct after trimmed whitespace
+	_, err := pongo2.FromString("{{ \"a\" -}}\n\n  {# comment #}\n  {{ missing|nofilter }}")
+	c.Check(err, ErrorMatches, `.*Line 4 Col 14.*Filter 'nofilter' does not exist.*`)
+
+	tpl, err := pongo2.FromString("<ul>\n  {%- for i in items %}\n  <li>{{ i }}</li>\n  {%- endfor %}\n</ul>")
+	if err != nil {
+		c.Fatal(err)
+	}
+	out, err := tpl.Execute(pongo2.Context{"items": []int{1, 2}})
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "<ul>\n  <li>1</li>\n  <li>2</li>\n</ul>")
+}
+
+type treeNode struct {
+	Name     string
+	Children []*treeNode
+}
+
+func (s *TestSuite) TestForRecursive(c *C) {
+	tree := []*treeNode{
+		{Name: "a", Children: []*treeNode{{Name: "a1"}, {Name: "a2"}}},
+		{Name: "b"},
+	}
+
+	tpl, err := pongo2.FromString(`<ul>{% for node in tree recursive %}<li>{{ node.Name }} ({{ forloop.Depth }}/{{ forloop.Depth0 }}){% if node.Children %}<ul>{{ forloop.Loop(node.Children) }}</ul>{% endif %}</li>{% endfor %}</ul>`)
+	if err != nil {
+		c.Fatal(err)
+	}
+	out, err := tpl.Execute(pongo2.Context{"tree": tree})
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "<ul><li>a (1/0)<ul><li>a1 (2/1)</li><li>a2 (2/1)</li></ul></li><li>b (1/0)</li></ul>")
+
+	// Cycles are stopped at the maximum recursion depth
+	cycle := &treeNode{Name: "cycle"}
+	cycle.Children = []*treeNode{cycle}
+	_, err = tpl.Execute(pongo2.Context{"tree": cycle.Children})
+	c.Check(err, ErrorMatches, `.*forloop.Loop\(\) exceeded the maximum recursion depth of 100.*`)
+
+	// Loop() is only available in recursive loops
+	tpl, err = pongo2.FromString(`{% for node in tree %}{{ forloop.Loop(node.Children) }}{% endfor %}`)
+	if err != nil {
+		c.Fatal(err)
+	}
+	_, err = tpl.Execute(pongo2.Context{"tree": tree})
+	c.Check(err, ErrorMatches, `.*forloop.Loop\(\) can only be used within a recursive for-loop.*`)
+}
+
+func (s *TestSuite) TestRegisterFunction(c *C) {
+	set := pongo2.NewSet("functions", pongo2.MustNewLocalFileSystemLoader(""))
+	c.Check(set.RegisterFunction("asset", func(name string) string {
+		return "/static/" + name
+	}), IsNil)
+	c.Check(set.RegisterFunction("asset", func() {}), ErrorMatches, "function with name 'asset' is already registered")
+	c.Check(set.RegisterFunction("not-valid", func() {}), ErrorMatches, "function name 'not-valid' is not a valid identifier")
+	c.Check(set.RegisterFunction("nofunc", "text"), ErrorMatches, "function 'nofunc' must be a func, got string")
+
+	tpl, err := set.FromString(`{{ asset("logo.png") }}|{% with asset="shadowed" %}{{ asset }}{% endwith %}|{{ asset("app.css")|upper }}`)
+	if err != nil {
+		c.Fatal(err)
+	}
+	out, err := tpl.Execute(nil)
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "/static/logo.png|shadowed|/STATIC/APP.CSS")
+
+	// The function is resolved before the data context
+	out, err = tpl.Execute(pongo2.Context{"asset": func(name string) string { return name }})
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "/static/logo.png|shadowed|/STATIC/APP.CSS")
+
+	// Other sets are not affected
+	out, err = pongo2.RenderTemplateString(`{{ asset("logo.png") }}`, nil)
+	c.Check(err, IsNil)
+	c.Check(out, Equals, "")
+}
+
+func (s *TestSuite) TestSliceFilter(c *C) {
+	// Arrays (which can't be sliced using reflect unless addressable)
+	arr := [5]int{1, 2, 3, 4, 5}
+	for spec, expected := range map[string]string{
+		"1:3":    "2,3",
+		":-1":    "1,2,3,4",
+		"::2":    "1,3,5",
+		"::-2":   "5,3,1",
+		"3:1:-1": "4,3",
+		"9:":     "",
+	} {
+		out, err := pongo2.ApplyFilter("slice", pongo2.AsValue(arr), pongo2.AsValue(spec))
+		c.Assert(err, IsNil)
+		joined, err := pongo2.ApplyFilter("join", out, pongo2.AsValue(","))
+		c.Assert(err, IsNil)
+		c.Check(joined.String(), Equals, expected, Commentf("spec %q", spec))
+	}
+
+	// The slice type is retained
+	out, err := pongo2.ApplyFilter("slice", pongo2.AsValue([]string{"a", "b", "c"}), pongo2.AsValue("::-1"))
+	c.Assert(err, IsNil)
+	c.Check(out.Interface(), DeepEquals, []string{"c", "b", "a"})
+
+	// Other values are returned unchanged
+	out, err = pongo2.ApplyFilter("slice", pongo2.AsValue(42), pongo2.AsValue("1:2"))
+	c.Assert(err, IsNil)
+	c.Check(out.Integer(), Equals, 42)
+}
+
+func (s *TestSuite) TestValueIterate(c *C) {
+	collect := func(in interface{}) (items []string, emptyCalled bool) {
+		pongo2.AsValue(in).Iterate(func(idx, count int, key, value *pongo2.Value) bool {
+			item := fmt.Sprintf("%d/%d:%s", idx, count, key.String())
+			if value != nil {
+				item += "=" + value.String()
+			}
+			items = append(items, item)
+			return true
+		}, func() {
+			emptyCalled = true
+		})
+		return
+	}
+
+	items, empty := collect([]int{7, 8})
+	c.Check(items, DeepEquals, []string{"0/2:7", "1/2:8"})
+	c.Check(empty, Equals, false)
+
+	items, _ = collect([2]string{"a", "b"})
+	c.Check(items, DeepEquals, []string{"0/2:a", "1/2:b"})
+
+	// Items of interface types (like the ones of list literals) are unpacked
+	items, _ = collect([]interface{}{1, "b", nil, true})
+	c.Check(items, DeepEquals, []string{"0/4:1", "1/4:b", "2/4:", "3/4:True"})
+	items, _ = collect(map[interface{}]interface{}{"x": 1})
+	c.Check(items, DeepEquals, []string{"0/1:x=1"})
+
+	// Strings are iterated by rune
+	items, _ = collect("aä世")
+	c.Check(items, DeepEquals, []string{"0/3:a", "1/3:ä", "2/3:世"})
+
+	// Maps have no order
+	items, _ = collect(map[string]int{"x": 1, "y": 2})
+	for i := range items {
+		items[i] = items[i][strings.Index(items[i], ":")+1:]
+	}
+	sort.Strings(items)
+	c.Check(items, DeepEquals, []string{"x=1", "y=2"})
+
+	ch := make(chan int, 2)
+	ch <- 1
+	ch <- 2
+	close(ch)
+	items, _ = collect(ch)
+	c.Check(items, DeepEquals, []string{"0/-1:1", "1/-1:2"})
+
+	// The empty function is called for empty and non-iterable values
+	for _, in := range []interface{}{[]int{}, "", map[string]int{}, 42, nil} {
+		items, empty = collect(in)
+		c.Check(items, IsNil)
+		c.Check(empty, Equals, true, Commentf("%#v", in))
+	}
+
+	// Returning false stops the iteration
+	var seen []int
+	pongo2.AsValue([]int{1, 2, 3}).Iterate(func(idx, count int, key, value *pongo2.Value) bool {
+		seen = append(seen, key.Integer())
+		return key.Integer() < 2
+	}, func() {})
+	c.Check(seen, DeepEquals, []int{1, 2})
+
+	// Sorting and reversing strings works rune by rune as well
+	var runes []string
+	pongo2.AsValue("cäb").IterateOrder(func(idx, count int, key, value *pongo2.Value) bool {
+		runes = append(runes, key.String())
+		return true
+	}, func() {}, true, true)
+	c.Check(runes, DeepEquals, []string{"ä", "c", "b"})
+
+	// Items of interface types are sorted by their underlying values
+	var numbers []int
+	pongo2.AsValue([]interface{}{10, 2, 33}).IterateOrder(func(idx, count int, key, value *pongo2.Value) bool {
+		numbers = append(numbers, key.Integer())
+		return true
+	}, func() {}, false, true)
+	c.Check(numbers, DeepEquals, []int{2, 10, 33})
+}
+
+func (s *TestSuite) TestForEvaluatesIterableOnce(c *C) {
+	calls := 0
+	counting := func(in *pongo2.Value, param *pongo2.Value) (*pongo2.Value, *pongo2.Error) {
+		calls++
+		return in, nil
+	}
+	if pongo2.FilterExists("count_evaluations") {
+		c.Assert(pongo2.ReplaceFilter("count_evaluations", counting), IsNil)
+	} else {
+		c.Assert(pongo2.RegisterFilter("count_evaluations", counting), IsNil)
+	}
+
+	ctx := pongo2.Context{"items": []map[string]int{{"n": 1}, {"n": 2}, {"n": 3}}}
+	for tpl, expected := range map[string]string{
+		`{% for item in items|count_evaluations %}{{ item.n }}{% if forloop.Last %}!{% endif %}{% endfor %}`: "123!",
+		`{% for item in items|count_evaluations reversed %}{{ item.n }}{% endfor %}`:                         "321",
+		`{% for item in items|count_evaluations if item.n > 1 limit 1 %}{{ item.n }}{% endfor %}`:            "2",
+		`{% for item in items|count_evaluations %}{% for j in items %}{% endfor %}{{ item.n }}{% endfor %}`:  "123",
+		`{% for item in items|count_evaluations|slice:"5:" %}{{ item.n }}{% empty %}empty{% endfor %}`:       "empty",
+	} {
+		calls = 0
+		out, err := pongo2.RenderTemplateString(tpl, ctx)
+		c.Check(err, IsNil)
+		c.Check(out, Equals, expected)
+		c.Check(calls, Equals, 1, Commentf("%s", tpl))
+	}
+}
+
+func (s *TestSuite) TestPooledBuffersConcurrently(c *C) {
+	// Every render must only see its own output although the buffers of the
+	// buffering tags are reused
+	tpl, err := pongo2.FromString(`{% macro m(v) %}[{{ v }}]{% endmacro %}` +
+		`{% for i in items %}{% spaceless %}<b> {{ m(name) }} </b>{% endspaceless %}{% filter lower %}{{ name }}{% endfilter %}{% endfor %}`)
+	if err != nil {
+		c.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan string, 20)
+	for g := 0; g < 20; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			name := fmt.Sprintf("N%d", g)
+			expected := strings.Repeat("<b> ["+name+"] </b>"+strings.ToLower(name), 50)
+			for i := 0; i < 20; i++ {
+				out, err := tpl.Execute(pongo2.Context{"items": make([]int, 50), "name": name})
+				if err != nil || out != expected {
+					errs <- fmt.Sprintf("goroutine %d: %v %q", g, err, out)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for e := range errs {
+		c.Error(e)
+	}
+}
+
+type namedLabel struct{ Name string }
+
+type methodLabel struct{ title string }
+
+func (l methodLabel) Name() string { return "method " + l.title }
+
+type embeddedLabel struct {
+	Extra int
+	namedLabel
+}
+
+func (s *TestSuite) TestAttributeLookupTypeChanges(c *C) {
+	// The lookup of item.Name is cached for the type seen first and must fall
+	// back to the dynamic lookup for every other type
+	tpl, err := pongo2.FromString("{% for item in items %}[{{ item.Name }}]{% endfor %}")
+	if err != nil {
+		c.Fatal(err)
+	}
+
+	var nilLabel *namedLabel
+	items := []interface{}{
+		namedLabel{"a"},
+		&namedLabel{"b"},
+		methodLabel{"c"},
+		embeddedLabel{1, namedLabel{"d"}},
+		map[string]string{"Name": "e"},
+		namedLabel{"f"},
+		nilLabel,
+		methodLabel{"g"},
+	}
+	expected := "[a][b][method c][d][e][f][][method g]"
+	for i := 0; i < 2; i++ {
+		out, err := tpl.Execute(pongo2.Context{"items": items})
+		if err != nil {
+			c.Fatal(err)
+		}
+		c.Check(out, Equals, expected)
+	}
+}
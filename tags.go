@@ -1,11 +1,6 @@
 package pongo2
 
-/* Incomplete:
-   -----------
-
-   verbatim (only the "name" argument is missing for verbatim)
-
-   Reconsideration:
+/* Reconsideration:
    ----------------
 
    debug (reason: not sure what to output yet)
@@ -14,13 +9,15 @@ package pongo2
    Following built-in tags wont be added:
    --------------------------------------
 
-   csrf_token (reason: web-framework specific)
    load (reason: python-specific)
    url (reason: web-framework specific)
 */
 
 import (
 	"fmt"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/juju/errors"
 )
@@ -51,6 +48,40 @@ type tag struct {
 
 var tags map[string]*tag
 
+// tagsMutex guards tags
+var tagsMutex sync.RWMutex
+
+// Profiler records the execution time of tags. Set it on a TemplateSet using
+// the Profiler field (it's only used in debug mode). Record is called after
+// each tag's execution (including the execution time of nested tags) with
+// the tag's name (e. g. "include" or "for").
+type Profiler interface {
+	Record(name string, d time.Duration)
+}
+
+// tagNode keeps track of a tag's position within the template to
+// provide position information on errors returned during execution.
+type tagNode struct {
+	position *Token
+	name     string
+	node     INodeTag
+}
+
+func (tn *tagNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	if ctx.template != nil {
+		if set := ctx.template.set; set.Debug && set.Profiler != nil {
+			start := time.Now()
+			defer func() { set.Profiler.Record(tn.name, time.Since(start)) }()
+		}
+	}
+
+	err := tn.node.Execute(ctx, writer)
+	if err != nil {
+		return err.updateFromTokenIfNeeded(ctx.template, tn.position)
+	}
+	return nil
+}
+
 func init() {
 	tags = make(map[string]*tag)
 }
@@ -62,6 +93,8 @@ func init() {
 // See http://www.florian-schlachter.de/post/pongo2/ for more about
 // writing filters and tags.
 func RegisterTag(name string, parserFn TagParser) error {
+	tagsMutex.Lock()
+	defer tagsMutex.Unlock()
 	_, existing := tags[name]
 	if existing {
 		return errors.Errorf("tag with name '%s' is already registered", name)
@@ -76,6 +109,8 @@ func RegisterTag(name string, parserFn TagParser) error {
 // Replaces an already registered tag with a new implementation. Use this
 // function with caution since it allows you to change existing tag behaviour.
 func ReplaceTag(name string, parserFn TagParser) error {
+	tagsMutex.Lock()
+	defer tagsMutex.Unlock()
 	_, existing := tags[name]
 	if !existing {
 		return errors.Errorf("tag with name '%s' does not exist (therefore cannot be overridden)", name)
@@ -87,6 +122,27 @@ func ReplaceTag(name string, parserFn TagParser) error {
 	return nil
 }
 
+// getTag returns the registered tag.
+func getTag(name string) (*tag, bool) {
+	tagsMutex.RLock()
+	defer tagsMutex.RUnlock()
+	t, existing := tags[name]
+	return t, existing
+}
+
+// RegisteredTags returns the sorted names of all registered tags.
+func RegisteredTags() []string {
+	tagsMutex.RLock()
+	names := make([]string, 0, len(tags))
+	for name := range tags {
+		names = append(names, name)
+	}
+	tagsMutex.RUnlock()
+
+	sort.Strings(names)
+	return names
+}
+
 // Tag = "{%" IDENT ARGS "%}"
 func (p *Parser) parseTagElement() (INodeTag, *Error) {
 	p.Consume() // consume "{%"
@@ -98,7 +154,7 @@ func (p *Parser) parseTagElement() (INodeTag, *Error) {
 	}
 
 	// Check for the existing tag
-	tag, exists := tags[tokenName.Val]
+	tag, exists := getTag(tokenName.Val)
 	if !exists {
 		// Does not exists
 		return nil, p.Error(fmt.Sprintf("Tag '%s' not found (or beginning tag not provided)", tokenName.Val), tokenName)
@@ -131,5 +187,9 @@ func (p *Parser) parseTagElement() (INodeTag, *Error) {
 
 	p.template.level++
 	defer func() { p.template.level-- }()
-	return tag.parser(p, tokenName, argParser)
+	node, err := tag.parser(p, tokenName, argParser)
+	if err != nil {
+		return nil, err
+	}
+	return &tagNode{position: tokenName, name: tokenName.Val, node: node}, nil
 }
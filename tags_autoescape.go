@@ -3,18 +3,22 @@ package pongo2
 type tagAutoescapeNode struct {
 	wrapper    *NodeWrapper
 	autoescape bool
+	mode       AutoescapeMode
 }
 
 func (node *tagAutoescapeNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
-	old := ctx.Autoescape
+	old, oldMode := ctx.Autoescape, ctx.AutoescapeMode
 	ctx.Autoescape = node.autoescape
+	if node.autoescape {
+		ctx.AutoescapeMode = node.mode
+	}
 
 	err := node.wrapper.Execute(ctx, writer)
 	if err != nil {
 		return err
 	}
 
-	ctx.Autoescape = old
+	ctx.Autoescape, ctx.AutoescapeMode = old, oldMode
 
 	return nil
 }
@@ -32,12 +36,17 @@ func tagAutoescapeParser(doc *Parser, start *Token, arguments *Parser) (INodeTag
 	if modeToken == nil {
 		return nil, arguments.Error("A mode is required for autoescape-tag.", nil)
 	}
-	if modeToken.Val == "on" {
+	switch modeToken.Val {
+	case "on", "html":
+		autoescapeNode.autoescape = true
+		autoescapeNode.mode = AutoescapeHTML
+	case "json":
 		autoescapeNode.autoescape = true
-	} else if modeToken.Val == "off" {
+		autoescapeNode.mode = AutoescapeJSON
+	case "off", "none":
 		autoescapeNode.autoescape = false
-	} else {
-		return nil, arguments.Error("Only 'on' or 'off' is valid as an autoescape-mode.", nil)
+	default:
+		return nil, arguments.Error("Only 'on', 'off', 'html', 'json' or 'none' is valid as an autoescape-mode.", nil)
 	}
 
 	if arguments.Remaining() > 0 {
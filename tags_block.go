@@ -7,6 +7,11 @@ import (
 
 type tagBlockNode struct {
 	name string
+
+	// Only applies if the template is extended: a required block must be
+	// overridden, an optional block renders nothing unless it's overridden.
+	required bool
+	optional bool
 }
 
 func (node *tagBlockNode) getBlockWrappers(tpl *Template) []*NodeWrapper {
@@ -38,19 +43,50 @@ func (node *tagBlockNode) Execute(ctx *ExecutionContext, writer TemplateWriter)
 		return ctx.Error("internal error: len(block_wrappers) == 0 in tagBlockNode.Execute()", nil)
 	}
 
+	if (node.required || node.optional) && lenBlockWrappers == 1 {
+		if definingTpl := node.getBlockTemplate(tpl, blockWrappers[0]); definingTpl.child != nil {
+			if node.required {
+				return ctx.Error(fmt.Sprintf("Block '%s' is required and must be overridden by templates extending '%s'.",
+					node.name, definingTpl.name), nil)
+			}
+			return nil
+		}
+	}
+
 	blockWrapper := blockWrappers[lenBlockWrappers-1]
 	ctx.Private["block"] = tagBlockInformation{
 		ctx:      ctx,
 		wrappers: blockWrappers[0 : lenBlockWrappers-1],
 	}
+
+	annotate := tpl.set.annotate()
+	if annotate {
+		writer.WriteString(fmt.Sprintf("<!-- begin block %s (%s) -->", node.name,
+			node.getBlockTemplate(tpl, blockWrapper).name))
+	}
+
 	err := blockWrapper.Execute(ctx, writer)
 	if err != nil {
 		return err
 	}
 
+	if annotate {
+		writer.WriteString("<!-- end -->")
+	}
+
 	return nil
 }
 
+// getBlockTemplate returns the template in which the given block wrapper is defined.
+func (node *tagBlockNode) getBlockTemplate(tpl *Template, wrapper *NodeWrapper) *Template {
+	for t := tpl; t != nil; t = t.child {
+		if t.blocks[node.name] == wrapper {
+			return t
+		}
+	}
+	return tpl
+}
+
 type tagBlockInformation struct {
 	ctx      *ExecutionContext
 	wrappers []*NodeWrapper
@@ -88,8 +124,14 @@ func tagBlockParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Er
 		return nil, arguments.Error("First argument for tag 'block' must be an identifier.", nil)
 	}
 
+	blockNode := &tagBlockNode{name: nameToken.Val}
+	if modifier := arguments.MatchOne(TokenIdentifier, "required", "optional"); modifier != nil {
+		blockNode.required = modifier.Val == "required"
+		blockNode.optional = modifier.Val == "optional"
+	}
+
 	if arguments.Remaining() != 0 {
-		return nil, arguments.Error("Tag 'block' takes exactly 1 argument (an identifier).", nil)
+		return nil, arguments.Error("Tag 'block' takes exactly 1 argument (an identifier) and an optional modifier ('required' or 'optional').", nil)
 	}
 
 	wrapper, endtagargs, err := doc.WrapUntilTag("endblock")
@@ -121,7 +163,7 @@ func tagBlockParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Er
 		return nil, arguments.Error(fmt.Sprintf("Block named '%s' already defined", nameToken.Val), nil)
 	}
 
-	return &tagBlockNode{name: nameToken.Val}, nil
+	return blockNode, nil
 }
 
 func init() {
@@ -0,0 +1,130 @@
+package pongo2
+
+import (
+	"fmt"
+)
+
+// callBlock is passed by the call-tag as last argument to the macro, which
+// provides caller() within its body.
+type callBlock struct {
+	caller macroFunction
+}
+
+type tagCallNode struct {
+	position *Token
+	macro    *variableResolver // e. g. panel or helpers.panel (not called)
+	args     []IEvaluator
+	wrapper  *NodeWrapper
+}
+
+func (node *tagCallNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	macro, err := node.macro.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+	fn, isMacro := macro.Interface().(macroFunction)
+	if !isMacro {
+		return ctx.Error(fmt.Sprintf("'%s' is not a macro (call-tag).", node.macro.String()), node.position)
+	}
+
+	args := make([]*Value, 0, len(node.args)+1)
+	for _, arg := range node.args {
+		value, err := arg.Evaluate(ctx)
+		if err != nil {
+			return err
+		}
+		args = append(args, value)
+	}
+
+	// The body is rendered within the scope of the call-tag (not the macro's)
+	var bodyErr *Error
+	args = append(args, AsValue(&callBlock{
+		caller: func(args ...*Value) *Value {
+			b := getBuffer()
+			defer putBuffer(b)
+			bodyCtx := NewChildExecutionContext(ctx)
+			bodyCtx.isolated = true
+			if err := node.wrapper.Execute(bodyCtx, b); err != nil {
+				if bodyErr == nil {
+					bodyErr = err
+				}
+				return AsValue("")
+			}
+			return AsSafeValue(b.String())
+		},
+	}))
+
+	out := fn(args...)
+	if bodyErr != nil {
+		return bodyErr
+	}
+	writer.WriteString(out.String())
+	return nil
+}
+
+// tagCallParser parses a call block which lets the macro render the block's
+// body using caller():
+//     {% macro panel(title) %}<div class="panel"><h2>{{ title }}</h2>{{ caller() }}</div>{% endmacro %}
+//     {% call panel("News") %}<p>Content</p>{% endcall %}
+func tagCallParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
+	callNode := &tagCallNode{
+		position: start,
+	}
+
+	// The macro's name (which might be namespaced by an import)
+	nameToken := arguments.MatchType(TokenIdentifier)
+	if nameToken == nil {
+		return nil, arguments.Error("Call-tag needs the name of a macro.", nil)
+	}
+	callNode.macro = &variableResolver{
+		locationToken: nameToken,
+		parts:         []*variablePart{{typ: varTypeIdent, s: nameToken.Val}},
+		macroAsValue:  true,
+	}
+	for arguments.Match(TokenSymbol, ".") != nil {
+		partToken := arguments.MatchType(TokenIdentifier)
+		if partToken == nil {
+			return nil, arguments.Error("Expected an identifier after '.'.", nil)
+		}
+		callNode.macro.parts = append(callNode.macro.parts, &variablePart{typ: varTypeIdent, s: partToken.Val})
+	}
+
+	if arguments.Match(TokenSymbol, "(") == nil {
+		return nil, arguments.Error("Expected '('.", nil)
+	}
+	for arguments.Match(TokenSymbol, ")") == nil {
+		arg, err := arguments.ParseExpression()
+		if err != nil {
+			return nil, err
+		}
+		markMacroAsValue(arg)
+		callNode.args = append(callNode.args, arg)
+
+		if arguments.Match(TokenSymbol, ")") != nil {
+			break
+		}
+		if arguments.Match(TokenSymbol, ",") == nil {
+			return nil, arguments.Error("Expected ',' or ')'.", nil)
+		}
+	}
+
+	if arguments.Remaining() > 0 {
+		return nil, arguments.Error("Malformed call-tag.", nil)
+	}
+
+	wrapper, endargs, err := doc.WrapUntilTag("endcall")
+	if err != nil {
+		return nil, err
+	}
+	callNode.wrapper = wrapper
+
+	if endargs.Count() > 0 {
+		return nil, endargs.Error("Arguments not allowed here.", nil)
+	}
+
+	return callNode, nil
+}
+
+func init() {
+	RegisterTag("call", tagCallParser)
+}
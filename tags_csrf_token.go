@@ -0,0 +1,59 @@
+package pongo2
+
+import (
+	"fmt"
+)
+
+// CSRFProvider provides the CSRF token rendered by the 'csrf_token'-tag.
+// Set it on a TemplateSet using the CSRFProvider field.
+type CSRFProvider interface {
+	Token(ctx *ExecutionContext) string
+}
+
+type tagCSRFTokenNode struct {
+	position *Token
+}
+
+func (node *tagCSRFTokenNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	var token string
+	if provider := ctx.template.set.CSRFProvider; provider != nil {
+		token = provider.Token(ctx)
+	} else {
+		// Fall back to the token provided through the context
+		val, has := ctx.Private["csrf_token"]
+		if !has {
+			val = ctx.Public["csrf_token"]
+		}
+		token = AsValue(val).String()
+	}
+
+	if token == "" {
+		ctx.Logf("csrf_token-tag used, but no CSRF token available (neither CSRFProvider nor context key 'csrf_token' set)")
+		return nil
+	}
+
+	escaped, err := filterEscape(AsValue(token), nil)
+	if err != nil {
+		return err
+	}
+
+	writer.WriteString(fmt.Sprintf(`<input type="hidden" name="csrfmiddlewaretoken" value="%s">`, escaped.String()))
+
+	return nil
+}
+
+func tagCSRFTokenParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
+	csrfNode := &tagCSRFTokenNode{
+		position: start,
+	}
+
+	if arguments.Remaining() > 0 {
+		return nil, arguments.Error("Tag 'csrf_token' does not take any argument.", nil)
+	}
+
+	return csrfNode, nil
+}
+
+func init() {
+	RegisterTag("csrf_token", tagCSRFTokenParser)
+}
@@ -8,7 +8,6 @@ type tagCycleValue struct {
 type tagCycleNode struct {
 	position *Token
 	args     []IEvaluator
-	idx      int
 	asName   string
 	silent   bool
 }
@@ -17,9 +16,25 @@ func (cv *tagCycleValue) String() string {
 	return cv.value.String()
 }
 
+// next returns the next value of the cycle. The position is kept per
+// execution, so concurrent executions of a template don't interfere.
+func (node *tagCycleNode) next(ctx *ExecutionContext) IEvaluator {
+	root := ctx.root()
+	if root.cycles == nil {
+		root.cycles = make(map[*tagCycleNode]int)
+	}
+	idx := root.cycles[node]
+	root.cycles[node] = idx + 1
+	return node.args[idx%len(node.args)]
+}
+
+// reset lets the cycle start with its first value again.
+func (node *tagCycleNode) reset(ctx *ExecutionContext) {
+	delete(ctx.root().cycles, node)
+}
+
 func (node *tagCycleNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
-	item := node.args[node.idx%len(node.args)]
-	node.idx++
+	item := node.next(ctx)
 
 	val, err := item.Evaluate(ctx)
 	if err != nil {
@@ -31,8 +46,7 @@ func (node *tagCycleNode) Execute(ctx *ExecutionContext, writer TemplateWriter)
 		// {% cycle cycleitem %}
 
 		// Update the cycle value with next value
-		item := t.node.args[t.node.idx%len(t.node.args)]
-		t.node.idx++
+		item := t.node.next(ctx)
 
 		val, err := item.Evaluate(ctx)
 		if err != nil {
@@ -53,6 +67,9 @@ func (node *tagCycleNode) Execute(ctx *ExecutionContext, writer TemplateWriter)
 		}
 
 		if node.asName != "" {
+			if err := ctx.checkAssignable(node.asName, node.position); err != nil {
+				return err
+			}
 			ctx.Private[node.asName] = cycleValue
 		}
 		if !node.silent {
@@ -98,6 +115,14 @@ func tagCycleParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Er
 		return nil, arguments.Error("Malformed cycle-tag.", nil)
 	}
 
+	// Remember the cycle for a later resetcycle-tag
+	if doc.template != nil {
+		doc.template.lastCycle = cycleNode
+		if cycleNode.asName != "" {
+			doc.template.namedCycles[cycleNode.asName] = cycleNode
+		}
+	}
+
 	return cycleNode, nil
 }
 
@@ -0,0 +1,95 @@
+package pongo2
+
+import (
+	"fmt"
+)
+
+type tagEmbedNode struct {
+	filename string
+	tpl      *Template // holds the block overrides; its parent is the embedded template
+}
+
+func (node *tagEmbedNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	// The embedded template sees the current context (like the include-tag)
+	embedCtx := make(Context)
+	embedCtx.Update(ctx.Public)
+	embedCtx.Update(ctx.Private)
+
+	annotate := ctx.template.set.annotate()
+	if annotate {
+		writer.WriteString(fmt.Sprintf("<!-- begin embed (%s) -->", node.filename))
+	}
+
+	if err := executeSubTemplate(ctx, node.tpl, embedCtx, writer, true); err != nil {
+		return err
+	}
+
+	if annotate {
+		writer.WriteString("<!-- end -->")
+	}
+	return nil
+}
+
+// embedTemplate returns a template extending tpl which overrides the given
+// blocks. The inheritance chain of tpl is copied since the (cached) templates
+// might be extended or embedded elsewhere, too.
+func embedTemplate(tpl *Template, name string, blocks map[string]*NodeWrapper) *Template {
+	overrides := &Template{
+		set:            tpl.set,
+		name:           name,
+		size:           tpl.size,
+		blocks:         blocks,
+		exportedMacros: make(map[string]*tagMacroNode),
+	}
+
+	child := overrides
+	for t := tpl; t != nil; t = t.parent {
+		parent := *t
+		parent.child = child
+		child.parent = &parent
+		child = &parent
+	}
+
+	return overrides
+}
+
+func tagEmbedParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
+	embedNode := &tagEmbedNode{}
+
+	filenameToken := arguments.MatchType(TokenString)
+	if filenameToken == nil {
+		return nil, arguments.Error("Tag 'embed' requires a template filename as string.", nil)
+	}
+	if arguments.Remaining() > 0 {
+		return nil, arguments.Error("Tag 'embed' does only take 1 argument.", nil)
+	}
+
+	embedNode.filename = doc.template.set.resolveFilename(doc.template, filenameToken.Val)
+	embeddedTpl, err := doc.template.set.FromCache(embedNode.filename)
+	if err != nil {
+		return nil, err.(*Error).updateFromTokenIfNeeded(doc.template, filenameToken)
+	}
+
+	// The blocks within the embed-tag override the embedded template's blocks
+	// and must not be registered as blocks of the current template.
+	outerBlocks := doc.template.blocks
+	doc.template.blocks = make(map[string]*NodeWrapper)
+	_, endargs, wrapErr := doc.WrapUntilTag("endembed")
+	blocks := doc.template.blocks
+	doc.template.blocks = outerBlocks
+	if wrapErr != nil {
+		return nil, wrapErr
+	}
+	if endargs.Count() > 0 {
+		return nil, endargs.Error("Arguments not allowed here.", nil)
+	}
+
+	// Everything outside of the blocks is ignored (like in templates using extends)
+	embedNode.tpl = embedTemplate(embeddedTpl, doc.template.name, blocks)
+
+	return embedNode, nil
+}
+
+func init() {
+	RegisterTag("embed", tagEmbedParser)
+}
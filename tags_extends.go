@@ -1,5 +1,10 @@
 package pongo2
 
+import (
+	"fmt"
+	"strings"
+)
+
 type tagExtendsNode struct {
 	filename string
 }
@@ -20,7 +25,31 @@ func tagExtendsParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *
 		return nil, arguments.Error("This template has already one parent.", start)
 	}
 
+	// Either one filename or a list of them (the first parent which exists is used)
+	var filenameTokens []*Token
 	if filenameToken := arguments.MatchType(TokenString); filenameToken != nil {
+		filenameTokens = append(filenameTokens, filenameToken)
+	} else if arguments.Match(TokenSymbol, "[") != nil {
+		for {
+			filenameToken := arguments.MatchType(TokenString)
+			if filenameToken == nil {
+				return nil, arguments.Error("Tag 'extends' requires a list of template filenames as strings.", nil)
+			}
+			filenameTokens = append(filenameTokens, filenameToken)
+
+			if arguments.Match(TokenSymbol, "]") != nil {
+				break
+			}
+			if arguments.Match(TokenSymbol, ",") == nil {
+				return nil, arguments.Error("Expected ',' or ']' in the list of parent templates.", nil)
+			}
+		}
+	} else {
+		return nil, arguments.Error("Tag 'extends' requires a template filename as string.", nil)
+	}
+
+	var tried []string
+	for _, filenameToken := range filenameTokens {
 		// prepared, static template
 
 		// Get parent's filename
@@ -29,6 +58,11 @@ func tagExtendsParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *
 		// Parse the parent
 		parentTemplate, err := doc.template.set.FromFile(parentFilename)
 		if err != nil {
+			if len(filenameTokens) > 1 && err.(*Error).Sender == "fromfile" {
+				// Try the next candidate
+				tried = append(tried, filenameToken.Val)
+				continue
+			}
 			return nil, err.(*Error)
 		}
 
@@ -36,8 +70,11 @@ func tagExtendsParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *
 		parentTemplate.child = doc.template
 		doc.template.parent = parentTemplate
 		extendsNode.filename = parentFilename
-	} else {
-		return nil, arguments.Error("Tag 'extends' requires a template filename as string.", nil)
+		break
+	}
+	if doc.template.parent == nil {
+		return nil, arguments.Error(fmt.Sprintf("None of the parent templates exists (tried: '%s').",
+			strings.Join(tried, "', '")), start)
 	}
 
 	if arguments.Remaining() > 0 {
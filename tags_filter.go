@@ -1,24 +1,38 @@
 package pongo2
 
 import (
-	"bytes"
+	"fmt"
 )
 
 type nodeFilterCall struct {
 	name      string
 	paramExpr IEvaluator
+	alias     []*filterCall
 }
 
 type tagFilterNode struct {
 	position    *Token
 	bodyWrapper *NodeWrapper
 	filterChain []*nodeFilterCall
+
+	// escapes is true if the filter chain contains the escape filter
+	escapes bool
 }
 
 func (node *tagFilterNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
-	temp := bytes.NewBuffer(make([]byte, 0, 1024)) // 1 KiB size
-
+	temp := getBuffer()
+	defer putBuffer(temp)
+
+	// Like Django, the body isn't autoescaped if the chain escapes the
+	// content itself; otherwise the output of variables would be escaped twice
+	noAutoescape := node.escapes && ctx.Autoescape && ctx.AutoescapeMode == AutoescapeHTML
+	if noAutoescape {
+		ctx.Autoescape = false
+	}
 	err := node.bodyWrapper.Execute(ctx, temp)
+	if noAutoescape {
+		ctx.Autoescape = true
+	}
 	if err != nil {
 		return err
 	}
@@ -26,6 +40,14 @@ func (node *tagFilterNode) Execute(ctx *ExecutionContext, writer TemplateWriter)
 	value := AsValue(temp.String())
 
 	for _, call := range node.filterChain {
+		if call.alias != nil {
+			value, err = applyFilterChain(ctx, call.alias, value)
+			if err != nil {
+				return ctx.Error(err.Error(), node.position)
+			}
+			continue
+		}
+
 		var param *Value
 		if call.paramExpr != nil {
 			param, err = call.paramExpr.Evaluate(ctx)
@@ -35,7 +57,7 @@ func (node *tagFilterNode) Execute(ctx *ExecutionContext, writer TemplateWriter)
 		} else {
 			param = AsValue(nil)
 		}
-		value, err = ApplyFilter(call.name, value, param)
+		value, err = applyFilterWithContext(ctx, call.name, value, param)
 		if err != nil {
 			return ctx.Error(err.Error(), node.position)
 		}
@@ -65,8 +87,15 @@ func tagFilterParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *E
 			return nil, arguments.Error("Expected a filter name (identifier).", nil)
 		}
 		filterCall.name = nameToken.Val
+		if doc.template != nil {
+			filterCall.alias = doc.template.filterAliases[nameToken.Val]
+		}
 
 		if arguments.MatchOne(TokenSymbol, ":") != nil {
+			if filterCall.alias != nil {
+				return nil, arguments.Error(fmt.Sprintf("Filter alias '%s' does not take a parameter.", nameToken.Val), nil)
+			}
+
 			// Filter parameter
 			// NOTICE: we can't use ParseExpression() here, because it would parse the next filter "|..." as well in the argument list
 			expr, err := arguments.parseVariableOrLiteral()
@@ -77,6 +106,14 @@ func tagFilterParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *E
 		}
 
 		filterNode.filterChain = append(filterNode.filterChain, filterCall)
+		if filterCall.alias == nil && filterCall.name == "escape" {
+			filterNode.escapes = true
+		}
+		for _, call := range filterCall.alias {
+			if call.applies("escape") {
+				filterNode.escapes = true
+			}
+		}
 
 		if arguments.MatchOne(TokenSymbol, "|") == nil {
 			break
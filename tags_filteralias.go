@@ -0,0 +1,54 @@
+package pongo2
+
+import (
+	"fmt"
+)
+
+type tagFilterAliasNode struct{}
+
+func (node *tagFilterAliasNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	// The alias is registered (and resolved) at parse time
+	return nil
+}
+
+// filteralias defines an alias for a filter chain which can be used like a
+// regular filter afterwards, for example:
+//     {% filteralias money = floatformat:2|stringformat:"$%s" %}
+//     {{ price|money }}
+// The alias is only valid within the template which defines it (starting with
+// its definition). An alias defined within a block (like with or for) is only
+// valid until the end of that block.
+func tagFilterAliasParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
+	if doc.template == nil {
+		return nil, arguments.Error("Tag 'filteralias' can only be used within a template.", nil)
+	}
+
+	nameToken := arguments.MatchType(TokenIdentifier)
+	if nameToken == nil {
+		return nil, arguments.Error("Expected an alias name (identifier).", nil)
+	}
+	if FilterExists(nameToken.Val) {
+		return nil, arguments.Error(fmt.Sprintf("Filter alias '%s' would shadow an existing filter.", nameToken.Val), nameToken)
+	}
+
+	if arguments.Match(TokenSymbol, "=") == nil {
+		return nil, arguments.Error("Expected '='.", nil)
+	}
+
+	chain, err := arguments.parseFilterChain()
+	if err != nil {
+		return nil, err
+	}
+
+	if arguments.Remaining() > 0 {
+		return nil, arguments.Error("Malformed filteralias-tag arguments.", nil)
+	}
+
+	doc.template.filterAliases[nameToken.Val] = chain
+
+	return &tagFilterAliasNode{}, nil
+}
+
+func init() {
+	RegisterTag("filteralias", tagFilterAliasParser)
+}
@@ -1,34 +1,93 @@
 package pongo2
 
+import (
+	"fmt"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
 type tagForNode struct {
 	key             string
-	value           string // only for maps: for key, value in map
+	value           string   // only for maps: for key, value in map
+	moreValues      []string // more variables to unpack tuples into: for a, b, c in tuples
 	objectEvaluator IEvaluator
 	reversed        bool
 	sorted          bool
+	limitEvaluator  IEvaluator // optional: limit N
+	offsetEvaluator IEvaluator // optional: offset M
+	condition       IEvaluator // optional: if <condition> (skips the items it's false for)
+	recursive       bool       // forloop.Loop(items) renders the body for items
 
 	bodyWrapper  *NodeWrapper
 	emptyWrapper *NodeWrapper
 }
 
+// tagForLoopInformation is available as 'forloop' within the for-tag's body.
+// Slices, arrays, maps and strings have a known length. When iterating over
+// a channel the length is unknown, so Revcounter and Revcounter0 are always
+// 0 and Last is never true.
 type tagForLoopInformation struct {
-	Counter     int
-	Counter0    int
-	Revcounter  int
-	Revcounter0 int
+	Counter     int // the current iteration (1-indexed)
+	Counter0    int // the current iteration (0-indexed)
+	Revcounter  int // number of iterations from the end of the loop (1-indexed)
+	Revcounter0 int // number of iterations from the end of the loop (0-indexed)
 	First       bool
 	Last        bool
+	Depth       int // the level of recursion (1-indexed, see Loop)
+	Depth0      int // the level of recursion (0-indexed)
 	Parentloop  *tagForLoopInformation
+
+	// Renders the loop for other items (recursive loops only)
+	recurse func(items *Value) (*Value, *Error)
+}
+
+// maxForLoopDepth limits the recursion of recursive for-loops (see Loop).
+const maxForLoopDepth = 100
+
+// Loop renders the body of a recursive for-loop ({% for ... recursive %})
+// for the given items, e. g. to render a tree:
+// {% for node in tree recursive %}{{ node.Name }}{{ forloop.Loop(node.Children) }}{% endfor %}
+func (loop *tagForLoopInformation) Loop(items *Value) (*Value, error) {
+	if loop.recurse == nil {
+		return nil, errors.New("forloop.Loop() can only be used within a recursive for-loop")
+	}
+	if loop.Depth >= maxForLoopDepth {
+		return nil, errors.Errorf("forloop.Loop() exceeded the maximum recursion depth of %d", maxForLoopDepth)
+	}
+	out, err := loop.recurse(items)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
-func (node *tagForNode) Execute(ctx *ExecutionContext, writer TemplateWriter) (forError *Error) {
+// Cycle returns the argument at index Counter0 % len(args), i. e. it cycles
+// through the given values on each iteration (a stateless alternative to
+// the cycle-tag): {{ forloop.Cycle("odd", "even") }}
+func (loop *tagForLoopInformation) Cycle(args ...*Value) (*Value, error) {
+	if len(args) == 0 {
+		return nil, errors.New("forloop.Cycle() requires at least one argument")
+	}
+	return args[loop.Counter0%len(args)], nil
+}
+
+func (node *tagForNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	return node.execute(ctx, writer, nil, 1)
+}
+
+// execute runs the loop over the given items (or the loop's object if items
+// is nil) at the given level of recursion.
+func (node *tagForNode) execute(ctx *ExecutionContext, writer TemplateWriter, items *Value, depth int) (forError *Error) {
 	// Backup forloop (as parentloop in public context), key-name and value-name
 	forCtx := NewChildExecutionContext(ctx)
 	parentloop := forCtx.Private["forloop"]
 
 	// Create loop struct
 	loopInfo := &tagForLoopInformation{
-		First: true,
+		First:  true,
+		Depth:  depth,
+		Depth0: depth - 1,
 	}
 
 	// Is it a loop in a loop?
@@ -36,32 +95,84 @@ func (node *tagForNode) Execute(ctx *ExecutionContext, writer TemplateWriter) (f
 		loopInfo.Parentloop = parentloop.(*tagForLoopInformation)
 	}
 
+	if node.recursive {
+		loopInfo.recurse = func(items *Value) (*Value, *Error) {
+			// The body is rendered within the current iteration's scope
+			b := getBuffer()
+			defer putBuffer(b)
+			if err := node.execute(forCtx, b, items, depth+1); err != nil {
+				return nil, err
+			}
+			return AsSafeValue(b.String()), nil
+		}
+	}
+
 	// Register loopInfo in public context
 	forCtx.Private["forloop"] = loopInfo
 
-	obj, err := node.objectEvaluator.Evaluate(forCtx)
+	obj := items
+	if obj == nil {
+		var err *Error
+		obj, err = node.objectEvaluator.Evaluate(forCtx)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Only a view of the items is iterated if limit and/or offset are given
+	offset, err := node.evaluateModifier(forCtx, node.offsetEvaluator, "offset")
+	if err != nil {
+		return err
+	}
+	limit, err := node.evaluateModifier(forCtx, node.limitEvaluator, "limit")
 	if err != nil {
 		return err
 	}
+	if offset < 0 {
+		offset = 0
+	}
+	viewEmpty := true
 
-	obj.IterateOrder(func(idx, count int, key, value *Value) bool {
+	iteration := func(idx, count int, key, value *Value) bool {
 		// There's something to iterate over (correct type and at least 1 item)
 
+		if offset > 0 || limit >= 0 {
+			if idx < offset {
+				return true
+			}
+			idx -= offset
+			if count >= 0 {
+				count -= offset
+			}
+			if limit >= 0 {
+				if idx >= limit {
+					return false
+				}
+				if count >= 0 {
+					count = min(count, limit)
+				}
+			}
+		}
+		viewEmpty = false
+
 		// Update loop infos and public context
-		forCtx.Private[node.key] = key
-		if value != nil {
-			forCtx.Private[node.value] = value
+		if err := node.bind(forCtx, key, value); err != nil {
+			forError = err
+			return false
 		}
 		loopInfo.Counter = idx + 1
 		loopInfo.Counter0 = idx
 		if idx == 1 {
 			loopInfo.First = false
 		}
-		if idx+1 == count {
-			loopInfo.Last = true
+		if count >= 0 {
+			// The length is unknown for channels
+			if idx+1 == count {
+				loopInfo.Last = true
+			}
+			loopInfo.Revcounter = count - idx
+			loopInfo.Revcounter0 = count - (idx + 1)
 		}
-		loopInfo.Revcounter = count - idx        // TODO: Not sure about this, have to look it up
-		loopInfo.Revcounter0 = count - (idx + 1) // TODO: Not sure about this, have to look it up
 
 		// Render elements with updated context
 		err := node.bodyWrapper.Execute(forCtx, writer)
@@ -69,20 +180,126 @@ func (node *tagForNode) Execute(ctx *ExecutionContext, writer TemplateWriter) (f
 			forError = err
 			return false
 		}
+		flushWriter(writer)
 		return true
-	}, func() {
+	}
+	empty := func() {
 		// Nothing to iterate over (maybe wrong type or no items)
+		viewEmpty = false // handled here
 		if node.emptyWrapper != nil {
 			err := node.emptyWrapper.Execute(forCtx, writer)
 			if err != nil {
 				forError = err
 			}
 		}
-	}, node.reversed, node.sorted)
+	}
+
+	if node.condition == nil {
+		obj.IterateOrder(iteration, empty, node.reversed, node.sorted)
+	} else {
+		// The items are selected first, so the loop infos (like forloop.Last)
+		// only take the selected items into account
+		items, err := node.selectItems(forCtx, obj)
+		if err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			empty()
+		}
+		for idx, item := range items {
+			if !iteration(idx, len(items), item[0], item[1]) {
+				break
+			}
+		}
+	}
+
+	if viewEmpty && forError == nil && node.emptyWrapper != nil {
+		// limit/offset left nothing to iterate over
+		forError = node.emptyWrapper.Execute(forCtx, writer)
+	}
 
 	return forError
 }
 
+// bind sets the loop variable(s) to the current item.
+func (node *tagForNode) bind(ctx *ExecutionContext, key, value *Value) *Error {
+	if value == nil && node.value != "" {
+		// Unpack tuples (like the zip-filter's output) into the loop variables:
+		// {% for a, b in list1|zip:list2 %}
+		return node.unpack(ctx, key)
+	}
+
+	if value != nil && len(node.moreValues) > 0 {
+		return ctx.Error(fmt.Sprintf("Cannot unpack a key and a value into %d loop variables.",
+			len(node.moreValues)+2), nil)
+	}
+
+	ctx.Private[node.key] = key
+	if value != nil {
+		ctx.Private[node.value] = value
+	}
+	return nil
+}
+
+// unpack assigns the items of a tuple (slice or array) to the loop variables
+// (one item per variable).
+func (node *tagForNode) unpack(ctx *ExecutionContext, tuple *Value) *Error {
+	names := append([]string{node.key, node.value}, node.moreValues...)
+	if !tuple.CanSlice() || tuple.IsString() {
+		return ctx.Error(fmt.Sprintf("Cannot unpack '%s' into %d loop variables (a list is required).",
+			tuple.String(), len(names)), nil)
+	}
+	if tuple.Len() != len(names) {
+		return ctx.Error(fmt.Sprintf("Cannot unpack %d values into %d loop variables ('%s').",
+			tuple.Len(), len(names), strings.Join(names, "', '")), nil)
+	}
+
+	for i, name := range names {
+		ctx.Private[name] = tuple.Index(i)
+	}
+	return nil
+}
+
+// selectItems returns the items (key and value) for which the loop's
+// condition is true.
+func (node *tagForNode) selectItems(ctx *ExecutionContext, obj *Value) ([][2]*Value, *Error) {
+	var items [][2]*Value
+	var condErr *Error
+	obj.IterateOrder(func(idx, count int, key, value *Value) bool {
+		if err := node.bind(ctx, key, value); err != nil {
+			condErr = err
+			return false
+		}
+		result, err := node.condition.Evaluate(ctx)
+		if err != nil {
+			condErr = err
+			return false
+		}
+		if result.IsTrue() {
+			items = append(items, [2]*Value{key, value})
+		}
+		return true
+	}, func() {}, node.reversed, node.sorted)
+	return items, condErr
+}
+
+// evaluateModifier evaluates the limit- or offset-modifier. It returns -1 if the
+// modifier is not given.
+func (node *tagForNode) evaluateModifier(ctx *ExecutionContext, evaluator IEvaluator, name string) (int, *Error) {
+	if evaluator == nil {
+		return -1, nil
+	}
+	val, err := evaluator.Evaluate(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if !val.IsInteger() || val.Integer() < 0 {
+		return 0, ctx.Error(fmt.Sprintf("The for-loop's %s must be a non-negative integer (got: '%s').", name, val.String()),
+			evaluator.GetPositionToken())
+	}
+	return val.Integer(), nil
+}
+
 func tagForParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
 	forNode := &tagForNode{}
 
@@ -99,6 +316,15 @@ func tagForParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Erro
 		if valueToken == nil {
 			return nil, arguments.Error("Value name must be an identifier.", nil)
 		}
+
+		// More names to unpack tuples into
+		for arguments.Match(TokenSymbol, ",") != nil {
+			nameToken := arguments.MatchType(TokenIdentifier)
+			if nameToken == nil {
+				return nil, arguments.Error("Value name must be an identifier.", nil)
+			}
+			forNode.moreValues = append(forNode.moreValues, nameToken.Val)
+		}
 	}
 
 	if arguments.Match(TokenKeyword, "in") == nil {
@@ -123,6 +349,36 @@ func tagForParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Erro
 		forNode.sorted = true
 	}
 
+	if arguments.MatchOne(TokenIdentifier, "recursive") != nil {
+		forNode.recursive = true
+	}
+
+	// Optional modifiers (in any order): if <condition> limit N offset M
+	for arguments.Remaining() > 0 {
+		modifierToken := arguments.MatchOne(TokenIdentifier, "if", "limit", "offset")
+		if modifierToken == nil {
+			return nil, arguments.Error("Malformed for-loop arguments.", nil)
+		}
+		if (modifierToken.Val == "if" && forNode.condition != nil) ||
+			(modifierToken.Val == "limit" && forNode.limitEvaluator != nil) ||
+			(modifierToken.Val == "offset" && forNode.offsetEvaluator != nil) {
+			return nil, arguments.Error(fmt.Sprintf("Modifier '%s' given twice.", modifierToken.Val), modifierToken)
+		}
+
+		expr, err := arguments.ParseExpression()
+		if err != nil {
+			return nil, err
+		}
+		switch modifierToken.Val {
+		case "if":
+			forNode.condition = expr
+		case "limit":
+			forNode.limitEvaluator = expr
+		default:
+			forNode.offsetEvaluator = expr
+		}
+	}
+
 	if arguments.Remaining() > 0 {
 		return nil, arguments.Error("Malformed for-loop arguments.", nil)
 	}
@@ -3,9 +3,17 @@ package pongo2
 type tagIfNode struct {
 	conditions []IEvaluator
 	wrappers   []*NodeWrapper
+
+	// The conditions contain assignment expressions: their variables are
+	// bound within a scope of the if-tag
+	assigns bool
 }
 
 func (node *tagIfNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	if node.assigns {
+		ctx = NewChildExecutionContext(ctx)
+	}
+
 	for i, condition := range node.conditions {
 		result, err := condition.Evaluate(ctx)
 		if err != nil {
@@ -27,11 +35,13 @@ func tagIfParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error
 	ifNode := &tagIfNode{}
 
 	// Parse first and main IF condition
+	arguments.allowAssignments = true
 	condition, err := arguments.ParseExpression()
 	if err != nil {
 		return nil, err
 	}
 	ifNode.conditions = append(ifNode.conditions, condition)
+	ifNode.assigns = arguments.assigned
 
 	if arguments.Remaining() > 0 {
 		return nil, arguments.Error("If-condition is malformed.", nil)
@@ -47,11 +57,13 @@ func tagIfParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error
 
 		if wrapper.Endtag == "elif" {
 			// elif can take a condition
+			tagArgs.allowAssignments = true
 			condition, err = tagArgs.ParseExpression()
 			if err != nil {
 				return nil, err
 			}
 			ifNode.conditions = append(ifNode.conditions, condition)
+			ifNode.assigns = ifNode.assigns || tagArgs.assigned
 
 			if tagArgs.Remaining() > 0 {
 				return nil, tagArgs.Error("Elif-condition is malformed.", nil)
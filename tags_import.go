@@ -5,63 +5,72 @@ import (
 )
 
 type tagImportNode struct {
-	position *Token
-	filename string
-	macros   map[string]*tagMacroNode // alias/name -> macro instance
+	position  *Token
+	filename  string
+	namespace string                   // only set for {% import "file" as namespace %}
+	macros    map[string]*tagMacroNode // alias/name -> macro instance
 }
 
 func (node *tagImportNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	target := ctx.Private
+	if node.namespace != "" {
+		target = make(Context)
+		ctx.Private[node.namespace] = target
+	}
+
 	for name, macro := range node.macros {
 		func(name string, macro *tagMacroNode) {
-			ctx.Private[name] = func(args ...*Value) *Value {
+			target[name] = macroFunction(func(args ...*Value) *Value {
 				return macro.call(ctx, args...)
-			}
+			})
 		}(name, macro)
 	}
 	return nil
 }
 
-func tagImportParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
-	importNode := &tagImportNode{
-		position: start,
-		macros:   make(map[string]*tagMacroNode),
-	}
-
+// parseImportFilename parses the filename of the template to import from
+// and compiles this template.
+func parseImportFilename(doc *Parser, start *Token, arguments *Parser, importNode *tagImportNode, tagName string) (*Template, *Error) {
 	filenameToken := arguments.MatchType(TokenString)
 	if filenameToken == nil {
-		return nil, arguments.Error("Import-tag needs a filename as string.", nil)
+		return nil, arguments.Error(fmt.Sprintf("%s-tag needs a filename as string.", tagName), nil)
 	}
 
 	importNode.filename = doc.template.set.resolveFilename(doc.template, filenameToken.Val)
 
-	if arguments.Remaining() == 0 {
-		return nil, arguments.Error("You must at least specify one macro to import.", nil)
-	}
-
 	// Compile the given template
 	tpl, err := doc.template.set.FromFile(importNode.filename)
 	if err != nil {
 		return nil, err.(*Error).updateFromTokenIfNeeded(doc.template, start)
 	}
+	return tpl, nil
+}
+
+// parseImportMacroList parses a list of macro names (with an optional alias):
+//     macro1, macro2 as alias2, ...
+func parseImportMacroList(arguments *Parser, tpl *Template, importNode *tagImportNode) *Error {
+	if arguments.Remaining() == 0 {
+		return arguments.Error("You must at least specify one macro to import.", nil)
+	}
 
 	for arguments.Remaining() > 0 {
 		macroNameToken := arguments.MatchType(TokenIdentifier)
 		if macroNameToken == nil {
-			return nil, arguments.Error("Expected macro name (identifier).", nil)
+			return arguments.Error("Expected macro name (identifier).", nil)
 		}
 
 		asName := macroNameToken.Val
 		if arguments.Match(TokenKeyword, "as") != nil {
 			aliasToken := arguments.MatchType(TokenIdentifier)
 			if aliasToken == nil {
-				return nil, arguments.Error("Expected macro alias name (identifier).", nil)
+				return arguments.Error("Expected macro alias name (identifier).", nil)
 			}
 			asName = aliasToken.Val
 		}
 
 		macroInstance, has := tpl.exportedMacros[macroNameToken.Val]
 		if !has {
-			return nil, arguments.Error(fmt.Sprintf("Macro '%s' not found (or not exported) in '%s'.", macroNameToken.Val,
+			return arguments.Error(fmt.Sprintf("Macro '%s' not found (or not exported) in '%s'.", macroNameToken.Val,
 				importNode.filename), macroNameToken)
 		}
 
@@ -72,8 +81,72 @@ func tagImportParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *E
 		}
 
 		if arguments.Match(TokenSymbol, ",") == nil {
-			return nil, arguments.Error("Expected ','.", nil)
+			return arguments.Error("Expected ','.", nil)
+		}
+	}
+
+	return nil
+}
+
+// Supported forms (only exported macros can be imported):
+//     {% import "forms.html" input, textarea as text %}
+//     {% import "forms.html" as forms %} (usage: {{ forms.input("q") }})
+func tagImportParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
+	importNode := &tagImportNode{
+		position: start,
+		macros:   make(map[string]*tagMacroNode),
+	}
+
+	tpl, err := parseImportFilename(doc, start, arguments, importNode, "Import")
+	if err != nil {
+		return nil, err
+	}
+
+	if arguments.Match(TokenKeyword, "as") != nil {
+		// Import all exported macros into a namespace
+		namespaceToken := arguments.MatchType(TokenIdentifier)
+		if namespaceToken == nil {
+			return nil, arguments.Error("Expected namespace name (identifier).", nil)
+		}
+		if arguments.Remaining() > 0 {
+			return nil, arguments.Error("Malformed import-tag arguments.", nil)
+		}
+		if len(tpl.exportedMacros) == 0 {
+			return nil, arguments.Error(fmt.Sprintf("'%s' does not export any macros.", importNode.filename), nil)
 		}
+
+		importNode.namespace = namespaceToken.Val
+		for name, macro := range tpl.exportedMacros {
+			importNode.macros[name] = macro
+		}
+		return importNode, nil
+	}
+
+	if err := parseImportMacroList(arguments, tpl, importNode); err != nil {
+		return nil, err
+	}
+
+	return importNode, nil
+}
+
+//     {% from "forms.html" import input, textarea as text %}
+func tagFromParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
+	importNode := &tagImportNode{
+		position: start,
+		macros:   make(map[string]*tagMacroNode),
+	}
+
+	tpl, err := parseImportFilename(doc, start, arguments, importNode, "From")
+	if err != nil {
+		return nil, err
+	}
+
+	if arguments.Match(TokenIdentifier, "import") == nil {
+		return nil, arguments.Error("Expected 'import'.", nil)
+	}
+
+	if err := parseImportMacroList(arguments, tpl, importNode); err != nil {
+		return nil, err
 	}
 
 	return importNode, nil
@@ -81,4 +154,5 @@ func tagImportParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *E
 
 func init() {
 	RegisterTag("import", tagImportParser)
+	RegisterTag("from", tagFromParser)
 }
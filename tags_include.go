@@ -1,13 +1,20 @@
 package pongo2
 
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
 type tagIncludeNode struct {
-	tpl               *Template
-	filenameEvaluator IEvaluator
-	lazy              bool
-	only              bool
-	filename          string
-	withPairs         map[string]IEvaluator
-	ifExists          bool
+	tpl                *Template
+	filenameEvaluators []IEvaluator // more than one for a list of fallbacks
+	lazy               bool
+	only               bool
+	filename           string
+	withPairs          map[string]IEvaluator
+	ifExists           bool
+	autoescape         bool // autoescape-mode of the included template (default on)
 }
 
 func (node *tagIncludeNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
@@ -21,47 +28,133 @@ func (node *tagIncludeNode) Execute(ctx *ExecutionContext, writer TemplateWriter
 	}
 
 	// Put all custom with-pairs into the context
+	var overridden []string // only tracked in debug mode
 	for key, value := range node.withPairs {
 		val, err := value.Evaluate(ctx)
 		if err != nil {
 			return err
 		}
+		if old, has := includeCtx[key]; has && ctx.template.set.Debug {
+			oldValue, isValue := old.(*Value)
+			if !isValue {
+				oldValue = AsValue(old)
+			}
+			overridden = append(overridden, fmt.Sprintf("'%s' (was: '%s', now: '%s')", key, oldValue.String(), val.String()))
+		}
 		includeCtx[key] = val
 	}
+	if len(overridden) > 0 {
+		sort.Strings(overridden)
+		ctx.Logf("include in %s: 'with' overrides %s", ctx.template.name, strings.Join(overridden, ", "))
+	}
 
 	// Execute the template
 	if node.lazy {
-		// Evaluate the filename
-		filename, err := node.filenameEvaluator.Evaluate(ctx)
+		// Evaluate the filename(s)
+		filenames, err := node.evaluateFilenames(ctx)
 		if err != nil {
 			return err
 		}
 
-		if filename.String() == "" {
-			return ctx.Error("Filename for 'include'-tag evaluated to an empty string.", nil)
-		}
+		// The first template which exists is included
+		var notFound *Error
+		for _, filename := range filenames {
+			// Get include-filename
+			includedFilename := ctx.template.set.resolveFilename(ctx.template, filename)
 
-		// Get include-filename
-		includedFilename := ctx.template.set.resolveFilename(ctx.template, filename.String())
-
-		includedTpl, err2 := ctx.template.set.FromFile(includedFilename)
-		if err2 != nil {
-			// if this is ReadFile error, and "if_exists" flag is enabled
-			if node.ifExists && err2.(*Error).Sender == "fromfile" {
-				return nil
+			// The included template is compiled only once (unless in debug mode)
+			includedTpl, err2 := ctx.template.set.FromCache(includedFilename)
+			if err2 != nil {
+				if err2.(*Error).Sender == "fromfile" {
+					notFound = err2.(*Error)
+					continue
+				}
+				return err2.(*Error)
 			}
-			return err2.(*Error)
+			return node.executeTemplate(ctx, includedTpl, includeCtx, writer)
+		}
+
+		// if "if_exists" flag is enabled, a missing template is no error
+		if node.ifExists {
+			ctx.template.set.warnf("%s: template to include (if_exists) not found: '%s'",
+				ctx.template.name, strings.Join(filenames, "', '"))
+			return nil
 		}
-		err2 = includedTpl.ExecuteWriter(includeCtx, writer)
-		if err2 != nil {
-			return err2.(*Error)
+		if len(filenames) > 1 {
+			return ctx.Error(fmt.Sprintf("None of the templates to include exists (tried: '%s').",
+				strings.Join(filenames, "', '")), nil)
 		}
-		return nil
+		return notFound
 	}
 	// Template is already parsed with static filename
-	err := node.tpl.ExecuteWriter(includeCtx, writer)
+	return node.executeTemplate(ctx, node.tpl, includeCtx, writer)
+}
+
+// evaluateFilenames returns the candidates to include in order. An expression
+// evaluating to a list contributes all of its items.
+func (node *tagIncludeNode) evaluateFilenames(ctx *ExecutionContext) ([]string, *Error) {
+	var filenames []string
+	for _, evaluator := range node.filenameEvaluators {
+		filename, err := evaluator.Evaluate(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if filename.CanSlice() && !filename.IsString() {
+			filename.Iterate(func(idx, count int, key, value *Value) bool {
+				filenames = append(filenames, key.String())
+				return true
+			}, func() {})
+		} else {
+			filenames = append(filenames, filename.String())
+		}
+	}
+
+	if len(filenames) == 0 {
+		return nil, ctx.Error("Filenames for 'include'-tag evaluated to an empty list.", nil)
+	}
+	for _, filename := range filenames {
+		if filename == "" {
+			return nil, ctx.Error("Filename for 'include'-tag evaluated to an empty string.", nil)
+		}
+	}
+	return filenames, nil
+}
+
+func (node *tagIncludeNode) executeTemplate(ctx *ExecutionContext, tpl *Template, includeCtx Context, writer TemplateWriter) *Error {
+	annotate := ctx.template.set.annotate()
+	if annotate {
+		writer.WriteString(fmt.Sprintf("<!-- begin include (%s) -->", tpl.name))
+	}
+
+	if err := executeSubTemplate(ctx, tpl, includeCtx, writer, node.autoescape); err != nil {
+		return err
+	}
+
+	if annotate {
+		writer.WriteString("<!-- end -->")
+	}
+	return nil
+}
+
+// executeSubTemplate executes tpl (e. g. an included template) with the given
+// context and autoescape-mode. Errors it recovered from are passed on to ctx.
+func executeSubTemplate(ctx *ExecutionContext, tpl *Template, subCtx Context, writer TemplateWriter, autoescape bool) *Error {
+	buf, err := tpl.newBufferAndExecute(subCtx, autoescape)
+	if buf != nil {
+		writer.Write(buf.Bytes())
+	}
 	if err != nil {
-		return err.(*Error)
+		recovered, ok := err.(RecoveredErrors)
+		if !ok {
+			return err.(*Error)
+		}
+		// The sub-template recovered from its errors (its output is
+		// already written), so just pass them on
+		if ctx.recovery == nil {
+			return recovered[0]
+		}
+		ctx.recovery.errors = append(ctx.recovery.errors, recovered...)
 	}
 	return nil
 }
@@ -74,7 +167,8 @@ func (node *tagIncludeEmptyNode) Execute(ctx *ExecutionContext, writer TemplateW
 
 func tagIncludeParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
 	includeNode := &tagIncludeNode{
-		withPairs: make(map[string]IEvaluator),
+		withPairs:  make(map[string]IEvaluator),
+		autoescape: true,
 	}
 
 	if filenameToken := arguments.MatchType(TokenString); filenameToken != nil {
@@ -88,26 +182,55 @@ func tagIncludeParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *
 
 		// Parse the parent
 		includeNode.filename = includedFilename
-		includedTpl, err := doc.template.set.FromFile(includedFilename)
+		includedTpl, err := doc.template.set.FromCache(includedFilename)
 		if err != nil {
 			// if this is ReadFile error, and "if_exists" token presents we should create and empty node
 			if err.(*Error).Sender == "fromfile" && ifExists {
+				doc.template.set.warnf("%s: template to include (if_exists) not found: '%s'",
+					doc.template.name, filenameToken.Val)
 				return &tagIncludeEmptyNode{}, nil
 			}
 			return nil, err.(*Error).updateFromTokenIfNeeded(doc.template, filenameToken)
 		}
 		includeNode.tpl = includedTpl
+	} else if arguments.Match(TokenSymbol, "[") != nil {
+		// A list of fallbacks: the first template which exists is included
+		for {
+			filenameEvaluator, err := arguments.ParseExpression()
+			if err != nil {
+				return nil, err
+			}
+			includeNode.filenameEvaluators = append(includeNode.filenameEvaluators, filenameEvaluator)
+
+			if arguments.Match(TokenSymbol, "]") != nil {
+				break
+			}
+			if arguments.Match(TokenSymbol, ",") == nil {
+				return nil, arguments.Error("Expected ',' or ']' in the list of templates to include.", nil)
+			}
+		}
+		includeNode.lazy = true
+		includeNode.ifExists = arguments.Match(TokenIdentifier, "if_exists") != nil // "if_exists" flag
 	} else {
 		// No String, then the user wants to use lazy-evaluation (slower, but possible)
 		filenameEvaluator, err := arguments.ParseExpression()
 		if err != nil {
 			return nil, err.updateFromTokenIfNeeded(doc.template, filenameToken)
 		}
-		includeNode.filenameEvaluator = filenameEvaluator
+		includeNode.filenameEvaluators = []IEvaluator{filenameEvaluator}
 		includeNode.lazy = true
 		includeNode.ifExists = arguments.Match(TokenIdentifier, "if_exists") != nil // "if_exists" flag
 	}
 
+	// The autoescape-mode for the included template only: autoescape on|off
+	if arguments.Match(TokenIdentifier, "autoescape") != nil {
+		modeToken := arguments.MatchOne(TokenIdentifier, "on", "off")
+		if modeToken == nil {
+			return nil, arguments.Error("Only 'on' or 'off' is valid as an autoescape-mode for 'include'.", nil)
+		}
+		includeNode.autoescape = modeToken.Val == "on"
+	}
+
 	// After having parsed the filename we're gonna parse the with+only options
 	if arguments.Match(TokenIdentifier, "with") != nil {
 		for arguments.Remaining() > 0 {
@@ -0,0 +1,88 @@
+package pongo2
+
+import (
+	"fmt"
+)
+
+type tagIncludeEachNode struct {
+	tpl             *Template
+	filename        string
+	objectEvaluator IEvaluator
+	name            string
+}
+
+func (node *tagIncludeEachNode) Execute(ctx *ExecutionContext, writer TemplateWriter) (includeError *Error) {
+	obj, err := node.objectEvaluator.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+
+	annotate := ctx.template.set.annotate()
+
+	obj.Iterate(func(idx, count int, key, value *Value) bool {
+		// Every render gets the current context with the element bound to name
+		includeCtx := make(Context)
+		includeCtx.Update(ctx.Public)
+		includeCtx.Update(ctx.Private)
+		includeCtx[node.name] = key
+
+		if annotate {
+			writer.WriteString(fmt.Sprintf("<!-- begin include (%s) -->", node.tpl.name))
+		}
+		if err := executeSubTemplate(ctx, node.tpl, includeCtx, writer, true); err != nil {
+			includeError = err
+			return false
+		}
+		if annotate {
+			writer.WriteString("<!-- end -->")
+		}
+		return true
+	}, func() {})
+
+	return includeError
+}
+
+func tagIncludeEachParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
+	includeEachNode := &tagIncludeEachNode{}
+
+	filenameToken := arguments.MatchType(TokenString)
+	if filenameToken == nil {
+		return nil, arguments.Error("Tag 'include_each' requires a template filename as string.", nil)
+	}
+
+	includeEachNode.filename = doc.template.set.resolveFilename(doc.template, filenameToken.Val)
+	includedTpl, err := doc.template.set.FromCache(includeEachNode.filename)
+	if err != nil {
+		return nil, err.(*Error).updateFromTokenIfNeeded(doc.template, filenameToken)
+	}
+	includeEachNode.tpl = includedTpl
+
+	if arguments.Match(TokenIdentifier, "for") == nil {
+		return nil, arguments.Error("Expected keyword 'for'.", nil)
+	}
+
+	objectEvaluator, perr := arguments.ParseExpression()
+	if perr != nil {
+		return nil, perr
+	}
+	includeEachNode.objectEvaluator = objectEvaluator
+
+	if arguments.Match(TokenKeyword, "as") == nil {
+		return nil, arguments.Error("Expected keyword 'as'.", nil)
+	}
+	nameToken := arguments.MatchType(TokenIdentifier)
+	if nameToken == nil {
+		return nil, arguments.Error("Expected an identifier after 'as'.", nil)
+	}
+	includeEachNode.name = nameToken.Val
+
+	if arguments.Remaining() > 0 {
+		return nil, arguments.Error("Malformed 'include_each'-tag arguments.", nil)
+	}
+
+	return includeEachNode, nil
+}
+
+func init() {
+	RegisterTag("include_each", tagIncludeEachParser)
+}
@@ -1,10 +1,15 @@
 package pongo2
 
 import (
-	"bytes"
 	"fmt"
 )
 
+// macroFunction is the type of macros in the context. Like other functions,
+// macros are called even without parentheses, unless they are passed as a
+// function argument or assigned (e. g. `{% set greet = greetings %}`), so
+// they can be passed around as values (see markMacroAsValue).
+type macroFunction func(args ...*Value) *Value
+
 type tagMacroNode struct {
 	position  *Token
 	name      string
@@ -16,14 +21,23 @@ type tagMacroNode struct {
 }
 
 func (node *tagMacroNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
-	ctx.Private[node.name] = func(args ...*Value) *Value {
+	ctx.Private[node.name] = macroFunction(func(args ...*Value) *Value {
 		return node.call(ctx, args...)
-	}
+	})
 
 	return nil
 }
 
 func (node *tagMacroNode) call(ctx *ExecutionContext, args ...*Value) *Value {
+	// The call-tag passes its body as last argument (see tagCallNode)
+	var block *callBlock
+	if len(args) > 0 {
+		if b, isBlock := args[len(args)-1].Interface().(*callBlock); isBlock {
+			block = b
+			args = args[:len(args)-1]
+		}
+	}
+
 	argsCtx := make(Context)
 
 	for k, v := range node.args {
@@ -53,6 +67,7 @@ func (node *tagMacroNode) call(ctx *ExecutionContext, args ...*Value) *Value {
 
 	// Make a context for the macro execution
 	macroCtx := NewChildExecutionContext(ctx)
+	macroCtx.isolated = true
 
 	// Register all arguments in the private context
 	macroCtx.Private.Update(argsCtx)
@@ -61,8 +76,13 @@ func (node *tagMacroNode) call(ctx *ExecutionContext, args ...*Value) *Value {
 		macroCtx.Private[node.argsOrder[idx]] = argValue.Interface()
 	}
 
-	var b bytes.Buffer
-	err := node.wrapper.Execute(macroCtx, &b)
+	if block != nil {
+		macroCtx.Private["caller"] = block.caller
+	}
+
+	b := getBuffer()
+	defer putBuffer(b)
+	err := node.wrapper.Execute(macroCtx, b)
 	if err != nil {
 		return AsSafeValue(err.updateFromTokenIfNeeded(ctx.template, node.position).Error())
 	}
@@ -0,0 +1,70 @@
+package pongo2
+
+// MarkdownRenderer renders Markdown to HTML for the 'markdown'-tag.
+// Set it on a TemplateSet using the MarkdownRenderer field.
+type MarkdownRenderer interface {
+	Render(markdown []byte) ([]byte, error)
+}
+
+type tagMarkdownNode struct {
+	position *Token
+	wrapper  *NodeWrapper
+}
+
+func (node *tagMarkdownNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	renderer := ctx.template.set.MarkdownRenderer
+	if renderer == nil {
+		return ctx.Error("No markdown renderer set (see TemplateSet.MarkdownRenderer).", node.position)
+	}
+
+	b := getBuffer()
+	defer putBuffer(b)
+
+	err := node.wrapper.Execute(ctx, b)
+	if err != nil {
+		return err
+	}
+
+	html, rerr := renderer.Render(b.Bytes())
+	if rerr != nil {
+		return ctx.OrigError(rerr, node.position)
+	}
+
+	// The rendered HTML is considered safe
+	writer.Write(html)
+
+	return nil
+}
+
+// The markdown-tag renders its body using the template set's MarkdownRenderer:
+//     {% markdown %}# Hello {{ name }}{% endmarkdown %}
+// Using the raw-argument, the body is not being parsed as a template (like verbatim):
+//     {% markdown raw %}# Hello {{ name }}{% endmarkdown %}
+func tagMarkdownParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
+	markdownNode := &tagMarkdownNode{
+		position: start,
+	}
+
+	// The lexer already emitted the body of the raw-form as a single HTML token
+	arguments.MatchOne(TokenIdentifier, "raw")
+
+	if arguments.Remaining() > 0 {
+		return nil, arguments.Error("Malformed markdown-tag arguments.", nil)
+	}
+
+	wrapper, endargs, err := doc.WrapUntilTag("endmarkdown")
+	if err != nil {
+		return nil, err
+	}
+	markdownNode.wrapper = wrapper
+
+	if endargs.Count() > 0 {
+		return nil, endargs.Error("Arguments not allowed here.", nil)
+	}
+
+	return markdownNode, nil
+}
+
+func init() {
+	RegisterTag("markdown", tagMarkdownParser)
+}
@@ -0,0 +1,88 @@
+package pongo2
+
+import (
+	"fmt"
+)
+
+// Minifier minifies the body of the 'minify'-tag. Set it on a TemplateSet
+// using the Minifier field. The mediatype is one of "text/html", "text/css"
+// or "application/javascript".
+type Minifier interface {
+	Minify(mediatype, content string) (string, error)
+}
+
+var tagMinifyMediatypes = map[string]string{
+	"html": "text/html",
+	"css":  "text/css",
+	"js":   "application/javascript",
+}
+
+type tagMinifyNode struct {
+	position  *Token
+	mediatype string
+	wrapper   *NodeWrapper
+}
+
+func (node *tagMinifyNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	minifier := ctx.template.set.Minifier
+	if minifier == nil {
+		// Nothing to minify with; output the body unchanged
+		return node.wrapper.Execute(ctx, writer)
+	}
+
+	b := getBuffer()
+	defer putBuffer(b)
+
+	err := node.wrapper.Execute(ctx, b)
+	if err != nil {
+		return err
+	}
+
+	minified, merr := minifier.Minify(node.mediatype, b.String())
+	if merr != nil {
+		return ctx.OrigError(merr, node.position)
+	}
+
+	writer.WriteString(minified)
+
+	return nil
+}
+
+// The minify-tag minifies its rendered body using the template set's Minifier:
+//     {% minify html %}<p>  {{ text }}  </p>{% endminify %}
+// Valid mediatypes are html, css and js.
+func tagMinifyParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
+	minifyNode := &tagMinifyNode{
+		position: start,
+	}
+
+	typeToken := arguments.MatchType(TokenIdentifier)
+	if typeToken == nil {
+		return nil, arguments.Error("Tag 'minify' requires a mediatype (html, css or js).", nil)
+	}
+	mediatype, has := tagMinifyMediatypes[typeToken.Val]
+	if !has {
+		return nil, arguments.Error(fmt.Sprintf("Unknown mediatype '%s' for tag 'minify' (valid: html, css or js).", typeToken.Val), typeToken)
+	}
+	minifyNode.mediatype = mediatype
+
+	if arguments.Remaining() > 0 {
+		return nil, arguments.Error("Malformed minify-tag arguments.", nil)
+	}
+
+	wrapper, endargs, err := doc.WrapUntilTag("endminify")
+	if err != nil {
+		return nil, err
+	}
+	minifyNode.wrapper = wrapper
+
+	if endargs.Count() > 0 {
+		return nil, endargs.Error("Arguments not allowed here.", nil)
+	}
+
+	return minifyNode, nil
+}
+
+func init() {
+	RegisterTag("minify", tagMinifyParser)
+}
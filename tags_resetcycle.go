@@ -0,0 +1,48 @@
+package pongo2
+
+import (
+	"fmt"
+)
+
+type tagResetCycleNode struct {
+	cycle *tagCycleNode
+}
+
+func (node *tagResetCycleNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	// The next execution of the cycle-tag starts with the first value again
+	node.cycle.reset(ctx)
+	return nil
+}
+
+// resetcycle resets the most recent cycle-tag of the template or, if a name
+// is given, the cycle-tag with the given name ({% cycle ... as name %}).
+func tagResetCycleParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
+	resetNode := &tagResetCycleNode{}
+
+	if doc.template == nil {
+		return nil, arguments.Error("Tag 'resetcycle' can only be used within a template.", nil)
+	}
+
+	if nameToken := arguments.MatchType(TokenIdentifier); nameToken != nil {
+		cycle, has := doc.template.namedCycles[nameToken.Val]
+		if !has {
+			return nil, arguments.Error(fmt.Sprintf("Named cycle '%s' does not exist.", nameToken.Val), nameToken)
+		}
+		resetNode.cycle = cycle
+	} else {
+		if doc.template.lastCycle == nil {
+			return nil, arguments.Error("No cycle-tag to reset found in this template.", nil)
+		}
+		resetNode.cycle = doc.template.lastCycle
+	}
+
+	if arguments.Remaining() > 0 {
+		return nil, arguments.Error("Malformed resetcycle-tag.", nil)
+	}
+
+	return resetNode, nil
+}
+
+func init() {
+	RegisterTag("resetcycle", tagResetCycleParser)
+}
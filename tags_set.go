@@ -1,23 +1,143 @@
 package pongo2
 
+import (
+	"fmt"
+	"strings"
+)
+
 type tagSetNode struct {
+	position   *Token
 	name       string
+	names      []string // more than one to unpack a list (set a, b = pair)
 	expression IEvaluator
+
+	// Compound assignments (like `+=`) only: the operator ("+", "-", "*"
+	// or "/") and a resolver for the variable's current value
+	operator string
+	current  *variableResolver
 }
 
 func (node *tagSetNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	if err := ctx.checkAssignable(node.name, node.position); err != nil {
+		return err
+	}
+
 	// Evaluate expression
 	value, err := node.expression.Evaluate(ctx)
 	if err != nil {
 		return err
 	}
 
-	ctx.Private[node.name] = value
+	if len(node.names) > 1 {
+		return node.unpack(ctx, value)
+	}
+
+	if node.operator == "" {
+		node.assign(ctx, node.name, value)
+		return nil
+	}
+
+	current, err := node.current.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+	if current.IsNil() {
+		return ctx.Error(fmt.Sprintf("Cannot apply '%s=': variable '%s' is not defined.", node.operator, node.name), node.position)
+	}
+	value, err = node.apply(ctx, current, value)
+	if err != nil {
+		return err
+	}
+
+	node.assign(ctx, node.name, value)
+	return nil
+}
+
+// unpack assigns the items of a list (slice or array) to the variables
+// (one item per variable).
+func (node *tagSetNode) unpack(ctx *ExecutionContext, value *Value) *Error {
+	if !value.CanSlice() || value.IsString() {
+		return ctx.Error(fmt.Sprintf("Cannot unpack '%s' into %d variables (a list is required).",
+			value.String(), len(node.names)), node.position)
+	}
+	if value.Len() != len(node.names) {
+		return ctx.Error(fmt.Sprintf("Cannot unpack %d values into %d variables ('%s').",
+			value.Len(), len(node.names), strings.Join(node.names, "', '")), node.position)
+	}
+
+	for i, name := range node.names {
+		node.assign(ctx, name, value.Index(i))
+	}
 	return nil
 }
 
+// assign sets the variable in the current scope. If the variable has been
+// set (by a set-tag) in an enclosing scope, this outer binding is updated
+// as well instead of being shadowed. This way a variable set before a loop
+// can be changed within the loop and keeps its value after the loop.
+func (node *tagSetNode) assign(ctx *ExecutionContext, name string, value *Value) {
+	old, has := ctx.Private[name]
+	ctx.Private[name] = value
+
+	if _, isSet := old.(*Value); !has || !isSet {
+		return
+	}
+
+	// Child scopes start with a copy of their parent's private context, so
+	// the binding is shared as long as the enclosing scopes hold the same
+	// value (loop variables or macro arguments of the same name don't). The
+	// scope of a macro is isolated: a macro never changes its caller's variables.
+	for c := ctx; !c.isolated && c.parent != nil; c = c.parent {
+		if v, has := c.parent.Private[name]; !has || v != old {
+			break
+		}
+		c.parent.Private[name] = value
+	}
+}
+
+// apply applies the compound assignment operator to both values. The result
+// is a float if one of the operands is a float, an integer otherwise. Two
+// strings can be concatenated using `+=`.
+func (node *tagSetNode) apply(ctx *ExecutionContext, current, value *Value) (*Value, *Error) {
+	if node.operator == "+" && current.IsString() && value.IsString() {
+		return AsValue(current.String() + value.String()), nil
+	}
+	if !current.IsNumber() || !value.IsNumber() {
+		return nil, ctx.Error(fmt.Sprintf("Cannot apply '%s=' to '%s' (operands must be numbers).", node.operator, node.name), node.position)
+	}
+
+	if current.IsFloat() || value.IsFloat() {
+		switch node.operator {
+		case "+":
+			return AsValue(current.Float() + value.Float()), nil
+		case "-":
+			return AsValue(current.Float() - value.Float()), nil
+		case "*":
+			return AsValue(current.Float() * value.Float()), nil
+		default:
+			return AsValue(current.Float() / value.Float()), nil
+		}
+	}
+
+	switch node.operator {
+	case "+":
+		return AsValue(current.Integer() + value.Integer()), nil
+	case "-":
+		return AsValue(current.Integer() - value.Integer()), nil
+	case "*":
+		return AsValue(current.Integer() * value.Integer()), nil
+	default:
+		if value.Integer() == 0 {
+			return nil, ctx.Error(fmt.Sprintf("Cannot apply '/=' to '%s': division by zero.", node.name), node.position)
+		}
+		return AsValue(current.Integer() / value.Integer()), nil
+	}
+}
+
 func tagSetParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
-	node := &tagSetNode{}
+	node := &tagSetNode{
+		position: start,
+	}
 
 	// Parse variable name
 	typeToken := arguments.MatchType(TokenIdentifier)
@@ -26,8 +146,40 @@ func tagSetParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Erro
 	}
 	node.name = typeToken.Val
 
-	if arguments.Match(TokenSymbol, "=") == nil {
-		return nil, arguments.Error("Expected '='.", nil)
+	// Multiple variables to unpack a list into (set a, b = pair)
+	for arguments.Match(TokenSymbol, ",") != nil {
+		if node.names == nil {
+			node.names = []string{node.name}
+		}
+		nameToken := arguments.MatchType(TokenIdentifier)
+		if nameToken == nil {
+			return nil, arguments.Error("Expected an identifier after ','.", nil)
+		}
+		node.names = append(node.names, nameToken.Val)
+	}
+
+	if node.names != nil {
+		node.name = strings.Join(node.names, ", ")
+		if arguments.Match(TokenSymbol, "=") == nil {
+			return nil, arguments.Error("Expected '=' after the variables to unpack into.", nil)
+		}
+	} else {
+		// Compound assignment (`+=`, `-=`, `*=` or `/=`; no whitespace allowed
+		// between the operator and '=')
+		if op := arguments.PeekOne(TokenSymbol, "+", "-", "*", "/"); op != nil {
+			if eq := arguments.PeekN(1, TokenSymbol, "="); eq != nil && op.End == eq.Start {
+				arguments.Consume()
+				node.operator = op.Val
+				node.current = &variableResolver{
+					locationToken: typeToken,
+					parts:         []*variablePart{{typ: varTypeIdent, s: node.name}},
+				}
+			}
+		}
+
+		if arguments.Match(TokenSymbol, "=") == nil {
+			return nil, arguments.Error("Expected '=' (or one of '+=', '-=', '*=', '/=').", nil)
+		}
 	}
 
 	// Variable expression
@@ -35,6 +187,7 @@ func tagSetParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Erro
 	if err != nil {
 		return nil, err
 	}
+	markMacroAsValue(keyExpression)
 	node.expression = keyExpression
 
 	// Remaining arguments
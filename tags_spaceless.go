@@ -1,7 +1,6 @@
 package pongo2
 
 import (
-	"bytes"
 	"regexp"
 )
 
@@ -12,7 +11,8 @@ type tagSpacelessNode struct {
 var tagSpacelessRegexp = regexp.MustCompile(`(?U:(<.*>))([\t\n\v\f\r ]+)(?U:(<.*>))`)
 
 func (node *tagSpacelessNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
-	b := bytes.NewBuffer(make([]byte, 0, 1024)) // 1 KiB
+	b := getBuffer()
+	defer putBuffer(b)
 
 	err := node.wrapper.Execute(ctx, b)
 	if err != nil {
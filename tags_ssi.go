@@ -17,9 +17,8 @@ func (node *tagSSINode) Execute(ctx *ExecutionContext, writer TemplateWriter) *E
 		includeCtx.Update(ctx.Public)
 		includeCtx.Update(ctx.Private)
 
-		err := node.template.execute(includeCtx, writer)
-		if err != nil {
-			return err.(*Error)
+		if err := executeSubTemplate(ctx, node.template, includeCtx, writer, true); err != nil {
+			return err
 		}
 	} else {
 		// Just print out the content
@@ -0,0 +1,70 @@
+package pongo2
+
+import (
+	"strings"
+)
+
+type tagStripNode struct {
+	wrapper *NodeWrapper
+}
+
+// stripLines removes the trailing whitespace of every line as well as the
+// blank lines at the beginning and the end (blank lines in between are kept).
+func stripLines(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r\v\f")
+	}
+
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (node *tagStripNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	b := getBuffer()
+	defer putBuffer(b)
+
+	err := node.wrapper.Execute(ctx, b)
+	if err != nil {
+		return err
+	}
+
+	writer.WriteString(stripLines(b.String()))
+
+	return nil
+}
+
+// tagStripParser parses the strip-tag which post-processes the rendered output
+// of its body (useful for code-generation templates): the trailing whitespace
+// of every line is removed as well as the blank lines at the beginning and
+// the end of the block. Note that the block's output therefore never ends
+// with a newline.
+func tagStripParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
+	stripNode := &tagStripNode{}
+
+	wrapper, endargs, err := doc.WrapUntilTag("endstrip")
+	if err != nil {
+		return nil, err
+	}
+	stripNode.wrapper = wrapper
+
+	if endargs.Count() > 0 {
+		return nil, endargs.Error("Arguments not allowed here.", nil)
+	}
+
+	if arguments.Remaining() > 0 {
+		return nil, arguments.Error("Malformed strip-tag arguments.", nil)
+	}
+
+	return stripNode, nil
+}
+
+func init() {
+	RegisterTag("strip", tagStripParser)
+}
@@ -0,0 +1,106 @@
+package pongo2
+
+type tagSwitchCase struct {
+	values  []IEvaluator
+	wrapper *NodeWrapper
+}
+
+type tagSwitchNode struct {
+	subject        IEvaluator
+	cases          []*tagSwitchCase
+	defaultWrapper *NodeWrapper
+}
+
+func (node *tagSwitchNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	// The subject is evaluated only once
+	subject, err := node.subject.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range node.cases {
+		for _, expr := range c.values {
+			val, err := expr.Evaluate(ctx)
+			if err != nil {
+				return err
+			}
+
+			if subject.EqualValueTo(val) {
+				return c.wrapper.Execute(ctx, writer)
+			}
+		}
+	}
+
+	if node.defaultWrapper != nil {
+		return node.defaultWrapper.Execute(ctx, writer)
+	}
+
+	return nil
+}
+
+func tagSwitchParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
+	switchNode := &tagSwitchNode{}
+
+	subject, err := arguments.ParseExpression()
+	if err != nil {
+		return nil, err
+	}
+	switchNode.subject = subject
+
+	if arguments.Remaining() > 0 {
+		return nil, arguments.Error("Switch-subject is malformed.", nil)
+	}
+
+	// Everything between the switch-tag and the first case is ignored
+	wrapper, tagArgs, err := doc.WrapUntilTag("case", "default", "endswitch")
+	if err != nil {
+		return nil, err
+	}
+
+	for wrapper.Endtag != "endswitch" {
+		endtag := wrapper.Endtag
+
+		if endtag == "default" {
+			if tagArgs.Count() > 0 {
+				return nil, tagArgs.Error("Arguments not allowed here.", nil)
+			}
+
+			wrapper, tagArgs, err = doc.WrapUntilTag("endswitch")
+			if err != nil {
+				return nil, err
+			}
+			switchNode.defaultWrapper = wrapper
+			break
+		}
+
+		// case takes one or more values
+		c := &tagSwitchCase{}
+		for tagArgs.Remaining() > 0 {
+			value, err := tagArgs.ParseExpression()
+			if err != nil {
+				return nil, err
+			}
+			c.values = append(c.values, value)
+		}
+		if len(c.values) == 0 {
+			return nil, tagArgs.Error("Tag 'case' requires at least one value.", nil)
+		}
+
+		wrapper, tagArgs, err = doc.WrapUntilTag("case", "default", "endswitch")
+		if err != nil {
+			return nil, err
+		}
+		c.wrapper = wrapper
+		switchNode.cases = append(switchNode.cases, c)
+	}
+
+	if tagArgs.Count() > 0 {
+		return nil, tagArgs.Error("Arguments not allowed here.", nil)
+	}
+
+	return switchNode, nil
+}
+
+func init() {
+	RegisterTag("switch", tagSwitchParser)
+}
@@ -0,0 +1,66 @@
+package pongo2
+
+import (
+	"fmt"
+)
+
+type tagTransformNode struct {
+	position *Token
+	name     string
+	wrapper  *NodeWrapper
+}
+
+func (node *tagTransformNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	b := getBuffer()
+	defer putBuffer(b)
+
+	err := node.wrapper.Execute(ctx, b)
+	if err != nil {
+		return err
+	}
+
+	transform, has := ctx.template.set.outputTransforms[node.name]
+	if !has {
+		return ctx.Error(fmt.Sprintf("Output transform '%s' does not exist.", node.name), node.position)
+	}
+
+	s, terr := transform(b.String())
+	if terr != nil {
+		return ctx.OrigError(terr, node.position)
+	}
+
+	writer.WriteString(s)
+
+	return nil
+}
+
+func tagTransformParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
+	transformNode := &tagTransformNode{
+		position: start,
+	}
+
+	nameToken := arguments.MatchType(TokenIdentifier)
+	if nameToken == nil {
+		return nil, arguments.Error("Expected an output transform name (identifier).", nil)
+	}
+	if _, has := doc.template.set.outputTransforms[nameToken.Val]; !has {
+		return nil, arguments.Error(fmt.Sprintf("Output transform '%s' does not exist.", nameToken.Val), nameToken)
+	}
+	transformNode.name = nameToken.Val
+
+	if arguments.Remaining() > 0 {
+		return nil, arguments.Error("Malformed transform-tag arguments.", nil)
+	}
+
+	wrapper, _, err := doc.WrapUntilTag("endtransform")
+	if err != nil {
+		return nil, err
+	}
+	transformNode.wrapper = wrapper
+
+	return transformNode, nil
+}
+
+func init() {
+	RegisterTag("transform", tagTransformParser)
+}
@@ -0,0 +1,80 @@
+package pongo2
+
+type tagTryNode struct {
+	tryWrapper    *NodeWrapper
+	exceptWrapper *NodeWrapper
+	errorName     string
+}
+
+func (node *tagTryNode) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	// Errors must reach the try-tag, so the error-recovery mode is disabled within
+	tryCtx := NewChildExecutionContext(ctx)
+	tryCtx.recovery = nil
+
+	// The try-branch's output is only written if it succeeded
+	buf := getBuffer()
+	defer putBuffer(buf)
+	err := node.tryWrapper.Execute(tryCtx, buf)
+	if err == nil {
+		writer.Write(buf.Bytes())
+		return nil
+	}
+
+	if node.exceptWrapper == nil {
+		return nil
+	}
+
+	exceptCtx := NewChildExecutionContext(ctx)
+	if node.errorName != "" {
+		msg := ""
+		if err.OrigError != nil {
+			msg = err.OrigError.Error()
+		}
+		exceptCtx.Private[node.errorName] = msg
+	}
+	return node.exceptWrapper.Execute(exceptCtx, writer)
+}
+
+func tagTryParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Error) {
+	tryNode := &tagTryNode{}
+
+	if arguments.Remaining() > 0 {
+		return nil, arguments.Error("Tag 'try' does not take any argument.", nil)
+	}
+
+	wrapper, tagArgs, err := doc.WrapUntilTag("except", "endtry")
+	if err != nil {
+		return nil, err
+	}
+	tryNode.tryWrapper = wrapper
+
+	if wrapper.Endtag == "except" {
+		// {% except as err %}
+		if tagArgs.Match(TokenKeyword, "as") != nil {
+			nameToken := tagArgs.MatchType(TokenIdentifier)
+			if nameToken == nil {
+				return nil, tagArgs.Error("Name (identifier) expected after 'as'.", nil)
+			}
+			tryNode.errorName = nameToken.Val
+		}
+		if tagArgs.Remaining() > 0 {
+			return nil, tagArgs.Error("Malformed except-tag.", nil)
+		}
+
+		wrapper, tagArgs, err = doc.WrapUntilTag("endtry")
+		if err != nil {
+			return nil, err
+		}
+		tryNode.exceptWrapper = wrapper
+	}
+
+	if tagArgs.Count() > 0 {
+		return nil, tagArgs.Error("Arguments not allowed here.", nil)
+	}
+
+	return tryNode, nil
+}
+
+func init() {
+	RegisterTag("try", tagTryParser)
+}
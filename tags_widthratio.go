@@ -33,6 +33,9 @@ func (node *tagWidthratioNode) Execute(ctx *ExecutionContext, writer TemplateWri
 	if node.ctxName == "" {
 		writer.WriteString(fmt.Sprintf("%d", value))
 	} else {
+		if err := ctx.checkAssignable(node.ctxName, node.position); err != nil {
+			return err
+		}
 		ctx.Private[node.ctxName] = value
 	}
 
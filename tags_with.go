@@ -63,6 +63,7 @@ func tagWithParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Err
 			if keyToken == nil {
 				return nil, arguments.Error("Expected an identifier", nil)
 			}
+			markMacroAsValue(valueExpr)
 			withNode.withPairs[keyToken.Val] = valueExpr
 		} else {
 			keyToken := arguments.MatchType(TokenIdentifier)
@@ -76,6 +77,7 @@ func tagWithParser(doc *Parser, start *Token, arguments *Parser) (INodeTag, *Err
 			if err != nil {
 				return nil, err
 			}
+			markMacroAsValue(valueExpr)
 			withNode.withPairs[keyToken.Val] = valueExpr
 		}
 	}
@@ -3,6 +3,8 @@ package pongo2
 import (
 	"bytes"
 	"io"
+	"net/http"
+	"reflect"
 
 	"github.com/juju/errors"
 )
@@ -24,6 +26,24 @@ func (tw *templateWriter) Write(b []byte) (int, error) {
 	return tw.w.Write(b)
 }
 
+// flushTemplateWriter is a templateWriter which flushes the underlying
+// writer with each call to Flush (used by ExecuteWriterFlush).
+type flushTemplateWriter struct {
+	templateWriter
+	flusher http.Flusher
+}
+
+func (tw *flushTemplateWriter) Flush() {
+	tw.flusher.Flush()
+}
+
+// flushWriter flushes the writer if it supports flushing.
+func flushWriter(writer TemplateWriter) {
+	if f, ok := writer.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 type Template struct {
 	set *TemplateSet
 
@@ -44,8 +64,23 @@ type Template struct {
 	blocks         map[string]*NodeWrapper
 	exportedMacros map[string]*tagMacroNode
 
+	// Cycle-tags seen so far while parsing (needed by the resetcycle-tag)
+	lastCycle   *tagCycleNode
+	namedCycles map[string]*tagCycleNode
+
+	// Filter aliases defined by the filteralias-tag (only valid within this template)
+	filterAliases map[string][]*filterCall
+
+	// Context keys which must be provided on execution (see SetRequiredVars)
+	requiredVars map[string]reflect.Kind
+
 	// Output
 	root *nodeDocument
+
+	// Set if the template contains no tags and no variables; staticContent
+	// holds the rendered output then
+	static        bool
+	staticContent string
 }
 
 func newTemplateString(set *TemplateSet, tpl []byte) (*Template, error) {
@@ -64,6 +99,8 @@ func newTemplate(set *TemplateSet, name string, isTplString bool, tpl []byte) (*
 		size:           len(strTpl),
 		blocks:         make(map[string]*NodeWrapper),
 		exportedMacros: make(map[string]*tagMacroNode),
+		namedCycles:    make(map[string]*tagCycleNode),
+		filterAliases:  make(map[string][]*filterCall),
 	}
 
 	// Tokenize it
@@ -87,13 +124,32 @@ func newTemplate(set *TemplateSet, name string, isTplString bool, tpl []byte) (*
 	return t, nil
 }
 
-func (tpl *Template) execute(context Context, writer TemplateWriter) error {
-	// Determine the parent to be executed (for template inheritance)
+// Name returns the template's name. It's the filename for templates
+// loaded from files and "<string>" for templates created from a string.
+func (tpl *Template) Name() string {
+	return tpl.name
+}
+
+// SetRequiredVars declares context keys (including their kinds) which must be
+// provided on every execution of this template. Executing the template with
+// a context (after merging the set's globals) not fulfilling these
+// requirements returns an error before anything is rendered.
+// See Context.Validate for more information on the kind checks.
+func (tpl *Template) SetRequiredVars(vars map[string]reflect.Kind) {
+	tpl.requiredVars = vars
+}
+
+// baseTemplate returns the template to be executed (for template inheritance).
+func (tpl *Template) baseTemplate() *Template {
 	parent := tpl
 	for parent.parent != nil {
 		parent = parent.parent
 	}
+	return parent
+}
 
+// newContext merges the set's globals with the given context and validates the result.
+func (tpl *Template) newContext(context Context) (Context, error) {
 	// Create context if none is given
 	newContext := make(Context)
 	newContext.Update(tpl.set.Globals)
@@ -105,14 +161,14 @@ func (tpl *Template) execute(context Context, writer TemplateWriter) error {
 			// Check for context name syntax
 			err := newContext.checkForValidIdentifiers()
 			if err != nil {
-				return err
+				return nil, err
 			}
 
 			// Check for clashes with macro names
 			for k := range newContext {
 				_, has := tpl.exportedMacros[k]
 				if has {
-					return &Error{
+					return nil, &Error{
 						Filename:  tpl.name,
 						Sender:    "execution",
 						OrigError: errors.Errorf("context key name '%s' clashes with macro '%s'", k, k),
@@ -122,26 +178,61 @@ func (tpl *Template) execute(context Context, writer TemplateWriter) error {
 		}
 	}
 
+	// Check for required context keys
+	if len(tpl.requiredVars) > 0 {
+		if err := newContext.Validate(tpl.requiredVars); err != nil {
+			e := err.(*Error)
+			e.Filename = tpl.name
+			return nil, e
+		}
+	}
+
+	return newContext, nil
+}
+
+// execute executes the template; autoescape sets the initial autoescape-mode.
+func (tpl *Template) execute(context Context, writer TemplateWriter, autoescape bool) error {
+	newContext, err := tpl.newContext(context)
+	if err != nil {
+		return err
+	}
+
+	// Static templates don't need to be executed
+	if tpl.static {
+		_, werr := writer.WriteString(tpl.staticContent)
+		return werr
+	}
+
 	// Create operational context
+	parent := tpl.baseTemplate()
 	ctx := newExecutionContext(parent, newContext)
+	ctx.Autoescape = autoescape
 
 	// Run the selected document
 	if err := parent.root.Execute(ctx, writer); err != nil {
 		return err
 	}
 
+	if ctx.recovery != nil && len(ctx.recovery.errors) > 0 {
+		return ctx.recovery.errors
+	}
+
 	return nil
 }
 
 func (tpl *Template) newTemplateWriterAndExecute(context Context, writer io.Writer) error {
-	return tpl.execute(context, &templateWriter{w: writer})
+	return tpl.execute(context, &templateWriter{w: writer}, true)
 }
 
-func (tpl *Template) newBufferAndExecute(context Context) (*bytes.Buffer, error) {
+func (tpl *Template) newBufferAndExecute(context Context, autoescape bool) (*bytes.Buffer, error) {
 	// Create output buffer
 	// We assume that the rendered template will be 30% larger
 	buffer := bytes.NewBuffer(make([]byte, 0, int(float64(tpl.size)*1.3)))
-	if err := tpl.execute(context, buffer); err != nil {
+	if err := tpl.execute(context, buffer, autoescape); err != nil {
+		if recovered, ok := err.(RecoveredErrors); ok {
+			// The output is usable in error-recovery mode
+			return buffer, recovered
+		}
 		return nil, err
 	}
 	return buffer, nil
@@ -149,17 +240,18 @@ func (tpl *Template) newBufferAndExecute(context Context) (*bytes.Buffer, error)
 
 // Executes the template with the given context and writes to writer (io.Writer)
 // on success. Context can be nil. Nothing is written on error; instead the error
-// is being returned.
+// is being returned. In error-recovery mode (see TemplateSet.ErrorPlaceholder)
+// the output is written and a RecoveredErrors error is returned if any errors
+// have been recovered.
 func (tpl *Template) ExecuteWriter(context Context, writer io.Writer) error {
-	buf, err := tpl.newBufferAndExecute(context)
-	if err != nil {
+	buf, err := tpl.newBufferAndExecute(context, true)
+	if buf == nil {
 		return err
 	}
-	_, err = buf.WriteTo(writer)
-	if err != nil {
-		return err
+	if _, werr := buf.WriteTo(writer); werr != nil {
+		return werr
 	}
-	return nil
+	return err
 }
 
 // Same as ExecuteWriter. The only difference between both functions is that
@@ -171,24 +263,96 @@ func (tpl *Template) ExecuteWriterUnbuffered(context Context, writer io.Writer)
 	return tpl.newTemplateWriterAndExecute(context, writer)
 }
 
+// Same as ExecuteWriterUnbuffered, but if writer implements http.Flusher (like
+// http.ResponseWriter does), the writer is flushed after every top-level node
+// and after each for-loop iteration. This enables progressive rendering of
+// long pages (or server-sent events) and improves the time to first byte.
+func (tpl *Template) ExecuteWriterFlush(context Context, writer io.Writer) error {
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		return tpl.newTemplateWriterAndExecute(context, writer)
+	}
+	return tpl.execute(context, &flushTemplateWriter{
+		templateWriter: templateWriter{w: writer},
+		flusher:        flusher,
+	}, true)
+}
+
 // Executes the template and returns the rendered template as a []byte
 func (tpl *Template) ExecuteBytes(context Context) ([]byte, error) {
 	// Execute template
-	buffer, err := tpl.newBufferAndExecute(context)
-	if err != nil {
+	buffer, err := tpl.newBufferAndExecute(context, true)
+	if buffer == nil {
 		return nil, err
 	}
-	return buffer.Bytes(), nil
+	return buffer.Bytes(), err
 }
 
 // Executes the template and returns the rendered template as a string
 func (tpl *Template) Execute(context Context) (string, error) {
+	// Static templates are returned as-is (but the context is still validated)
+	if tpl.static {
+		if _, err := tpl.newContext(context); err != nil {
+			return "", err
+		}
+		return tpl.staticContent, nil
+	}
+
 	// Execute template
-	buffer, err := tpl.newBufferAndExecute(context)
+	buffer, err := tpl.newBufferAndExecute(context, true)
+	if buffer == nil {
+		return "", err
+	}
+
+	return buffer.String(), err
+
+}
+
+// MustExecute behaves like Execute, but panics on an error.
+func (tpl *Template) MustExecute(context Context) string {
+	out, err := tpl.Execute(context)
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+// ExecuteBlock executes only the block named blockName with the given context
+// and returns its rendered output (useful for partial page updates). Block
+// overrides of child templates as well as {{ block.Super }} are honored.
+// An error is returned if no template in the inheritance chain defines the block.
+func (tpl *Template) ExecuteBlock(blockName string, context Context) (string, error) {
+	parent := tpl.baseTemplate()
+
+	found := false
+	for t := parent; t != nil; t = t.child {
+		if _, has := t.blocks[blockName]; has {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return "", &Error{
+			Filename:  tpl.name,
+			Sender:    "execution",
+			OrigError: errors.Errorf("block '%s' does not exist", blockName),
+		}
+	}
+
+	newContext, err := tpl.newContext(context)
 	if err != nil {
 		return "", err
 	}
 
-	return buffer.String(), nil
+	ctx := newExecutionContext(parent, newContext)
+	buffer := bytes.NewBufferString("")
+	block := &tagBlockNode{name: blockName}
+	if err := block.Execute(ctx, &templateWriter{w: buffer}); err != nil {
+		return "", err
+	}
+	if ctx.recovery != nil && len(ctx.recovery.errors) > 0 {
+		return buffer.String(), ctx.recovery.errors
+	}
 
+	return buffer.String(), nil
 }
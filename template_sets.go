@@ -6,6 +6,8 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"reflect"
+	"regexp"
 	"sync"
 
 	"github.com/juju/errors"
@@ -21,6 +23,17 @@ type TemplateLoader interface {
 	Get(path string) (io.Reader, error)
 }
 
+// Logger receives the debug output and the warnings of a template set (see
+// TemplateSet.Logger).
+type Logger interface {
+	// Debugf receives the debug output (only called if Debug is enabled)
+	Debugf(format string, args ...interface{})
+
+	// Warnf is called on silent fallbacks, like undefined variables or
+	// missing templates which are included using if_exists.
+	Warnf(format string, args ...interface{})
+}
+
 // TemplateSet allows you to create your own group of templates with their own
 // global context (which is shared among all members of the set) and their own
 // configuration.
@@ -39,6 +52,72 @@ type TemplateSet struct {
 	// variable during program execution (and template compilation/execution).
 	Debug bool
 
+	// If DebugAnnotate is true (default false) and Debug is enabled as well, the
+	// output of blocks and includes is surrounded by HTML comments showing
+	// which template produced it, for example:
+	//     <!-- begin block content (base.html) -->...<!-- end -->
+	DebugAnnotate bool
+
+	// CSRFProvider provides the token for the 'csrf_token'-tag. If it's nil,
+	// the tag uses the context key 'csrf_token' instead.
+	CSRFProvider CSRFProvider
+
+	// MarkdownRenderer renders the body of the 'markdown'-tag to HTML. Using the
+	// markdown-tag without a renderer leads to an execution error.
+	MarkdownRenderer MarkdownRenderer
+
+	// Minifier minifies the body of the 'minify'-tag. Without a minifier
+	// the body is written unchanged.
+	Minifier Minifier
+
+	// Rand is the source of randomness for the 'random'-filter. If it's nil,
+	// the global source of math/rand is being used. Set it to a seeded
+	// rand.New(rand.NewSource(seed)) to get a deterministic output (e. g. in tests).
+	// A *rand.Rand is not safe for concurrent use: if the templates of this set
+	// are executed concurrently, wrap it into a source locking a mutex in Intn.
+	Rand RandomSource
+
+	// If ReadOnlyContext is true (default false), assignments into the top-level
+	// scope of an execution (like {% set %} outside of any block creating its own
+	// scope, e. g. with or for) lead to an execution error. Assignments within a
+	// child scope still work. Please note that the context passed to the
+	// Execute*-functions is never modified in any case.
+	ReadOnlyContext bool
+
+	// FloatFormat is the fmt-verb used to output floats within variable tags
+	// (e. g. {{ 1.5 }}). If it's empty (default), floats are formatted using %f
+	// ("1.000000"). Use "%v" to get the shortest representation ("1" and "1.5")
+	// or fixed decimals like "%.1f" ("1.0" and "1.5"). Filters like floatformat
+	// are not affected.
+	FloatFormat string
+
+	// ErrorPlaceholder enables the error-recovery mode if it's not nil. Instead of
+	// aborting the execution on the first error, the output of the failing node
+	// (the innermost tag or variable which returned the error) is replaced by the
+	// string returned by ErrorPlaceholder and the execution continues. The
+	// placeholder is written as is (no escaping). The Execute*-functions return
+	// the rendered output along with a RecoveredErrors error holding all
+	// recovered errors. Be aware that every node's output is buffered in this mode.
+	ErrorPlaceholder func(err *Error) string
+
+	// If StrictNumbers is true (default false), strings used with arithmetic
+	// operators or ordering comparisons (<, <=, >, >=) lead to an execution
+	// error. By default, numeric strings (like form values) are converted to
+	// numbers: {% if age > 18 %} works with "21" as well. Equality (==, !=)
+	// never converts strings.
+	StrictNumbers bool
+
+	// Logger receives the set's debug output (like ExecutionContext.Logf() or
+	// the context keys an include-tag overrides using 'with') if Debug is
+	// enabled; if it's nil, the debug output is written to STDOUT. Warnings
+	// are reported to the Logger only (regardless of Debug).
+	Logger Logger
+
+	// Profiler records the execution time of every tag if Debug is enabled
+	// (useful to find slow includes or loops). It must be safe for concurrent
+	// use if the templates of this set are executed concurrently.
+	Profiler Profiler
+
 	// Sandbox features
 	// - Disallow access to specific tags and/or filters (using BanTag() and BanFilter())
 	//
@@ -52,8 +131,22 @@ type TemplateSet struct {
 	// Template cache (for FromCache())
 	templateCache      map[string]*Template
 	templateCacheMutex sync.Mutex
+
+	// Output transforms for the transform-tag (see RegisterOutputTransform())
+	outputTransforms map[string]OutputTransform
+
+	// Functions callable within expressions (see RegisterFunction())
+	functions map[string]interface{}
+
+	// Compiled patterns of the regex-filters (see compileRegexp())
+	regexpCache      map[string]*regexp.Regexp
+	regexpCacheMutex sync.Mutex
 }
 
+// maxRegexpCacheSize limits the number of cached patterns per template set
+// (patterns might be provided by variables).
+const maxRegexpCacheSize = 256
+
 // NewSet can be used to create sets with different kind of templates
 // (e. g. web from mail templates), with different globals or
 // other configurations.
@@ -79,9 +172,75 @@ func (set *TemplateSet) resolveFilename(tpl *Template, path string) string {
 	return set.loader.Abs(name, path)
 }
 
+// compileRegexp compiles the pattern once and returns the cached regexp
+// on subsequent calls. A *regexp.Regexp is safe for concurrent use.
+func (set *TemplateSet) compileRegexp(pattern string) (*regexp.Regexp, error) {
+	set.regexpCacheMutex.Lock()
+	defer set.regexpCacheMutex.Unlock()
+
+	if re, has := set.regexpCache[pattern]; has {
+		return re, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	if set.regexpCache == nil {
+		set.regexpCache = make(map[string]*regexp.Regexp)
+	}
+	if len(set.regexpCache) < maxRegexpCacheSize {
+		set.regexpCache[pattern] = re
+	}
+	return re, nil
+}
+
+// OutputTransform post-processes the rendered output of a transform-tag.
+type OutputTransform func(output string) (string, error)
+
+// RegisterOutputTransform registers a named output transform (like a markdown
+// renderer or an HTML minifier) which can be applied using the transform-tag:
+//     {% transform minify %}...{% endtransform %}
+// The transformed output is considered safe and therefore not escaped. Register
+// all transforms before you add your first template which uses them.
+func (set *TemplateSet) RegisterOutputTransform(name string, fn OutputTransform) error {
+	if _, has := set.outputTransforms[name]; has {
+		return errors.Errorf("output transform with name '%s' is already registered", name)
+	}
+	if set.outputTransforms == nil {
+		set.outputTransforms = make(map[string]OutputTransform)
+	}
+	set.outputTransforms[name] = fn
+	return nil
+}
+
+// RegisterFunction registers a function which can be called within expressions
+// of all templates of this set without providing it in every context:
+//     {{ asset("logo.png") }}
+// Functions are looked up before the context passed to the Execute*-functions
+// (and the set's globals); variables defined by tags (like set, with or for)
+// shadow them. Register all functions before executing templates using them.
+func (set *TemplateSet) RegisterFunction(name string, fn interface{}) error {
+	if !reIdentifiers.MatchString(name) {
+		return errors.Errorf("function name '%s' is not a valid identifier", name)
+	}
+	if fn == nil || reflect.TypeOf(fn).Kind() != reflect.Func {
+		return errors.Errorf("function '%s' must be a func, got %T", name, fn)
+	}
+	if _, has := set.functions[name]; has {
+		return errors.Errorf("function with name '%s' is already registered", name)
+	}
+	if set.functions == nil {
+		set.functions = make(map[string]interface{})
+	}
+	set.functions[name] = fn
+	return nil
+}
+
 // BanTag bans a specific tag for this template set. See more in the documentation for TemplateSet.
 func (set *TemplateSet) BanTag(name string) error {
-	_, has := tags[name]
+	_, has := getTag(name)
 	if !has {
 		return errors.Errorf("tag '%s' not found", name)
 	}
@@ -99,7 +258,7 @@ func (set *TemplateSet) BanTag(name string) error {
 
 // BanFilter bans a specific filter for this template set. See more in the documentation for TemplateSet.
 func (set *TemplateSet) BanFilter(name string) error {
-	_, has := filters[name]
+	_, has := getFilter(name)
 	if !has {
 		return errors.Errorf("filter '%s' not found", name)
 	}
@@ -115,8 +274,33 @@ func (set *TemplateSet) BanFilter(name string) error {
 	return nil
 }
 
+// AvailableTags returns the sorted names of all registered tags which are
+// not banned in this template set.
+func (set *TemplateSet) AvailableTags() []string {
+	var names []string
+	for _, name := range RegisteredTags() {
+		if !set.bannedTags[name] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// AvailableFilters returns the sorted names of all registered filters which
+// are not banned in this template set.
+func (set *TemplateSet) AvailableFilters() []string {
+	var names []string
+	for _, name := range RegisteredFilters() {
+		if !set.bannedFilters[name] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
 // FromCache is a convenient method to cache templates. It is thread-safe
-// and will only compile the template associated with a filename once.
+// and will only compile the template associated with a filename once (in case
+// of concurrent first calls all callers receive the same cached instance).
 // If TemplateSet.Debug is true (for example during development phase),
 // FromCache() will not cache the template and instead recompile it on any
 // call (to make changes to a template live instantaneously).
@@ -129,21 +313,29 @@ func (set *TemplateSet) FromCache(filename string) (*Template, error) {
 	cleanedFilename := set.resolveFilename(nil, filename)
 
 	set.templateCacheMutex.Lock()
-	defer set.templateCacheMutex.Unlock()
-
 	tpl, has := set.templateCache[cleanedFilename]
+	set.templateCacheMutex.Unlock()
 
-	// Cache miss
-	if !has {
-		tpl, err := set.FromFile(cleanedFilename)
-		if err != nil {
-			return nil, err
-		}
-		set.templateCache[cleanedFilename] = tpl
+	// Cache hit
+	if has {
 		return tpl, nil
 	}
 
-	// Cache hit
+	// Cache miss; the lock is not held during compilation because the
+	// template itself might request other templates from the cache (e. g. includes)
+	tpl, err := set.FromFile(cleanedFilename)
+	if err != nil {
+		return nil, err
+	}
+
+	set.templateCacheMutex.Lock()
+	defer set.templateCacheMutex.Unlock()
+
+	if cachedTpl, has := set.templateCache[cleanedFilename]; has {
+		// Another goroutine was faster
+		return cachedTpl, nil
+	}
+	set.templateCache[cleanedFilename] = tpl
 	return tpl, nil
 }
 
@@ -154,6 +346,11 @@ func (set *TemplateSet) FromString(tpl string) (*Template, error) {
 	return newTemplateString(set, []byte(tpl))
 }
 
+// MustFromString behaves like FromString, but panics on an error.
+func (set *TemplateSet) MustFromString(tpl string) *Template {
+	return Must(set.FromString(tpl))
+}
+
 // FromBytes loads a template from bytes and returns a Template instance.
 func (set *TemplateSet) FromBytes(tpl []byte) (*Template, error) {
 	set.firstTemplateCreated = true
@@ -221,12 +418,27 @@ func (set *TemplateSet) RenderTemplateFile(fn string, ctx Context) (string, erro
 	return result, nil
 }
 
+func (set *TemplateSet) annotate() bool {
+	return set.Debug && set.DebugAnnotate
+}
+
 func (set *TemplateSet) logf(format string, args ...interface{}) {
 	if set.Debug {
+		if set.Logger != nil {
+			set.Logger.Debugf(format, args...)
+			return
+		}
 		logger.Printf(fmt.Sprintf("[template set: %s] %s", set.name, format), args...)
 	}
 }
 
+// warnf reports a warning to the set's Logger (if any).
+func (set *TemplateSet) warnf(format string, args ...interface{}) {
+	if set.Logger != nil {
+		set.Logger.Warnf(format, args...)
+	}
+}
+
 // Logging function (internally used)
 func logf(format string, items ...interface{}) {
 	if debug {
@@ -247,6 +459,7 @@ var (
 
 	// Methods on the default set
 	FromString           = DefaultSet.FromString
+	MustFromString       = DefaultSet.MustFromString
 	FromBytes            = DefaultSet.FromBytes
 	FromFile             = DefaultSet.FromFile
 	FromCache            = DefaultSet.FromCache
@@ -7,4 +7,17 @@
 {% endautoescape %}
 {% autoescape off %}
 {{ "<script>alert('xss');</script>"|escape }}
-{% endautoescape %}
\ No newline at end of file
+{% endautoescape %}
+{% autoescape json %}
+{"text": "{{ simple.newline_text }}", "quote": "{{ "say \"hi\" & <bye>" }}", "safe": {{ "[\"a\"]"|safe }}}
+{% endautoescape %}
+{% autoescape html %}
+{"text": "{{ simple.newline_text }}", "quote": "{{ "say \"hi\" & <bye>" }}"}
+{% endautoescape %}
+{% autoescape none %}
+{{ "say \"hi\" & <bye>" }}
+{% endautoescape %}
+{% autoescape json %}{% autoescape off %}{{ "\"off\"" }}{% endautoescape %} {{ "\"json\"" }}{% endautoescape %}
+
+{% autoescape on %}{{ "<b>&</b>"|escape }} {{ simple.xss|escape|escape }} {{ "<b>"|safe|escape }}{% endautoescape %}
+{% autoescape off %}{{ "<b>&</b>"|escape }} {{ "<b>"|escape|escape }}{% endautoescape %}
\ No newline at end of file
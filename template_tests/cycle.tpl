@@ -19,4 +19,12 @@
 '{% cycle "item1" simple.name simple.number as cycleitem silent %}'
 '{{ cycleitem }}'
 '{% cycle cycleitem %}'
-'{{ cycleitem }}'
\ No newline at end of file
+'{{ cycleitem }}'{% for outer in simple.multiple_item_list|slice:":2" %}
+    {% for item in simple.multiple_item_list|slice:":2" %}'{% cycle "row1" "row2" "row3" %}'{% endfor %}
+    {% resetcycle %}
+{% endfor %}
+{% for outer in simple.multiple_item_list|slice:":2" %}
+    {% for item in simple.multiple_item_list|slice:":2" %}'{% cycle "a" "b" "c" as letters %}'{% endfor %}
+    {% for item in simple.multiple_item_list|slice:":2" %}'{% cycle "x" "y" "z" %}'{% endfor %}
+    {% resetcycle letters %}
+{% endfor %}
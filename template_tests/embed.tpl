@@ -0,0 +1,6 @@
+{% embed "embed.helper" %}{% block title %}Custom title{% endblock %}{% endembed %}
+{% for name in simple.misc_list %}{% embed "embed.helper" %}{% block body %}{{ name }}: {{ block.Super }}{% endblock %}{% endembed %}
+{% endfor %}{% embed "embed.helper" %}ignored{% block footer %}{% embed "embed.helper" %}{% block title %}Nested{% endblock %}{% endembed %}{% endblock %}{% endembed %}
+{% embed "inheritance/base.tpl" %}{% block content %}Embedded content ({{ block.Super }}){% endblock %}{% endembed %}
+{% embed "embed.helper" %}{% endembed %}
+{% block title %}Outer title{% endblock %}
@@ -53,6 +53,17 @@ in/not in
 {{ "Hello2" in simple.misc_list }}
 {{ 99 in simple.misc_list }}
 {{ False in simple.misc_list }}
+{{ 4 not in simple.multiple_item_list }}
+{{ 1 not in simple.multiple_item_list }}
+{{ 5 not in simple.intmap }}
+{{ 7 not in simple.intmap }}
+{{ "abc" not in simple.strmap }}
+{{ "world" not in "hello world" }}
+{{ "earth" not in "hello world" }}
+{{ (4 not in simple.multiple_item_list) == !(4 in simple.multiple_item_list) }}
+{{ simple.uint in simple.multiple_item_list }}
+{{ 1.5 in simple.intmap }}
+{% if 4 not in simple.multiple_item_list and "Hello" in simple.misc_list %}not in with and{% endif %}
 
 issue #48 (associativity for infix operators)
 {{ 34/3*3 }}
@@ -66,4 +77,42 @@ issue #64 (uint comparison with int const)
 {{ simple.uint >= 8 }}
 {{ simple.uint <= 8 }}
 {{ simple.uint < 8 }}
-{{ simple.uint > 8 }}
\ No newline at end of file
+{{ simple.uint > 8 }}
+
+unary plus/minus
+{{ -simple.number }}
+{{ --simple.number }}
+{{ - -simple.number }}
+{{ +simple.number }}
+{{ -simple.float }}
+{{ -(-simple.float) }}
+{{ 5 * -simple.number }}
+{{ 2 ^ -1 }}
+{{ simple.number - -8 }}
+{% if -simple.number < 0 %}negative{% endif %}
+{% if -simple.number > 0 %}positive{% endif %}
+concatenation
+{{ "user-" ~ "name" }}
+{{ "user-" ~ simple.number }}
+{{ simple.number ~ "-" ~ simple.name ~ "-" ~ 3 }}
+{{ "total: " ~ 2 + 3 }}
+{{ "a" ~ "b" == "ab" }}
+{{ "b" ~ "c" in "abcd" }}
+{{ simple.name|upper ~ "!" }}
+
+is empty
+{{ "" is empty }} {{ "a" is empty }} {{ simple.nil is empty }} {{ simple.undefined is empty }}
+{{ simple.misc_list is empty }} {{ simple.misc_list|slice:":0" is empty }} {{ simple.intmap is empty }}
+{{ 0 is empty }} {% if not 0 %}falsy{% endif %} {{ simple.bool_false is empty }} {% if not simple.bool_false %}falsy{% endif %}
+{{ "a" is not empty }} {{ "" is not empty }}
+{% if simple.name is not empty and 0 is not empty %}both not empty{% endif %}
+
+null coalescing
+{{ simple.undefined ?? simple.nil ?? "none" }}
+{{ simple.undefined ?? simple.name ?? "none" }}
+'{{ simple.name|slice:":0" ?? "fallback" }}' '{{ "" ?? "fallback" }}' {{ 0 ?? 1 }} {{ simple.bool_false ?? true }}
+{{ simple.undefined ?? simple.misc_list|join:"," }}
+{{ (simple.undefined ?? 2) + 3 }} {{ simple.undefined ?? 2 + 3 }}
+{{ simple.bool_false or simple.undefined ?? "or first" }} {{ simple.undefined ?? false or true }}
+{{ simple.name ?? simple.name() }}
+{% set fallback = simple.undefined ?? "set" %}{{ fallback }}{% if simple.undefined ?? true %} if{% endif %}
@@ -0,0 +1 @@
+{% extends "inheritance/components.tpl" %}{% block title %}Child title{% endblock %}
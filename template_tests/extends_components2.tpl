@@ -0,0 +1 @@
+{% extends "inheritance/components.tpl" %}{% block title %}Title{% endblock %}{% block sidebar %}Sidebar ({{ block.Super }}){% endblock %}
@@ -0,0 +1,3 @@
+{% extends ["inheritance/not_existing_base.tpl", "inheritance/base.tpl"] %}
+
+{% block content %}Fallback content{% endblock %}
\ No newline at end of file
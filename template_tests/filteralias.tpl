@@ -0,0 +1,9 @@
+{% filteralias money = floatformat:2 %}{% filteralias shout = upper|stringformat:"%s!" %}{% filteralias loudmoney = money|shout %}
+alias: {{ simple.float|money }}
+chain: {{ simple.name|shout }}
+alias of alias: {{ simple.float|loudmoney }}
+followed by filters: {{ simple.name|shout|lower }}
+in filter-tag: {% filter shout|lower %}Hello{% endfilter %}
+autoescape: {% filteralias raw = safe %}{{ "<b>"|raw }} {{ "<b>" }}
+redefinition: {% filteralias money = floatformat:1 %}{{ simple.float|money }}
+block scope: {% with x=1 %}{% filteralias money = floatformat:3 %}{{ simple.float|money }}{% endwith %} {{ simple.float|money }}
@@ -10,6 +10,16 @@ addslashes
 {{ "plain text"|addslashes|safe }}
 {{ simple.escape_text|addslashes|safe }}
 
+attr
+{{ simple|attr:"name" }}
+{{ simple|attr:"strmap.abc" }}
+{{ complex.comments|attr:"1.Author.Name" }}
+{{ complex.post|attr:"Text"|length }}
+{% with field="number" %}{{ simple|attr:field }}{% endwith %}
+{{ simple|attr:"nothing" }}
+{{ simple|attr:"nothing.deeper" }}
+{{ complex.comments|attr:"5.Author" }}
+
 capfirst
 {{ ""|capfirst }}
 {{ 5|capfirst }}
@@ -20,6 +30,10 @@ capfirst
 cut
 {{ 15|cut:"5" }}
 {{ "Hello world"|cut: " " }}
+{{ " a b  c "|cut:" " }}
+{{ "banana"|cut:"an" }}
+{{ "Hello world"|cut:"xyz" }}
+{{ "Hello world"|cut:"" }}
 
 default
 {{ simple.nothing|default:"n/a" }}
@@ -27,6 +41,17 @@ default
 {{ simple.number|default:"n/a" }}
 {{ 5|default:"n/a" }}
 
+{{ simple.number.missing|default:"n/a" }}
+{{ simple.name.missing.attr|default:simple.number }}
+{{ simple.number.missing|upper|default:"n/a"|capfirst }}
+{% if simple.number.missing|default:false %}yes{% else %}no{% endif %}
+
+default_if_error
+{{ simple.number.missing|default_if_error:"n/a" }}
+{{ simple.number|default_if_error:"n/a" }}
+{{ simple.nothing|default_if_error:"n/a" }}
+{{ ""|default_if_error:"n/a" }}
+
 default_if_none
 {{ simple.nothing|default_if_none:"n/a" }}
 {{ ""|default_if_none:"n/a" }}
@@ -46,6 +71,13 @@ safe
 
 escape
 {{ "<script>"|safe|escape }}
+{{ "<script>"|escape }}
+{{ "<script>"|escape|escape }}
+{{ "<script>"|escape|force_escape }}
+
+force_escape
+{{ "<script>"|force_escape }}
+{{ "<script>"|safe|force_escape }}
 
 title
 {{ ""|title }}
@@ -73,6 +105,11 @@ divisibleby
 
 striptags
 {{ "<strong><i>Hello!</i></strong>"|striptags|safe }}
+{{ "<div class=\"outer\"><p>Nested <b>bold <i>and italic</i></b> text</p></div>"|striptags }}
+{{ "<a title=\"a > b\" data-x='>'>Link</a> <!-- a <comment> -->after"|striptags }}
+{{ "<p>Fish &amp; Chips &lt;3</p>"|striptags }}
+{{ "<p>Fish &amp; Chips &lt;3</p>"|striptags|safe }}
+{{ "&lt;script&gt;alert(1)&lt;/script&gt;"|striptags|safe }}
 
 removetags
 {{ "<strong><i>Hello!</i></strong>"|removetags:"i"|safe }}
@@ -98,8 +135,6 @@ walrus{{ 1|pluralize:"es" }}
 walrus{{ simple.number|pluralize:"es" }}
 
 random
-{{ 5|random }}
-{{ ""|random }}
 {{ "h"|random }}
 {{ simple.one_item_list|random }}
 
@@ -111,6 +146,22 @@ first
 {{ nothing|first }}
 {{ simple.chinese_hello_world|first }}
 
+json
+{% autoescape off %}{{ simple.strmap|json }}
+{{ simple.multiple_item_list|json }}
+{{ simple.name|json }}
+{{ simple.number|json }}
+{{ simple.nothing|json }}
+{{ "<script>"|json }}{% endautoescape %}
+{{ "a \"quoted\" string"|json }}
+
+tojson
+{% autoescape off %}{{ simple.strmap|tojson }}
+{{ simple.multiple_item_list|tojson }}
+{{ simple.name|tojson }}
+{{ simple.number|tojson }}
+{{ simple.nothing|tojson }}{% endautoescape %}
+
 last
 {{ "Test"|last }}
 {{ complex.comments|last }}
@@ -127,6 +178,13 @@ linebreaksbr
 {{ ""|linebreaksbr }}
 {{ "hallo"|linebreaksbr }}
 
+br2nl
+{{ "a<br>b<br/>c<br />d"|br2nl }}
+{{ "a<BR>b<Br/>c<bR />d<br  / >e"|br2nl }}
+{{ "<brx>no <break> <b>br</b>"|br2nl }}
+{{ ""|br2nl }}
+{% if simple.newline_text|linebreaksbr|br2nl == simple.newline_text %}round-trip ok{% endif %}
+
 length_is
 {{ simple.name|length_is:8 }}
 {{ simple.name|length_is:10 }}
@@ -179,6 +237,24 @@ join
 split
 {{ "Hello, 99, 3.140000, good"|split:", "|join:", " }}
 
+startswith
+{{ "/admin/users"|startswith:"/admin" }}
+{{ "/users"|startswith:"/admin" }}
+{{ "/admin"|startswith:"/admin/users" }}
+{{ "/admin"|startswith:"" }}
+{{ ""|startswith:"/admin" }}
+{{ simple.chinese_hello_world|startswith:"你好" }}
+{{ 12345|startswith:"12" }}
+{% if "/admin/users"|startswith:"/admin" %}admin area{% endif %}
+
+endswith
+{{ "image.png"|endswith:".png" }}
+{{ "image.png"|endswith:".jpg" }}
+{{ "image.png"|endswith:"" }}
+{{ ""|endswith:".png" }}
+{{ simple.chinese_hello_world|endswith:"世界" }}
+{{ 12345|endswith:"45" }}
+
 stringformat
 {{ simple.float|stringformat:"%.2f" }}
 {{ simple.uint|stringformat:"Test: %d" }}
@@ -188,6 +264,26 @@ make_list
 {{ simple.name|make_list|join:", " }}
 {% for char in simple.name|make_list %}{{ char }}{% endfor %}
 
+keys, values and items
+{% for k in simple.strmap|keys %}{{ k }},{% endfor %}
+{% for v in simple.strmap|values %}{{ v }},{% endfor %}
+{% for pair in simple.strmap|items %}{{ pair.0 }}={{ pair.1 }},{% endfor %}
+{% for k, v in simple.strmap|items %}{{ k }}={{ v }},{% endfor %}
+{{ {"b": 2, "a": 1}|keys|join:"" }} {{ {"b": 2, "a": 1}|values|join:"" }} {{ {}|items|length }}
+{% for k in simple.strmap|keys reversed %}{{ k }},{% endfor %}
+enumerate
+{% for i, x in simple.misc_list|enumerate %}{{ i }}:{{ x }} {% endfor %}
+{% for i, x in simple.misc_list|enumerate:1 %}{{ i }}:{{ x }} {% endfor %}
+{% for i, x in [10, 20]|enumerate:100 %}{{ i }}={{ x }} {% endfor %}
+{% for pair in "ab"|enumerate:simple.number %}{{ pair.0 }}{{ pair.1 }} {% endfor %}
+{% for i, x in simple.misc_list|slice:":0"|enumerate %}{{ i }}{% empty %}empty{% endfor %}
+merge
+{% set base = {"class": "btn", "type": "button"} %}{% set merged = base|merge:{"class": "btn primary", "id": "save"} %}{% for k, v in merged sorted %}{{ k }}="{{ v }}" {% endfor %}
+{% for k, v in base sorted %}{{ k }}="{{ v }}" {% endfor %}
+{% for k, v in base|merge:{"disabled": true} sorted %}{{ k }}={{ v }} {% endfor %}
+{% for k, v in simple.strmap|merge:base sorted %}{{ k }}={{ v }} {% endfor %}
+{{ base|merge:simple.nothing|length }} {{ {}|merge:{}|length }}
+
 center
 '{{ "test"|center:3 }}'
 '{{ "test"|center:19 }}'
@@ -197,21 +293,67 @@ center
 '{{ "test2"|center:20 }}'
 {{ "test2"|center:20|length }}
 '{{ simple.chinese_hello_world|center:20 }}'
+'{{ "ab"|center:"6,*" }}'
+'{{ "abc"|center:"6,*" }}'
+'{{ simple.chinese_hello_world|center:"7,-" }}'
+'{{ "test"|center:"3,*" }}'
 
 ljust
 '{{ "test"|ljust:"2" }}'
 '{{ "test"|ljust:"20" }}'
 {{ "test"|ljust:"20"|length }}
 '{{ simple.chinese_hello_world|ljust:10 }}'
+'{{ "test"|ljust:"8,." }}'
+'{{ simple.chinese_hello_world|ljust:"6,好" }}'
+
+regex_match
+{{ "john@example.com"|regex_match:"^[^@]+@[^@]+$" }}
+{{ "john.example.com"|regex_match:"^[^@]+@[^@]+$" }}
+{{ "Hello World"|regex_match:"world" }}
+{{ "Hello World"|regex_match:"world,i" }}
+{{ "Hello World"|regex_match:"^hello world$,i" }}
+{{ "ab12"|regex_match:"^[a-z]{1,2}\\d+$" }}
+{{ "a,b"|regex_match:"a\\,b," }}
+{{ 12345|regex_match:"^\\d+$" }}
+{% if "john@example.com"|regex_match:"@example\\.com$" %}internal{% endif %}
+
+regex_replace
+{{ "Order 123 and 4567"|regex_replace:"\\d+,#" }}
+{{ "John Smith"|regex_replace:"(\\w+) (\\w+),$2 $1" }}
+{{ "2014-05-21"|regex_replace:"(?P<y>\\d{4})-(?P<m>\\d{2})-(?P<d>\\d{2}),${d}.${m}.${y}" }}
+{{ "a1b22c333"|regex_replace:"\\d{2,3},_" }}
+{{ "a,b;c"|regex_replace:"[,;], and " }}
+{{ "a,b"|regex_replace:"\\,,+" }}
+{{ "x"|regex_replace:"x,a,b" }}
+{{ "nothing to do"|regex_replace:"\\d+,#" }}
+{{ "remove digits 42"|regex_replace:"\\s*\\d+," }}
+
+replace
+{{ "foo bar foo baz foo"|replace:"foo,qux" }}
+{{ "foo bar foo baz foo"|replace:"foo,qux,2" }}
+{{ "foo bar foo baz foo"|replace:"foo,qux,0" }}
+{{ "foo bar foo"|replace:"foo," }}
+{{ "a,b,c"|replace:"\\,,;" }}
+{{ "a, b, c"|replace:"\\, ,\\,,1" }}
+{{ "back\\slash"|replace:"\\\\,/" }}
+{{ simple.chinese_hello_world|replace:"世界,world" }}
+{{ 12321|replace:"2,x" }}
 
 rjust
 '{{ "test"|rjust:"2" }}'
 '{{ "test"|rjust:"20" }}'
 {{ "test"|rjust:"20"|length }}
 '{{ simple.chinese_hello_world|rjust:10 }}'
+'{{ "test"|rjust:"8,0" }}'
+'{{ "test"|rjust:"2,0" }}'
 
 wordcount
 {{ ""|wordcount }}
+{{ "   "|wordcount }}
+{{ "  multiple   spaces	and tabs "|wordcount }}
+{{ simple.newline_text|wordcount }}
+{{ simple.chinese_hello_world|wordcount }}
+{% if simple.long_text|wordcount > 10 %}more than 10 words{% endif %}
 {% filter wordcount %}{% lorem 25 w %}{% endfilter %}
 
 wordwrap
@@ -227,9 +369,59 @@ linebreaks
 {{ simple.long_text|linebreaks|safe }}
 {{ simple.name|linebreaks|safe }}
 
+linecount
+{{ ""|linecount }}
+{{ simple.name|linecount }}
+{{ simple.newline_text|linecount }}
+{{ simple.long_text|linecount }}
+{% filter linecount %}trailing
+newline
+{% endfilter %}
+{% filter linecount %}
+
+{% endfilter %}
+{% if simple.long_text|linecount == 6 %}6 lines{% endif %}
+
 linenumbers
 {% filter linenumbers %}{% lorem 10 %}{% endfilter %}
 
+number_format
+{{ 1234567.891|number_format }}
+{{ 1234567.891|number_format:2 }}
+{{ 1234567.891|number_format:"2,'.',' '" }}
+{{ 1234567.891|number_format:"2,',','.'" }}
+{{ 1234567.891|number_format:"3, ',' , ''" }}
+{{ 999.996|number_format:"2" }}
+{{ 100|number_format:"2" }}
+{{ 12|number_format }}
+{% with n=-1234567.891 %}{{ n|number_format:"2,',','.'" }}{% endwith %}
+{% with n=-123 %}{{ n|number_format }}{% endwith %}
+{% with n=-0.001 %}{{ n|number_format:2 }}{% endwith %}
+{{ "98765.4321"|number_format:"1" }}
+{{ 0|number_format:"2,','" }}
+
+icontains
+{{ "Hello World"|icontains:"WORLD" }} {{ "Hello World"|icontains:"lo wo" }} {{ "Hello World"|icontains:"planet" }}
+{{ "ÜBER Äpfel"|icontains:"über äpf" }} {{ "straße"|icontains:"STRASSE" }} {{ simple.name|icontains:"JOHN" }} {{ ""|icontains:"" }}
+{% if "Hello World"|icontains:"hello" %}match{% endif %}{% if "Hello World"|icontains:"bye" %}no match{% endif %}
+{% if "WORLD"|lower in "Hello World"|lower %}filters before in{% endif %}{% if "Planet"|lower in "Hello World"|lower %}no{% endif %}
+zip
+{% for a, b in simple.misc_list|zip:simple.multiple_item_list %}{{ a }}-{{ b }} {% endfor %}
+{% for a, b in simple.one_item_list|zip:simple.misc_list %}{{ a }}-{{ b }} {% endfor %}
+{% for t in simple.misc_list|zip:simple.multiple_item_list|zip:"abcd" %}{{ t|join:"," }} {% endfor %}
+{% for a, b, c in simple.misc_list|zip:simple.multiple_item_list|zip:"abcd" %}{{ a }}-{{ b }}-{{ c }} {% endfor %}
+{{ simple.misc_list|zip:simple.misc_list|length }} {{ simple.misc_list|zip:""|length }}
+
+normalize_space
+[{{ "  some   spaced    text  "|normalize_space }}]
+[{{ simple.newline_text|normalize_space }}]
+[{{ ""|normalize_space }}]
+[{% filter normalize_space %}
+	Tabs,	newlines
+  and   {{ simple.name }}
+	{% for i in simple.one_item_list %}  {{ i }}  {% endfor %}
+{% endfilter %}]
+
 phone2numeric
 {{ "999-PONGO2"|phone2numeric }}
 
@@ -239,6 +431,12 @@ truncatewords
 {{ simple.chinese_hello_world|truncatewords:0 }}
 {{ simple.chinese_hello_world|truncatewords:1 }}
 {{ simple.chinese_hello_world|truncatewords:2 }}
+{{ "one two three four"|truncatewords:"2" }}
+{{ "one two three four"|truncatewords:"2,[more]" }}
+{{ "one two three four"|truncatewords:"2,(...)" }}
+{{ "one two three four"|truncatewords:"2," }}
+{{ "one two three four"|truncatewords:"4,[more]" }}
+{{ "one   two      three   four"|truncatewords:3 }}
 
 urlize
 {{ "http://www.florian-schlachter.de"|urlize|safe }}
@@ -278,6 +476,12 @@ Please mail me at demo@example.com or visit mit on:
 escapejs
 {{ simple.escape_js_test|escapejs|safe }}
 
+sortkeys
+{% for key, value in simple.strmap|sortkeys %}{{ key }}={{ value }} {% endfor %}
+{% for key, value in simple.intmap|sortkeys %}{{ key }}={{ value }} {% endfor %}
+{% for item in simple.intmap|sortkeys %}{{ item.0 }} {% endfor %}
+{{ simple.strmap|sortkeys|length }}
+
 slice
 {{ simple.multiple_item_list|slice:":99"|join:"," }}
 {{ simple.multiple_item_list|slice:"99:"|join:"," }}
@@ -288,6 +492,15 @@ slice
 {{ simple.multiple_item_list|slice:"2:1"|join:"," }}
 {{ "Test"|slice:"1:3" }}
 {{ simple.chinese_hello_world|slice:"1:3" }}
+{{ simple.multiple_item_list|slice:"-3:"|join:"," }}
+{{ simple.multiple_item_list|slice:":-7"|join:"," }}
+{{ simple.multiple_item_list|slice:"-99:2"|join:"," }}
+{{ simple.multiple_item_list|slice:"::3"|join:"," }}
+{{ simple.multiple_item_list|slice:"1:8:2"|join:"," }}
+{{ simple.multiple_item_list|slice:"::-1"|join:"," }}
+{{ simple.multiple_item_list|slice:"-2:2:-3"|join:"," }}
+{{ simple.multiple_item_list|slice:"2:5:-1"|join:"," }}
+{{ "Hello"|slice:"::-1" }} {{ "Hello"|slice:"-3:" }} {{ "Hello"|slice:"::2" }} {{ simple.chinese_hello_world|slice:"::-1" }}
 
 truncatechars_html
 {{ "This is a long test which will be cutted after some chars."|truncatechars_html:25 }}
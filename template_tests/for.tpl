@@ -25,3 +25,60 @@ reversed sorted string map
 
 reversed sorted int map
 '{% for key in simple.intmap reversed sorted %}{{ key }} {% endfor %}'
+
+revcounter
+'{% for item in simple.multiple_item_list %}{{ item }}:{{ forloop.Revcounter }}/{{ forloop.Revcounter0 }} {% endfor %}'
+
+revcounter reversed
+'{% for item in simple.multiple_item_list reversed %}{{ item }}:{{ forloop.Revcounter }}/{{ forloop.Revcounter0 }} {% endfor %}'
+
+revcounter sorted string map
+'{% for key in simple.strmap sorted %}{{ key }}:{{ forloop.Revcounter }}/{{ forloop.Revcounter0 }} {% endfor %}'
+
+revcounter string
+'{% for char in "abc" %}{{ char }}:{{ forloop.Revcounter }}/{{ forloop.Revcounter0 }} {% endfor %}'
+
+limit
+'{% for item in simple.multiple_item_list limit 3 %}{{ forloop.Counter }}/{{ forloop.Revcounter }}:{{ item }}{% if forloop.First %}(first){% endif %}{% if forloop.Last %}(last){% endif %} {% endfor %}'
+
+offset
+'{% for item in simple.multiple_item_list offset 7 %}{{ forloop.Counter }}/{{ forloop.Revcounter }}:{{ item }}{% if forloop.First %}(first){% endif %}{% if forloop.Last %}(last){% endif %} {% endfor %}'
+
+limit and offset
+'{% for item in simple.multiple_item_list limit 3 offset 2 %}{{ forloop.Counter0 }}/{{ forloop.Revcounter0 }}:{{ item }} {% endfor %}'
+'{% for item in simple.multiple_item_list offset simple.number - 34 limit 1 + 1 %}{{ forloop.Counter }}:{{ item }} {% endfor %}'
+
+limit and offset reversed
+'{% for item in simple.multiple_item_list reversed limit 3 offset 1 %}{{ forloop.Counter }}:{{ item }} {% endfor %}'
+
+limit larger than the list
+'{% for item in simple.multiple_item_list limit 100 offset 8 %}{{ forloop.Counter }}/{{ forloop.Revcounter }}:{{ item }} {% endfor %}'
+
+limit and offset leaving nothing
+'{% for item in simple.multiple_item_list limit 0 %}{{ item }}{% empty %}empty{% endfor %}'
+'{% for item in simple.multiple_item_list offset 10 %}{{ item }}{% empty %}empty{% endfor %}'
+'{% for key, value in simple.strmap sorted limit 2 %}{{ key }}={{ value }} {% endfor %}'
+
+
+forloop.Cycle
+'{% for item in simple.multiple_item_list limit 5 %}{{ forloop.Cycle("odd", "even") }} {% endfor %}'
+'{% for item in simple.misc_list %}{{ item }}:{{ forloop.Cycle(1, 2, 3) }} {% endfor %}'
+'{% for item in simple.one_item_list %}{{ forloop.Cycle("only") }}{% for i in range(0, 3) %} {{ forloop.Parentloop.Cycle("a", "b") }}{{ forloop.Cycle("x", "y") }}{% endfor %}{% endfor %}'
+
+for-if
+'{% for comment in complex.comments if comment.Author.Validated %}{{ forloop.Counter }}/{{ forloop.Revcounter }}:{{ comment.Author.Name }}{% if forloop.Last %} (last){% endif %} {% endfor %}'
+'{% for comment in complex.comments if not comment.Author.Validated %}{{ comment.Author.Name }}{% if forloop.First and forloop.Last %} (only){% endif %}{% endfor %}'
+'{% for comment in complex.comments if comment.Author.Name == "nobody" %}{{ comment.Author.Name }}{% empty %}all filtered out{% endfor %}'
+'{% for item in simple.multiple_item_list if item > 2 limit 3 offset 1 %}{{ forloop.Counter }}:{{ item }} {% endfor %}'
+'{% for key, value in simple.strmap sorted if value != "cde" %}{{ key }}={{ value }} {% endfor %}'
+
+{# inline literals #}
+'{% for x in [1, 2, 3] %}{{ forloop.Counter }}:{{ x }} {% endfor %}'
+'{% for x in [1, 2, 3] reversed %}{{ x }}{% endfor %}'
+'{% for x in ["a", simple.name, [1, 2]|length] if x %}{{ x }},{% endfor %}'
+'{% for x in [] %}{{ x }}{% empty %}empty list{% endfor %}'
+'{% for k, v in {"a": 1} %}{{ k }}={{ v }}{% endfor %}'
+'{% for k, v in {"b": "xy", a: {"nested": 1}, "c": [3]} sorted %}{{ k }}={{ v|length }} {% endfor %}'
+'{% for k, v in {} %}{{ k }}{% empty %}empty dict{% endfor %}'
+'{% for row in [{"name": "x", "ids": [1, 2]}, {"name": "y", "ids": []}] %}{{ row.name }}:{% for id in row.ids %}{{ id }}{% empty %}-{% endfor %} {% endfor %}'
+'{% for k,v in {"a":[1,2]}%}{% for x in v%}{{x}}{% endfor %}{% endfor %}'
@@ -15,4 +15,7 @@
 {% if simple.number < 42 %}false{% elif simple.number > 42 %}no{% elif simple.number != 42 %}no{% else %}yes{% endif %}
 {% if 0 %}!0{% elif nothing %}nothing{% else %}true{% endif %}
 {% if 0 %}!0{% elif simple.float %}simple.float{% else %}false{% endif %}
-{% if 0 %}!0{% elif !simple.float %}false{% elif "Text" in complex.post%}Elseif with no else{% endif %}
\ No newline at end of file
+{% if 0 %}!0{% elif !simple.float %}false{% elif "Text" in complex.post%}Elseif with no else{% endif %}
+{% if (n := simple.multiple_item_list|length) > 5 %}{{ n }} items{% endif %}|{{ n }}
+{% if (name := simple.nothing) %}yes {{ name }}{% elif (name := simple.name|upper) %}elif {{ name }}{% else %}no{% endif %}
+{% if (first := simple.multiple_item_list|first) and first == 1 %}first is {{ first }}{% endif %}
@@ -4,4 +4,14 @@ Start '{% include "includes.helper" with what_am_i=simple.name only %}' End
 Start '{% include "includes.helper" with what_am_i=simple.name %}' End
 Start '{% include simple.included_file|lower with number=7 what_am_i="guest" %}' End
 Start '{% include "includes.helper.not_exists" if_exists %}' End
-Start '{% include simple.included_file_not_exists if_exists with number=7 what_am_i="guest" %}' End
\ No newline at end of file
+Start '{% include simple.included_file_not_exists if_exists with number=7 what_am_i="guest" %}' End
+Each '{% include_each "includes.helper" for simple.misc_list as what_am_i %}' End
+Each '{% for number in simple.multiple_item_list|slice:":2" %}{% include_each "includes.helper" for simple.one_item_list as what_am_i %}{% endfor %}' End
+Each '{% include_each "includes.helper" for simple.nothing as what_am_i %}' End
+Escaping '{% include "includes.helper" with what_am_i="<b>" %}' '{% include "includes.helper" autoescape off with what_am_i="<b>" %}' '{% include "includes.helper" autoescape on with what_am_i="<b>" %}' End
+Escaping '{% include simple.included_file|lower autoescape off with what_am_i="<i>" %}' '{% include "includes.helper" if_exists autoescape off with what_am_i="<u>" only %}' End
+{% autoescape off %}Escaping '{% include "includes.helper" with what_am_i="<b>" %}'{% endautoescape %}
+Fallback '{% include ["includes.helper.not_exists", "includes.helper"] with what_am_i="fallback" %}' End
+Fallback '{% include ["includes.helper", "includes.helper.not_exists"] with what_am_i="first" %}' End
+Fallback '{% include [simple.included_file_not_exists, simple.included_file|lower] with number=7 what_am_i="lazy" %}' End
+Fallback '{% include ["includes.helper.not_exists", "includes.helper.not_exists_either"] if_exists %}' End
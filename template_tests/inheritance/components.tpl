@@ -0,0 +1 @@
+[{% block title required %}Default title{% endblock %}][{% block sidebar optional %}Default sidebar{% endblock %}][{% block footer %}Default footer{% endblock %}]
\ No newline at end of file
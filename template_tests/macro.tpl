@@ -27,4 +27,21 @@ Importing macros
 
 Chaining macros{% import "macro2.helper" greeter_macro %}
 {{ greeter_macro() }}
+
+Namespaced import{% import "macro.helper" as helpers %}
+{{ helpers.imported_macro("User3") }}
+{{ helpers.imported_macro_void() }}
+
+Direct import{% from "macro.helper" import imported_macro as from_macro, imported_macro_void %}
+{{ from_macro("User4") }}
+{{ imported_macro_void() }}
+
+Call blocks
+{% macro panel(title, class="panel") %}<div class="{{ class }}"><h2>{{ title }}</h2>{{ caller() }}</div>{% endmacro %}
+{% call panel("News") %}<p>Hello {{ simple.name }}!</p>{% endcall %}
+{% call panel("<Escaped>", "box") %}{% for item in simple.multiple_item_list|slice:":3" %}{{ item }}{% endfor %}{% endcall %}
+{% set title = "call-site" %}{% macro scoped(title) %}{{ title }}: {{ caller() }}{% endmacro %}{% call scoped("macro") %}{{ title }}{% endcall %}
+{% macro twice() %}{{ caller() }}{{ caller() }}{% endmacro %}{% call twice() %}{% call panel("Nested") %}x{% endcall %}{% endcall %}
+{% call helpers.imported_wrapper() %}namespaced{% endcall %} {% call imported_macro_void() %}caller unused{% endcall %}
+{% set n = "before" %}{% macro run() %}{{ caller() }}{% endmacro %}{% call run() %}{% set n = "body" %}{{ n }}{% endcall %}|{{ n }}
 End
\ No newline at end of file
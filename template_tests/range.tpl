@@ -0,0 +1,8 @@
+ascending: {% for i in range(1, 5) %}{{ i }} {% endfor %}
+descending: {% for i in range(5, 0, -1) %}{{ i }} {% endfor %}
+step: {% for i in range(0, 10, 3) %}{{ i }} {% endfor %}
+negative step: {% for i in range(10, -10, -4) %}{{ i }} {% endfor %}
+empty: {% for i in range(5, 1) %}{{ i }}{% empty %}no items{% endfor %}
+empty (step): {% for i in range(1, 5, -1) %}{{ i }}{% empty %}no items{% endfor %}
+variables: {% for i in range(simple.number, simple.number + 3) %}{{ i }} {% endfor %}
+length: {{ range(0, 100, 10)|length }}
@@ -3,4 +3,31 @@
 {{ new_var }}{% for item in simple.misc_list %}
 {% set new_var = item %}{{ new_var }}{% endfor %}
 {{ new_var }}
-{% set car=someUndefinedVar %}{{ car.Drive }}No Panic
\ No newline at end of file
+{% set car=someUndefinedVar %}{{ car.Drive }}No Panic
+{% set total = 0 %}{% for i in range(1, 5) %}{% set total += i %}{% endfor %}{{ total }}
+{% set total = 10 %}{% for i in range(0, 3) %}{% set total -= 2 %}{% endfor %}{{ total }}
+{% set product = 1 %}{% for i in range(1, 5) %}{% for j in range(0, 1) %}{% set product *= i %}{% endfor %}{% endfor %}{{ product }}
+{% set half = 9 %}{% set half /= 2 %}{{ half }} {% set half = 9.0 %}{% set half /= 2 %}{{ half }}
+{% set sum = 1 %}{% set sum += 0.5 %}{{ sum }}
+{% set s = "a" %}{% for item in simple.misc_list %}{% set s += "-" ~ item %}{% endfor %}{{ s }}
+{% for i in range(0, 3) %}{% set local = 1 %}{% set local += i %}{{ local }}{% endfor %}
+{% set total = 0 %}{% for item in simple.multiple_item_list %}{% set total = total + item %}{% endfor %}total: {{ total }}
+{% set count = 0 %}{% for i in range(0, 2) %}{% for j in range(0, 3) %}{% set count += 1 %}{% endfor %}{% endfor %}count: {{ count }}
+{% set i = "outer" %}{% for i in range(0, 2) %}{% set i = "inner" %}{% endfor %}{{ i }}
+{% with w = "with" %}{% set w = "changed" %}{% set i = "in with" %}{% endwith %}{{ w }}|{{ i }}
+{% macro m(i) %}{% set i = "macro" %}{% endmacro %}{{ m(1) }}{{ i }}
+{% set x = 'outer' %}{% macro mx() %}{% set x = 'inner' %}{% endmacro %}{{ mx() }}{{ x }}
+{% for k in range(0, 2) %}{% set unset_before = k %}{% endfor %}[{{ unset_before }}]
+{% set first, second = simple.multiple_item_list|slice:":2" %}{{ first }}-{{ second }}
+{% set greeting, number, pi, word = simple.misc_list %}{{ word }} {{ greeting }} {{ number }}
+{% set low = 0 %}{% set high = 0 %}{% for i in range(0, 1) %}{% set low, high = simple.multiple_item_list|slice:"2:4" %}{% endfor %}{{ low }}/{{ high }}
+{% set items = [1, 2, 3] %}{% for item in items %}{{ item }}{% if not forloop.Last %},{% endif %}{% endfor %} ({{ items|length }})
+{% set mixed = ["a", simple.number, 1.5, true, [1, 2], ] %}{{ mixed|length }}
+{% set empty_list = [] %}{% set empty_dict = {} %}{{ empty_list|length }}/{{ empty_dict|length }}
+{% set m = {"a": 1, "b": simple.name, c: "x"|upper} %}{% for k, v in m sorted %}{{ k }}={{ v }} {% endfor %}{{ m.a }}
+{% set nested = {"list": [1, {"x": 2}], "dict": {"d": "e"}} %}{{ nested.list.1.x }} {{ nested.dict.d }}
+{% set pages = [{"title": "Home", "url": "/"}, {"title": "<About>", "url": "/about/"}] %}{% for page in pages %}<a href="{{ page.url }}">{{ page.title }}</a>{% endfor %}
+{{ 2 in [1, 2, 3] }} {{ "z" in {"z": 1} }} {{ [3, 1, 2]|join:"," }}
+
+{{ {"a": {"b": [1, 2]}}|length }} {{ [[1, 2], [3]]|length }}
+{{ {"a": {"b": {"c": 1}}}|length }}{{ "x" }} {{ {"a": {"b": 1}} |length }}
\ No newline at end of file
@@ -0,0 +1,22 @@
+Begin
+{% strip %}
+
+   
+package main   
+
+func main() {	
+{% for name in simple.misc_list|slice:":2" %}    println("{{ name }}")  
+{% endfor %}}
+
+
+{% endstrip %}
+{% strip %}  no newlines  {% endstrip %}|{% strip %}
+
+{% endstrip %}|
+{% strip %}{% spaceless %}
+<p>
+  <b>x</b>   
+</p>
+{% endspaceless %}
+{% endstrip %}
+End
\ No newline at end of file
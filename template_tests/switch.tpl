@@ -0,0 +1,7 @@
+{% switch simple.str %}{% case "foo" %}foo{% case "string" %}string{% default %}default{% endswitch %}
+{% switch simple.str %}{% case "foo" %}foo{% default %}default{% endswitch %}
+{% switch simple.str %}{% case "foo" %}foo{% endswitch %}
+{% switch simple.number %}{% case 1 2 3 %}small{% case 41 42 simple.uint %}big{% endswitch %}
+{% switch simple.uint %}{% case 1 2 3 %}small{% case 41 42 8 %}big{% endswitch %}
+{% for item in simple.multiple_item_list %}{% switch item %}
+	{% case 1 %}one{% case 2 3 %}two or three{% default %}{{ item }}{% endswitch %}, {% endfor %}
@@ -1,3 +1,8 @@
 {% filter lower %}This is a nice test; let's see whether it works. Foobar. {{ simple.xss }}{% endfilter %}
 
-{% filter truncatechars:10|lower|length %}This is a nice test; let's see whether it works. Foobar. {{ simple.number }}{% endfilter %}
\ No newline at end of file
+{% filter truncatechars:10|lower|length %}This is a nice test; let's see whether it works. Foobar. {{ simple.number }}{% endfilter %}
+{% filter escape %}<p>{{ "<b>" }} & {{ simple.xss }}</p>{% endfilter %}
+{% filter escape|upper %}{{ "a<b" }}{% endfilter %}
+{% filter upper %}<p>{{ "a<b" }}</p>{% endfilter %}
+{% autoescape off %}{% filter escape %}{{ "a<b" }}{% endfilter %}{% endautoescape %}
+{% filteralias esc = escape %}{% filter esc %}{{ "a<b" }}{% endfilter %}
\ No newline at end of file
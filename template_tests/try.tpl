@@ -0,0 +1,6 @@
+no error: '{% try %}{{ simple.name }}{% except %}fallback{% endtry %}'
+erroring filter: '{% try %}before {{ 5|random }} after{% except %}fallback{% endtry %}'
+error message: '{% try %}{{ ""|random }}{% except as err %}{{ err }}{% endtry %}'
+without except: '{% try %}{{ ""|random }}{% endtry %}'
+nested: '{% try %}{% try %}{{ ""|random }}{% except %}{{ 5|random }}{% endtry %}{% except %}outer fallback{% endtry %}'
+in loop: '{% for i in simple.multiple_item_list|slice:":4" %}{% try %}{{ i|divisibleby:2|yesno:"even,odd,x" }}{% if i == 2 %}{{ i|random }}{% endif %}{% except %}!{% endtry %} {% endfor %}'
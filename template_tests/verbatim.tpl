@@ -4,4 +4,11 @@
 {% test %}
 {% endverbatim %}{{ simple.number }}.
 
-.{{ simple.number }}{% verbatim %}{{ test }}{% endverbatim %}{{ simple.number }}.
\ No newline at end of file
+.{{ simple.number }}{% verbatim %}{{ test }}{% endverbatim %}{{ simple.number }}.
+
+.{{ simple.number }}{% verbatim vue %}<div id="app">
+  <p>{{ vueVar }}</p>{# not a pongo2 comment #}
+  <p v-if="ok">{% verbatim %}{{ nested }}{% endverbatim %}</p>
+</div>{% endverbatim vue %}{{ simple.number }}.
+
+.{% verbatim a %}{% verbatim b %}{{ x }}{% endverbatim b %}{% endverbatim a %}.
\ No newline at end of file
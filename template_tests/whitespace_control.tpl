@@ -0,0 +1,19 @@
+   
+  {{- "start of template" }}trim before: '  {{- simple.name }}'
+trim after: '{{ simple.name -}}   '
+trim both: '   {{- simple.name -}}   '
+tags: [
+    {%- for item in simple.one_item_list %}
+    <{{ item }}>
+    {%- endfor %}
+]
+tags (trim after): [
+    {% for item in simple.multiple_item_list|slice:":3" -%}
+    <{{ item }}>
+    {% endfor -%}
+]
+across comments: 'a' {# comment #}  {# another #}  {%- if true -%} {# after #}  'b'{% endif %}
+no trim: ' {{ simple.name }} ' '{{ -simple.number }}'
+{{ "end of template" -}}
+
+   
\ No newline at end of file
@@ -1,11 +1,15 @@
 package pongo2
 
 import (
+	"encoding"
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"sort"
 	"strconv"
 	"strings"
+
+	"github.com/juju/errors"
 )
 
 type Value struct {
@@ -33,11 +37,15 @@ func AsSafeValue(i interface{}) *Value {
 	}
 }
 
+// getResolvedValue returns the value the methods of Value work on: pointers
+// are dereferenced and nullable types (like sql.NullString) are resolved to
+// their underlying value (this is invalid for NULL values).
 func (v *Value) getResolvedValue() reflect.Value {
-	if v.val.IsValid() && v.val.Kind() == reflect.Ptr {
-		return v.val.Elem()
+	rv := v.val
+	if rv.IsValid() && rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
 	}
-	return v.val
+	return resolveNullable(rv)
 }
 
 // IsString checks whether the underlying value is a string
@@ -76,6 +84,33 @@ func (v *Value) IsNumber() bool {
 	return v.IsInteger() || v.IsFloat()
 }
 
+// IsCallable checks whether the underlying value is a function (a Go
+// function or a macro) which can be called using Call.
+func (v *Value) IsCallable() bool {
+	return v.getResolvedValue().Kind() == reflect.Func
+}
+
+// Call calls the underlying function (a Go function or a macro) with the given
+// arguments the same way templates do (e. g. `{{ fn(arg) }}`) and returns its
+// result. Functions which take an *ExecutionContext can't be called this way.
+func (v *Value) Call(args ...*Value) (*Value, error) {
+	if !v.IsCallable() {
+		return nil, errors.Errorf("value is not callable (it is %s)", v.getResolvedValue().Kind().String())
+	}
+
+	rv, err := callFunction(nil, v.getResolvedValue(), "<value>", args)
+	if err != nil {
+		return nil, err
+	}
+	if rv.Type() == typeOfValuePtr {
+		if result := rv.Interface().(*Value); result != nil {
+			return result, nil
+		}
+		return AsValue(nil), nil
+	}
+	return AsValue(rv.Interface()), nil
+}
+
 // IsNil checks whether the underlying value is NIL
 func (v *Value) IsNil() bool {
 	//fmt.Printf("%+v\n", v.getResolvedValue().Type().String())
@@ -91,7 +126,11 @@ func (v *Value) IsNil() bool {
 //     3. float (any precision)
 //     4. bool
 //     5. time.Time
-//     6. String() will be called on the underlying value if provided
+//     6. String() will be called on the underlying value if provided (fmt.Stringer)
+//     7. MarshalText() will be called on the underlying value if provided (encoding.TextMarshaler)
+//
+// The fmt.Stringer and encoding.TextMarshaler interfaces take precedence and are
+// honored on both value and pointer receivers.
 //
 // NIL values will lead to an empty string. Unsupported types are leading
 // to their respective type name.
@@ -114,16 +153,62 @@ func (v *Value) String() string {
 			return "True"
 		}
 		return "False"
-	case reflect.Struct:
-		if t, ok := v.Interface().(fmt.Stringer); ok {
-			return t.String()
-		}
+	}
+
+	if s, ok := v.stringFromInterfaces(); ok {
+		return s
 	}
 
 	logf("Value.String() not implemented for type: %s\n", v.getResolvedValue().Kind().String())
 	return v.getResolvedValue().String()
 }
 
+// stringFromInterfaces converts the underlying value using fmt.Stringer or
+// encoding.TextMarshaler, if implemented.
+func (v *Value) stringFromInterfaces() (string, bool) {
+	if !v.val.CanInterface() {
+		return "", false
+	}
+
+	// Most values (like plain strings or numbers) don't have any methods, so
+	// the checks below (which allocate) can be skipped
+	t := v.val.Type()
+	ptrType := reflect.PtrTo(t)
+	if t.NumMethod() == 0 && ptrType.NumMethod() == 0 {
+		return "", false
+	}
+
+	// For pointer receivers, the address of a copy is taken
+	addressable := func() interface{} {
+		ptr := reflect.New(t)
+		ptr.Elem().Set(v.val)
+		return ptr.Interface()
+	}
+
+	if t.Implements(typeOfStringer) {
+		return v.val.Interface().(fmt.Stringer).String(), true
+	}
+	if t.Kind() != reflect.Ptr && ptrType.Implements(typeOfStringer) {
+		return addressable().(fmt.Stringer).String(), true
+	}
+
+	var marshaler encoding.TextMarshaler
+	if t.Implements(typeOfTextMarshaler) {
+		marshaler = v.val.Interface().(encoding.TextMarshaler)
+	} else if t.Kind() != reflect.Ptr && ptrType.Implements(typeOfTextMarshaler) {
+		marshaler = addressable().(encoding.TextMarshaler)
+	}
+	if marshaler != nil {
+		text, err := marshaler.MarshalText()
+		if err != nil {
+			logf("Value.String(): MarshalText() failed: %v\n", err)
+			return "", true
+		}
+		return string(text), true
+	}
+	return "", false
+}
+
 // Integer returns the underlying value as an integer (converts the underlying
 // value, if necessary). If it's not possible to convert the underlying value,
 // it will return 0.
@@ -248,6 +333,23 @@ func (v *Value) Negate() *Value {
 	}
 }
 
+// NegateNumber returns the arithmetic negation of the underlying
+// integer or float. In contrast to Negate() (which is the logical
+// negation) it returns an error if the underlying value is not a number.
+//
+// Example:
+//     AsValue(5).NegateNumber() // -5
+func (v *Value) NegateNumber() (*Value, error) {
+	switch {
+	case v.IsFloat():
+		return AsValue(-1 * v.Float()), nil
+	case v.IsInteger():
+		return AsValue(-1 * v.Integer()), nil
+	default:
+		return nil, errors.Errorf("cannot negate a non-number value of type %s", v.getResolvedValue().Kind().String())
+	}
+}
+
 // Len returns the length for an array, chan, map, slice or string.
 // Otherwise it will return 0.
 func (v *Value) Len() int {
@@ -313,25 +415,36 @@ func (v *Value) Contains(other *Value) bool {
 		fieldValue := v.getResolvedValue().FieldByName(other.String())
 		return fieldValue.IsValid()
 	case reflect.Map:
-		var mapValue reflect.Value
-		switch other.Interface().(type) {
-		case int:
-			mapValue = v.getResolvedValue().MapIndex(other.getResolvedValue())
-		case string:
-			mapValue = v.getResolvedValue().MapIndex(other.getResolvedValue())
-		default:
-			logf("Value.Contains() does not support lookup type '%s'\n", other.getResolvedValue().Kind().String())
+		key := other.getResolvedValue()
+		keyType := v.getResolvedValue().Type().Key()
+		if !key.IsValid() || !key.Type().ConvertibleTo(keyType) {
+			logf("Value.Contains() does not support lookup type '%s'\n", key.Kind().String())
 			return false
 		}
+		if key.Type() != keyType {
+			switch {
+			case keyType.Kind() == reflect.Interface, key.Kind() == keyType.Kind():
+				key = key.Convert(keyType)
+			case isNumericKind(key.Kind()) && isNumericKind(keyType.Kind()):
+				// e. g. an int (literal) within a map[int64]..., but no lossy conversions
+				converted := key.Convert(keyType)
+				if converted.Convert(key.Type()).Interface() != key.Interface() {
+					return false
+				}
+				key = converted
+			default:
+				return false
+			}
+		}
 
-		return mapValue.IsValid()
+		return v.getResolvedValue().MapIndex(key).IsValid()
 	case reflect.String:
 		return strings.Contains(v.getResolvedValue().String(), other.String())
 
 	case reflect.Slice, reflect.Array:
 		for i := 0; i < v.getResolvedValue().Len(); i++ {
-			item := v.getResolvedValue().Index(i)
-			if other.Interface() == item.Interface() {
+			item := &Value{val: v.getResolvedValue().Index(i)}
+			if other.EqualValueTo(item) {
 				return true
 			}
 		}
@@ -353,16 +466,30 @@ func (v *Value) CanSlice() bool {
 	return false
 }
 
-// Iterate iterates over a map, array, slice or a string. It calls the
+// Iterate iterates over a map, array, slice, string or channel. It calls the
 // function's first argument for every value with the following arguments:
 //
 //     idx      current 0-index
-//     count    total amount of items
+//     count    total amount of items (-1 for channels, the amount is unknown)
 //     key      *Value for the key or item
 //     value    *Value (only for maps, the respective value for a specific key)
 //
+// Strings are iterated by rune (every key is a string containing one rune);
+// maps are iterated in no particular order (use IterateOrder to sort the keys).
+// Channels are received from until they are closed.
+// The iteration stops as soon as the function returns false.
 // If the underlying value has no items or is not one of the types above,
 // the empty function (function's second argument) will be called.
+//
+// This is the primitive the for-tag is built upon, so custom tags can use it
+// to loop over the same kinds of values:
+//
+//     value.Iterate(func(idx, count int, key, value *Value) bool {
+//         // render the item...
+//         return true // continue
+//     }, func() {
+//         // no items
+//     })
 func (v *Value) Iterate(fn func(idx, count int, key, value *Value) bool, empty func()) {
 	v.IterateOrder(fn, empty, false, false)
 }
@@ -370,6 +497,7 @@ func (v *Value) Iterate(fn func(idx, count int, key, value *Value) bool, empty f
 // IterateOrder behaves like Value.Iterate, but can iterate through an array/slice/string in reverse. Does
 // not affect the iteration through a map because maps don't have any particular order.
 // However, you can force an order using the `sorted` keyword (and even use `reversed sorted`).
+// Channels are drained (until closed) before iterating if reverse or sorted is given.
 func (v *Value) IterateOrder(fn func(idx, count int, key, value *Value) bool, empty func(), reverse bool, sorted bool) {
 	switch v.getResolvedValue().Kind() {
 	case reflect.Map:
@@ -384,7 +512,7 @@ func (v *Value) IterateOrder(fn func(idx, count int, key, value *Value) bool, em
 		keyLen := len(keys)
 		for idx, key := range keys {
 			value := v.getResolvedValue().MapIndex(key)
-			if !fn(idx, keyLen, &Value{val: key}, &Value{val: value}) {
+			if !fn(idx, keyLen, iterationValue(key), iterationValue(value)) {
 				return
 			}
 		}
@@ -392,63 +520,70 @@ func (v *Value) IterateOrder(fn func(idx, count int, key, value *Value) bool, em
 			empty()
 		}
 		return // done
-	case reflect.Array, reflect.Slice:
-		var items valuesList
-
-		itemCount := v.getResolvedValue().Len()
-		for i := 0; i < itemCount; i++ {
-			items = append(items, &Value{val: v.getResolvedValue().Index(i)})
+	case reflect.Chan:
+		ch := v.getResolvedValue()
+		if ch.IsNil() || ch.Type().ChanDir()&reflect.RecvDir == 0 {
+			logf("Value.Iterate() not available for nil or send-only channels\n")
+			break
 		}
-
-		if sorted {
-			if reverse {
-				sort.Sort(sort.Reverse(items))
-			} else {
-				sort.Sort(items)
-			}
-		} else {
-			if reverse {
-				for i := 0; i < itemCount/2; i++ {
-					items[i], items[itemCount-1-i] = items[itemCount-1-i], items[i]
+		if reverse || sorted {
+			var items valuesList
+			for {
+				item, ok := ch.Recv()
+				if !ok {
+					break
 				}
+				items = append(items, iterationValue(item))
 			}
+			iterateItems(items, fn, empty, reverse, sorted)
+			return // done
 		}
 
-		if len(items) > 0 {
-			for idx, item := range items {
-				if !fn(idx, itemCount, item, nil) {
-					return
-				}
+		idx := 0
+		for {
+			item, ok := ch.Recv()
+			if !ok {
+				break
 			}
-		} else {
+			if !fn(idx, -1, iterationValue(item), nil) {
+				return
+			}
+			idx++
+		}
+		if idx == 0 {
 			empty()
 		}
 		return // done
-	case reflect.String:
-		if sorted {
-			// TODO(flosch): Handle sorted
-			panic("TODO: handle sort for type string")
-		}
+	case reflect.Array, reflect.Slice:
+		rv := v.getResolvedValue()
+		itemCount := rv.Len()
 
-		// TODO(flosch): Not utf8-compatible (utf8-decoding necessary)
-		charCount := v.getResolvedValue().Len()
-		if charCount > 0 {
-			if reverse {
-				for i := charCount - 1; i >= 0; i-- {
-					if !fn(i, charCount, &Value{val: v.getResolvedValue().Slice(i, i+1)}, nil) {
-						return
-					}
-				}
-			} else {
-				for i := 0; i < charCount; i++ {
-					if !fn(i, charCount, &Value{val: v.getResolvedValue().Slice(i, i+1)}, nil) {
-						return
-					}
+		if !reverse && !sorted {
+			// No need to collect the items first
+			for i := 0; i < itemCount; i++ {
+				if !fn(i, itemCount, iterationValue(rv.Index(i)), nil) {
+					return
 				}
 			}
-		} else {
-			empty()
+			if itemCount == 0 {
+				empty()
+			}
+			return // done
+		}
+
+		items := make(valuesList, 0, itemCount)
+		for i := 0; i < itemCount; i++ {
+			items = append(items, iterationValue(rv.Index(i)))
+		}
+		iterateItems(items, fn, empty, reverse, sorted)
+		return // done
+	case reflect.String:
+		// Strings are iterated by rune (not by byte)
+		var items valuesList
+		for _, r := range v.getResolvedValue().String() {
+			items = append(items, &Value{val: reflect.ValueOf(string(r))})
 		}
+		iterateItems(items, fn, empty, reverse, sorted)
 		return // done
 	default:
 		logf("Value.Iterate() not available for type: %s\n", v.getResolvedValue().Kind().String())
@@ -456,6 +591,44 @@ func (v *Value) IterateOrder(fn func(idx, count int, key, value *Value) bool, em
 	empty()
 }
 
+// iterationValue returns the Value of an item (or a map key) of an iterated
+// value. Items of interface types (like the ones of a []interface{}) are
+// unpacked, like Value.Index does.
+func iterationValue(rv reflect.Value) *Value {
+	if rv.Kind() == reflect.Interface {
+		rv = rv.Elem()
+	}
+	return &Value{val: rv}
+}
+
+// iterateItems calls fn for all items (in the requested order) or empty if there are none.
+func iterateItems(items valuesList, fn func(idx, count int, key, value *Value) bool, empty func(), reverse bool, sorted bool) {
+	itemCount := len(items)
+	if sorted {
+		if reverse {
+			sort.Sort(sort.Reverse(items))
+		} else {
+			sort.Sort(items)
+		}
+	} else {
+		if reverse {
+			for i := 0; i < itemCount/2; i++ {
+				items[i], items[itemCount-1-i] = items[itemCount-1-i], items[i]
+			}
+		}
+	}
+
+	if itemCount > 0 {
+		for idx, item := range items {
+			if !fn(idx, itemCount, item, nil) {
+				return
+			}
+		}
+	} else {
+		empty()
+	}
+}
+
 // Interface gives you access to the underlying value.
 func (v *Value) Interface() interface{} {
 	if v.val.IsValid() {
@@ -464,13 +637,43 @@ func (v *Value) Interface() interface{} {
 	return nil
 }
 
+// MarshalJSON implements json.Marshaler by encoding the underlying value.
+// NIL values are encoded as null.
+func (v *Value) MarshalJSON() ([]byte, error) {
+	if v.IsNil() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(v.Interface())
+}
+
 // EqualValueTo checks whether two values are containing the same value or object.
 func (v *Value) EqualValueTo(other *Value) bool {
 	// comparison of uint with int fails using .Interface()-comparison (see issue #64)
 	if v.IsInteger() && other.IsInteger() {
 		return v.Integer() == other.Integer()
 	}
-	return v.Interface() == other.Interface()
+	return v.comparable() == other.comparable()
+}
+
+// comparable returns the underlying value of nullable types (see
+// resolveNullable, NULL values are returned as nil) and the value itself
+// otherwise.
+func (v *Value) comparable() interface{} {
+	rv := v.val
+	if rv.IsValid() && rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return v.Interface()
+	}
+	resolved := resolveNullable(rv)
+	switch {
+	case !resolved.IsValid():
+		return nil
+	case resolved.Type() != rv.Type() && resolved.Type().Comparable():
+		return resolved.Interface()
+	}
+	return v.Interface()
 }
 
 type sortedKeys []reflect.Value
@@ -480,8 +683,8 @@ func (sk sortedKeys) Len() int {
 }
 
 func (sk sortedKeys) Less(i, j int) bool {
-	vi := &Value{val: sk[i]}
-	vj := &Value{val: sk[j]}
+	vi := iterationValue(sk[i])
+	vj := iterationValue(sk[j])
 	switch {
 	case vi.IsInteger() && vj.IsInteger():
 		return vi.Integer() < vj.Integer()
@@ -1,10 +1,14 @@
 package pongo2
 
 import (
+	"database/sql/driver"
+	"encoding"
 	"fmt"
+	"math"
 	"reflect"
 	"strconv"
 	"strings"
+	"sync/atomic"
 
 	"github.com/juju/errors"
 )
@@ -16,7 +20,14 @@ const (
 
 var (
 	typeOfValuePtr   = reflect.TypeOf(new(Value))
+	typeOfError      = reflect.TypeOf((*error)(nil)).Elem()
 	typeOfExecCtxPtr = reflect.TypeOf(new(ExecutionContext))
+	typeOfValuer     = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+
+	typeOfStringer      = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	typeOfTextMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+	typeOfMacroFunction = reflect.TypeOf(macroFunction(nil))
 )
 
 type variablePart struct {
@@ -26,6 +37,60 @@ type variablePart struct {
 
 	isFunctionCall bool
 	callingArgs    []functionCallArgument // needed for a function call, represents all argument nodes (INode supports nested function calls)
+
+	// The result of the last lookup of this (identifier) part on a method or
+	// struct field (holds a *partLookup, see lookupCached)
+	lookup atomic.Value
+}
+
+// partLookup caches how an identifier part is resolved on a concrete type,
+// so the name doesn't have to be looked up using reflection on every execution.
+type partLookup struct {
+	typ         reflect.Type // the type the lookup is valid for
+	methodIndex int          // the index of the method (if >= 0)
+	fieldIndex  []int        // the index path of the struct field (the pointer is resolved first)
+}
+
+// lookupCached resolves the part on current using the cached lookup if it was
+// made for the same type. ok is false if there is no such lookup, so the part
+// must be resolved dynamically (see storeLookup).
+func (part *variablePart) lookupCached(current reflect.Value) (result reflect.Value, isFunc bool, ok bool) {
+	cached, _ := part.lookup.Load().(*partLookup)
+	if cached == nil || cached.typ != current.Type() {
+		return reflect.Value{}, false, false
+	}
+	if cached.methodIndex >= 0 {
+		return current.Method(cached.methodIndex), true, true
+	}
+	if current.Kind() == reflect.Ptr {
+		current = current.Elem()
+		if !current.IsValid() {
+			return current, false, true
+		}
+	}
+	return current.FieldByIndex(cached.fieldIndex), false, true
+}
+
+// storeLookup caches how the part is resolved on values of the given type
+// (methods and struct fields only; map keys are always looked up).
+func (part *variablePart) storeLookup(typ reflect.Type) {
+	if typ.Kind() == reflect.Interface {
+		return
+	}
+	if method, has := typ.MethodByName(part.s); has {
+		part.lookup.Store(&partLookup{typ: typ, methodIndex: method.Index})
+		return
+	}
+	structType := typ
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return
+	}
+	if field, has := structType.FieldByName(part.s); has {
+		part.lookup.Store(&partLookup{typ: typ, methodIndex: -1, fieldIndex: field.Index})
+	}
 }
 
 type functionCallArgument interface {
@@ -53,10 +118,41 @@ type boolResolver struct {
 	val           bool
 }
 
+// listResolver evaluates a list literal ([1, 2, x]) to a []interface{}.
+type listResolver struct {
+	locationToken *Token
+	items         []IEvaluator
+}
+
+// dictResolver evaluates a dict literal ({"a": 1, b: x}) to a
+// map[string]interface{}.
+type dictResolver struct {
+	locationToken *Token
+	keys          []string
+	values        []IEvaluator
+}
+
 type variableResolver struct {
 	locationToken *Token
 
 	parts []*variablePart
+
+	// Set if the variable is passed as a function argument or assigned (see
+	// markMacroAsValue): a macro isn't called without parentheses then
+	macroAsValue bool
+}
+
+// markMacroAsValue marks an expression consisting of a single variable (without
+// filters) which is passed as a function argument or assigned to another
+// variable, so a macro is passed as value instead of being called
+// (e. g. `{% set greet = greetings %}`).
+func markMacroAsValue(expr IEvaluator) {
+	if v, ok := expr.(*nodeFilteredVariable); ok && len(v.filterChain) == 0 {
+		expr = v.resolver
+	}
+	if vr, ok := expr.(*variableResolver); ok {
+		vr.macroAsValue = true
+	}
 }
 
 type nodeFilteredVariable struct {
@@ -71,8 +167,6 @@ type nodeVariable struct {
 	expr          IEvaluator
 }
 
-type executionCtxEval struct{}
-
 func (v *nodeFilteredVariable) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
 	value, err := v.Evaluate(ctx)
 	if err != nil {
@@ -127,6 +221,24 @@ func (b *boolResolver) Execute(ctx *ExecutionContext, writer TemplateWriter) *Er
 	return nil
 }
 
+func (l *listResolver) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	value, err := l.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+	writer.WriteString(value.String())
+	return nil
+}
+
+func (d *dictResolver) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
+	value, err := d.Evaluate(ctx)
+	if err != nil {
+		return err
+	}
+	writer.WriteString(value.String())
+	return nil
+}
+
 func (v *nodeFilteredVariable) GetPositionToken() *Token {
 	return v.locationToken
 }
@@ -151,6 +263,14 @@ func (b *boolResolver) GetPositionToken() *Token {
 	return b.locationToken
 }
 
+func (l *listResolver) GetPositionToken() *Token {
+	return l.locationToken
+}
+
+func (d *dictResolver) GetPositionToken() *Token {
+	return d.locationToken
+}
+
 func (s *stringResolver) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
 	return AsValue(s.val), nil
 }
@@ -167,6 +287,30 @@ func (b *boolResolver) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
 	return AsValue(b.val), nil
 }
 
+func (l *listResolver) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
+	items := make([]interface{}, 0, len(l.items))
+	for _, item := range l.items {
+		value, err := item.Evaluate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, value.Interface())
+	}
+	return AsValue(items), nil
+}
+
+func (d *dictResolver) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
+	m := make(map[string]interface{}, len(d.keys))
+	for idx, key := range d.keys {
+		value, err := d.values[idx].Evaluate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = value.Interface()
+	}
+	return AsValue(m), nil
+}
+
 func (s *stringResolver) FilterApplied(name string) bool {
 	return false
 }
@@ -183,6 +327,14 @@ func (b *boolResolver) FilterApplied(name string) bool {
 	return false
 }
 
+func (l *listResolver) FilterApplied(name string) bool {
+	return false
+}
+
+func (d *dictResolver) FilterApplied(name string) bool {
+	return false
+}
+
 func (nv *nodeVariable) FilterApplied(name string) bool {
 	return nv.expr.FilterApplied(name)
 }
@@ -190,12 +342,17 @@ func (nv *nodeVariable) FilterApplied(name string) bool {
 func (nv *nodeVariable) Execute(ctx *ExecutionContext, writer TemplateWriter) *Error {
 	value, err := nv.expr.Evaluate(ctx)
 	if err != nil {
-		return err
+		// Make sure the error points to the variable tag it originates from
+		return err.updateFromTokenIfNeeded(ctx.template, nv.locationToken)
+	}
+
+	if format := ctx.template.set.FloatFormat; format != "" && value.IsFloat() {
+		value = AsValue(fmt.Sprintf(format, value.Float()))
 	}
 
 	if !nv.expr.FilterApplied("safe") && !value.safe && value.IsString() && ctx.Autoescape {
-		// apply escape filter
-		value, err = filters["escape"](value, nil)
+		// apply the escaper of the current autoescape mode
+		value, err = ctx.escape(value)
 		if err != nil {
 			return err
 		}
@@ -205,10 +362,6 @@ func (nv *nodeVariable) Execute(ctx *ExecutionContext, writer TemplateWriter) *E
 	return nil
 }
 
-func (executionCtxEval) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
-	return AsValue(ctx), nil
-}
-
 func (vr *variableResolver) FilterApplied(name string) bool {
 	return false
 }
@@ -238,18 +391,37 @@ func (vr *variableResolver) resolve(ctx *ExecutionContext) (*Value, error) {
 			// First we're having a look in our private
 			// context (e. g. information provided by tags, like the forloop)
 			val, inPrivate := ctx.Private[vr.parts[0].s]
+			if !inPrivate && ctx.template != nil {
+				// Then at the functions registered on the template set
+				val, inPrivate = ctx.template.set.functions[vr.parts[0].s]
+			}
 			if !inPrivate {
 				// Nothing found? Then have a final lookup in the public context
-				val = ctx.Public[vr.parts[0].s]
+				var inPublic bool
+				val, inPublic = ctx.Public[vr.parts[0].s]
+				if !inPublic && ctx.template != nil && ctx.template.set.Logger != nil {
+					ctx.template.set.warnf("%s: variable '%s' is not defined (line %d, column %d)",
+						ctx.template.name, vr.String(), vr.locationToken.Line, vr.locationToken.Col)
+				}
 			}
 			current = reflect.ValueOf(val) // Get the initial value
 		} else {
 			// Next parts, resolve it from current
 
+			// Methods and struct fields are looked up only once per type
+			isFunc, resolved := false, false
+			if part.typ == varTypeIdent {
+				var result reflect.Value
+				if result, isFunc, resolved = part.lookupCached(current); resolved {
+					current = result
+				} else {
+					part.storeLookup(current.Type())
+				}
+			}
+
 			// Before resolving the pointer, let's see if we have a method to call
 			// Problem with resolving the pointer is we're changing the receiver
-			isFunc := false
-			if part.typ == varTypeIdent {
+			if part.typ == varTypeIdent && !resolved {
 				funcValue := current.MethodByName(part.s)
 				if funcValue.IsValid() {
 					current = funcValue
@@ -257,7 +429,7 @@ func (vr *variableResolver) resolve(ctx *ExecutionContext) (*Value, error) {
 				}
 			}
 
-			if !isFunc {
+			if !isFunc && !resolved {
 				// If current a pointer, resolve it
 				if current.Kind() == reflect.Ptr {
 					current = current.Elem()
@@ -281,8 +453,8 @@ func (vr *variableResolver) resolve(ctx *ExecutionContext) (*Value, error) {
 							return AsValue(nil), nil
 						}
 					default:
-						return nil, errors.Errorf("Can't access an index on type %s (variable %s)",
-							current.Kind().String(), vr.String())
+						return nil, &lookupError{fmt.Sprintf("Can't access an index on type %s (variable %s)",
+							current.Kind().String(), vr.String())}
 					}
 				case varTypeIdent:
 					// debugging:
@@ -295,8 +467,8 @@ func (vr *variableResolver) resolve(ctx *ExecutionContext) (*Value, error) {
 					case reflect.Map:
 						current = current.MapIndex(reflect.ValueOf(part.s))
 					default:
-						return nil, errors.Errorf("Can't access a field by name on type %s (variable %s)",
-							current.Kind().String(), vr.String())
+						return nil, &lookupError{fmt.Sprintf("Can't access a field by name on type %s (variable %s)",
+							current.Kind().String(), vr.String())}
 					}
 				default:
 					panic("unimplemented")
@@ -323,91 +495,30 @@ func (vr *variableResolver) resolve(ctx *ExecutionContext) (*Value, error) {
 			current = reflect.ValueOf(current.Interface())
 		}
 
-		// Check if the part is a function call
-		if part.isFunctionCall || current.Kind() == reflect.Func {
+		// Check if the part is a function call (functions are called even without
+		// parentheses, except for macros which are passed around as values)
+		isMacroValue := vr.macroAsValue && idx == len(vr.parts)-1 && current.Type() == typeOfMacroFunction
+		if part.isFunctionCall || (current.Kind() == reflect.Func && !isMacroValue) {
 			// Check for callable
 			if current.Kind() != reflect.Func {
 				return nil, errors.Errorf("'%s' is not a function (it is %s)", vr.String(), current.Kind().String())
 			}
 
-			// Check for correct function syntax and types
-			// func(*Value, ...) *Value
-			t := current.Type()
-			currArgs := part.callingArgs
-
-			// If an implicit ExecCtx is needed
-			if t.NumIn() > 0 && t.In(0) == typeOfExecCtxPtr {
-				currArgs = append([]functionCallArgument{executionCtxEval{}}, currArgs...)
-			}
-
-			// Input arguments
-			if len(currArgs) != t.NumIn() && !(len(currArgs) >= t.NumIn()-1 && t.IsVariadic()) {
-				return nil,
-					errors.Errorf("Function input argument count (%d) of '%s' must be equal to the calling argument count (%d).",
-						t.NumIn(), vr.String(), len(currArgs))
-			}
-
-			// Output arguments
-			if t.NumOut() != 1 {
-				return nil, errors.Errorf("'%s' must have exactly 1 output argument", vr.String())
-			}
-
 			// Evaluate all parameters
-			var parameters []reflect.Value
-
-			numArgs := t.NumIn()
-			isVariadic := t.IsVariadic()
-			var fnArg reflect.Type
-
-			for idx, arg := range currArgs {
+			args := make([]*Value, 0, len(part.callingArgs))
+			for _, arg := range part.callingArgs {
 				pv, err := arg.Evaluate(ctx)
 				if err != nil {
 					return nil, err
 				}
-
-				if isVariadic {
-					if idx >= t.NumIn()-1 {
-						fnArg = t.In(numArgs - 1).Elem()
-					} else {
-						fnArg = t.In(idx)
-					}
-				} else {
-					fnArg = t.In(idx)
-				}
-
-				if fnArg != typeOfValuePtr {
-					// Function's argument is not a *pongo2.Value, then we have to check whether input argument is of the same type as the function's argument
-					if !isVariadic {
-						if fnArg != reflect.TypeOf(pv.Interface()) && fnArg.Kind() != reflect.Interface {
-							return nil, errors.Errorf("Function input argument %d of '%s' must be of type %s or *pongo2.Value (not %T).",
-								idx, vr.String(), fnArg.String(), pv.Interface())
-						}
-						// Function's argument has another type, using the interface-value
-						parameters = append(parameters, reflect.ValueOf(pv.Interface()))
-					} else {
-						if fnArg != reflect.TypeOf(pv.Interface()) && fnArg.Kind() != reflect.Interface {
-							return nil, errors.Errorf("Function variadic input argument of '%s' must be of type %s or *pongo2.Value (not %T).",
-								vr.String(), fnArg.String(), pv.Interface())
-						}
-						// Function's argument has another type, using the interface-value
-						parameters = append(parameters, reflect.ValueOf(pv.Interface()))
-					}
-				} else {
-					// Function's argument is a *pongo2.Value
-					parameters = append(parameters, reflect.ValueOf(pv))
-				}
+				args = append(args, pv)
 			}
 
-			// Check if any of the values are invalid
-			for _, p := range parameters {
-				if p.Kind() == reflect.Invalid {
-					return nil, errors.Errorf("Calling a function using an invalid parameter")
-				}
+			rv, err := callFunction(ctx, current, vr.String(), args)
+			if err != nil {
+				return nil, err
 			}
 
-			// Call it and get first return parameter back
-			rv := current.Call(parameters)[0]
-
 			if rv.Type() != typeOfValuePtr {
 				current = reflect.ValueOf(rv.Interface())
 			} else {
@@ -426,17 +537,232 @@ func (vr *variableResolver) resolve(ctx *ExecutionContext) (*Value, error) {
 	return &Value{val: current, safe: isSafe}, nil
 }
 
+// callFunction calls fn (a Go function or a macro) with the given arguments
+// and returns its first return value. Functions must have the signature
+// func([*ExecutionContext, ]args...) value[, error]; arguments are converted
+// to fn's parameter types where possible (see convertFunctionArgument).
+func callFunction(ctx *ExecutionContext, fn reflect.Value, name string, args []*Value) (reflect.Value, error) {
+	// Check for correct function syntax and types
+	// func(*Value, ...) *Value
+	t := fn.Type()
+
+	// If an implicit ExecCtx is needed
+	if t.NumIn() > 0 && t.In(0) == typeOfExecCtxPtr {
+		if ctx == nil {
+			return reflect.Value{}, errors.Errorf("'%s' requires an execution context", name)
+		}
+		args = append([]*Value{AsValue(ctx)}, args...)
+	}
+
+	// Input arguments
+	if len(args) != t.NumIn() && !(len(args) >= t.NumIn()-1 && t.IsVariadic()) {
+		return reflect.Value{},
+			errors.Errorf("Function input argument count (%d) of '%s' must be equal to the calling argument count (%d).",
+				t.NumIn(), name, len(args))
+	}
+
+	// Output arguments (optionally followed by an error)
+	if t.NumOut() != 1 && (t.NumOut() != 2 || t.Out(1) != typeOfError) {
+		return reflect.Value{}, errors.Errorf("'%s' must have exactly 1 output argument (optionally followed by an error)", name)
+	}
+
+	var parameters []reflect.Value
+
+	numArgs := t.NumIn()
+	isVariadic := t.IsVariadic()
+	var fnArg reflect.Type
+
+	for idx, pv := range args {
+		if isVariadic {
+			if idx >= t.NumIn()-1 {
+				fnArg = t.In(numArgs - 1).Elem()
+			} else {
+				fnArg = t.In(idx)
+			}
+		} else {
+			fnArg = t.In(idx)
+		}
+
+		if fnArg != typeOfValuePtr {
+			// Function's argument is not a *pongo2.Value, then we have to check whether input argument is of
+			// the same type as the function's argument (or can be converted to it)
+			param, ok := convertFunctionArgument(pv, fnArg)
+			if !ok {
+				if !isVariadic {
+					return reflect.Value{}, errors.Errorf("Function input argument %d of '%s' must be of type %s or *pongo2.Value (not %T).",
+						idx, name, fnArg.String(), pv.Interface())
+				}
+				return reflect.Value{}, errors.Errorf("Function variadic input argument of '%s' must be of type %s or *pongo2.Value (not %T).",
+					name, fnArg.String(), pv.Interface())
+			}
+			parameters = append(parameters, param)
+		} else {
+			// Function's argument is a *pongo2.Value
+			parameters = append(parameters, reflect.ValueOf(pv))
+		}
+	}
+
+	// Check if any of the values are invalid
+	for _, p := range parameters {
+		if p.Kind() == reflect.Invalid {
+			return reflect.Value{}, errors.Errorf("Calling a function using an invalid parameter")
+		}
+	}
+
+	// Call it and get first return parameter back
+	values := fn.Call(parameters)
+	if len(values) == 2 && !values[1].IsNil() {
+		return reflect.Value{}, values[1].Interface().(error)
+	}
+	return values[0], nil
+}
+
+// lookupError is returned by resolve() if a field or an index is looked up on a
+// value which can't have any (like an attribute of a number). Error fallback
+// filters (like default) apply on these errors only, all others (like failing
+// function calls) are passed on.
+type lookupError struct {
+	msg string
+}
+
+func (e *lookupError) Error() string {
+	return e.msg
+}
+
+// resolveNullable returns the underlying value of nullable types (the
+// database/sql Null* types or any other struct consisting of a Valid bool
+// and a value field) and types implementing driver.Valuer. NULL values are
+// returned as invalid reflect.Value. Other values are returned unchanged.
+//
+// It's applied by Value (see getResolvedValue) only, so the nullable types
+// still reach functions, filters and macros as they are.
+func resolveNullable(rv reflect.Value) reflect.Value {
+	if !rv.IsValid() {
+		return rv
+	}
+
+	if rv.Kind() == reflect.Struct && rv.NumField() == 2 {
+		// The Valid field is either the first or the second one
+		t := rv.Type()
+		for i := 0; i < 2; i++ {
+			valid := t.Field(i)
+			if valid.Name != "Valid" || valid.Type.Kind() != reflect.Bool {
+				continue
+			}
+			if !rv.Field(i).Bool() {
+				return reflect.Value{}
+			}
+			if value := rv.Field(1 - i); value.CanInterface() {
+				return value
+			}
+		}
+	}
+
+	if rv.Type().NumMethod() > 0 && rv.Type().Implements(typeOfValuer) {
+		if rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return reflect.Value{}
+		}
+		value, err := rv.Interface().(driver.Valuer).Value()
+		if err != nil {
+			// Keep the original value
+			return rv
+		}
+		return reflect.ValueOf(value)
+	}
+
+	return rv
+}
+
+// convertFunctionArgument converts an argument to the type of the function's
+// parameter. Numbers are converted between the numeric types if no data gets
+// lost (e. g. an int literal can be passed to an int64 or float64 parameter,
+// but 1.5 can't be passed to an int parameter) and nil can be passed to
+// parameters of a nillable type.
+func convertFunctionArgument(pv *Value, fnArg reflect.Type) (reflect.Value, bool) {
+	arg := pv.Interface()
+	if arg == nil {
+		switch fnArg.Kind() {
+		case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+			return reflect.Zero(fnArg), true
+		}
+		// Invalid parameters are reported by the caller
+		return reflect.ValueOf(arg), true
+	}
+
+	rv := reflect.ValueOf(arg)
+	if rv.Type() == fnArg || fnArg.Kind() == reflect.Interface {
+		return rv, rv.Type() == fnArg || rv.Type().Implements(fnArg)
+	}
+	if isNumericKind(rv.Kind()) && isNumericKind(fnArg.Kind()) && convertibleNumber(rv, fnArg) {
+		return rv.Convert(fnArg), true
+	}
+	return rv, false
+}
+
+// convertibleNumber returns whether the number can be converted to the
+// numeric type t without losing data.
+func convertibleNumber(rv reflect.Value, t reflect.Type) bool {
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := rv.Int()
+		switch t.Kind() {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return i >= 0 && !reflect.Zero(t).OverflowUint(uint64(i))
+		case reflect.Float32, reflect.Float64:
+			f := float64(i)
+			if t.Kind() == reflect.Float32 {
+				f = float64(float32(i))
+			}
+			return f < math.MaxInt64 && int64(f) == i
+		}
+		return !reflect.Zero(t).OverflowInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u := rv.Uint()
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return u <= math.MaxInt64 && !reflect.Zero(t).OverflowInt(int64(u))
+		case reflect.Float32, reflect.Float64:
+			f := float64(u)
+			if t.Kind() == reflect.Float32 {
+				f = float64(float32(u))
+			}
+			return f < math.MaxUint64 && uint64(f) == u
+		}
+		return !reflect.Zero(t).OverflowUint(u)
+	}
+
+	// Floats
+	f := rv.Float()
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 && !reflect.Zero(t).OverflowInt(int64(f))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return f == math.Trunc(f) && f >= 0 && f < math.MaxUint64 && !reflect.Zero(t).OverflowUint(uint64(f))
+	}
+	return !reflect.Zero(t).OverflowFloat(f)
+}
+
+func isNumericKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
 func (vr *variableResolver) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
 	value, err := vr.resolve(ctx)
 	if err != nil {
-		return AsValue(nil), ctx.Error(err.Error(), vr.locationToken)
+		return AsValue(nil), ctx.OrigError(err, vr.locationToken)
 	}
 	return value, nil
 }
 
 func (v *nodeFilteredVariable) FilterApplied(name string) bool {
 	for _, filter := range v.filterChain {
-		if filter.name == name {
+		if filter.applies(name) {
 			return true
 		}
 	}
@@ -444,12 +770,39 @@ func (v *nodeFilteredVariable) FilterApplied(name string) bool {
 }
 
 func (v *nodeFilteredVariable) Evaluate(ctx *ExecutionContext) (*Value, *Error) {
+	filterChain := v.filterChain
+
 	value, err := v.resolver.Evaluate(ctx)
 	if err != nil {
-		return nil, err
+		if _, isLookupError := err.OrigError.(*lookupError); !isLookupError {
+			return nil, err
+		}
+
+		// An error fallback filter (like default) in the chain replaces the
+		// value which couldn't be looked up by its parameter; all preceding
+		// filters are skipped.
+		idx := -1
+		for i, filter := range filterChain {
+			if errorFallbackFilters[filter.name] {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			return nil, err
+		}
+
+		value = AsValue(nil)
+		if param := filterChain[idx].parameter; param != nil {
+			value, err = param.Evaluate(ctx)
+			if err != nil {
+				return nil, err
+			}
+		}
+		filterChain = filterChain[idx+1:]
 	}
 
-	for _, filter := range v.filterChain {
+	for _, filter := range filterChain {
 		value, err = filter.Execute(value, ctx)
 		if err != nil {
 			return nil, err
@@ -528,6 +881,15 @@ func (p *Parser) parseVariableOrLiteral() (IEvaluator, *Error) {
 		default:
 			return nil, p.Error("This keyword is not allowed here.", nil)
 		}
+	case TokenSymbol:
+		switch t.Val {
+		case "[":
+			p.Consume()
+			return p.parseListLiteral(t)
+		case "{":
+			p.Consume()
+			return p.parseDictLiteral(t)
+		}
 	}
 
 	resolver := &variableResolver{
@@ -598,6 +960,7 @@ variableLoop:
 					if err != nil {
 						return nil, err
 					}
+					markMacroAsValue(exprArg)
 					part.callingArgs = append(part.callingArgs, exprArg)
 
 					if p.Match(TokenSymbol, ")") != nil {
@@ -627,6 +990,82 @@ variableLoop:
 	return resolver, nil
 }
 
+// parseListLiteral parses the part after the '[' of a list literal:
+//     [expr, expr, ...]
+func (p *Parser) parseListLiteral(start *Token) (IEvaluator, *Error) {
+	lr := &listResolver{
+		locationToken: start,
+	}
+
+	if p.Match(TokenSymbol, "]") != nil {
+		return lr, nil // empty list
+	}
+	for {
+		item, err := p.ParseExpression()
+		if err != nil {
+			return nil, err
+		}
+		lr.items = append(lr.items, item)
+
+		if p.Match(TokenSymbol, "]") != nil {
+			return lr, nil
+		}
+		if p.Match(TokenSymbol, ",") == nil {
+			return nil, p.Error("Expected ',' or ']' in the list literal.", nil)
+		}
+		if p.Match(TokenSymbol, "]") != nil {
+			return lr, nil // trailing comma
+		}
+	}
+}
+
+// parseDictLiteral parses the part after the '{' of a dict literal. Keys are
+// string literals or identifiers (which are not resolved):
+//     {"key": expr, key: expr, ...}
+func (p *Parser) parseDictLiteral(start *Token) (IEvaluator, *Error) {
+	dr := &dictResolver{
+		locationToken: start,
+	}
+
+	if p.Match(TokenSymbol, "}") != nil {
+		return dr, nil // empty dict
+	}
+	for {
+		keyToken := p.MatchType(TokenString)
+		if keyToken == nil {
+			keyToken = p.MatchType(TokenIdentifier)
+		}
+		if keyToken == nil {
+			return nil, p.Error("Expected a string or an identifier as key in the dict literal.", nil)
+		}
+		for _, key := range dr.keys {
+			if key == keyToken.Val {
+				return nil, p.Error(fmt.Sprintf("Key '%s' given twice in the dict literal.", key), keyToken)
+			}
+		}
+		if p.Match(TokenSymbol, ":") == nil {
+			return nil, p.Error("Expected ':' after the key in the dict literal.", nil)
+		}
+
+		value, err := p.ParseExpression()
+		if err != nil {
+			return nil, err
+		}
+		dr.keys = append(dr.keys, keyToken.Val)
+		dr.values = append(dr.values, value)
+
+		if p.Match(TokenSymbol, "}") != nil {
+			return dr, nil
+		}
+		if p.Match(TokenSymbol, ",") == nil {
+			return nil, p.Error("Expected ',' or '}' in the dict literal.", nil)
+		}
+		if p.Match(TokenSymbol, "}") != nil {
+			return dr, nil // trailing comma
+		}
+	}
+}
+
 func (p *Parser) parseVariableOrLiteralWithFilter() (*nodeFilteredVariable, *Error) {
 	v := &nodeFilteredVariable{
 		locationToken: p.Current(),